@@ -0,0 +1,94 @@
+#ifndef __BEESY_BPFOBJ_H
+#define __BEESY_BPFOBJ_H
+
+#include "iter.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1469
+#define BPF_OBJ_NAME_LEN 16U
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1474
+#define BPF_TAG_SIZE 8
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1021
+enum bpf_prog_type {
+    BPF_PROG_TYPE_UNSPEC,
+};
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1075
+enum bpf_attach_type {
+    BPF_CGROUP_INET_INGRESS,
+};
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1141
+enum bpf_link_type {
+    BPF_LINK_TYPE_UNSPEC,
+};
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/bpf.h#L1453
+struct bpf_prog_aux {
+    __u32 id;
+    const char *attach_func_name;
+    __u64 load_time; // CLOCK_BOOTTIME nanoseconds
+    char name[BPF_OBJ_NAME_LEN];
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/bpf.h#L1557
+struct bpf_prog {
+    __u16 pages; // number of allocated pages
+    enum bpf_prog_type type;
+    enum bpf_attach_type expected_attach_type;
+    __u32 len; // number of filter blocks
+    __u32 jited_len; // size of jited insns in bytes
+    __u8 tag[BPF_TAG_SIZE];
+    struct bpf_prog_aux *aux;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/bpf.h#L263
+struct bpf_map {
+    enum bpf_map_type map_type;
+    __u32 key_size;
+    __u32 value_size;
+    __u32 max_entries;
+    __u32 map_flags;
+    __u32 id;
+    char name[BPF_OBJ_NAME_LEN];
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v5.10/source/include/linux/bpf.h#L131
+struct bpf_map_memory {
+    __u32 pages;
+} __attribute__((preserve_access_index));
+
+// Before Linux 5.11, maps were charged against RLIMIT_MEMLOCK and tracked
+// their memory in pages; afterwards, the kernel only offers the memory usage
+// through a map operation, which we cannot call. See also:
+// https://github.com/torvalds/linux/commit/80ee81e0403c48f4eb342f7a8d7cf2b9f7a7fa35
+struct bpf_map___pre511 {
+    struct bpf_map_memory memory;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/bpf.h#L1616
+struct bpf_link {
+    __u32 id;
+    enum bpf_link_type type;
+    struct bpf_prog *prog;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/prog_iter.c#L72
+struct bpf_iter__bpf_prog {
+    struct bpf_iter_meta *meta;
+    struct bpf_prog *prog;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/map_iter.c#L72
+struct bpf_iter__bpf_map {
+    struct bpf_iter_meta *meta;
+    struct bpf_map *map;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/link_iter.c#L72
+struct bpf_iter__bpf_link {
+    struct bpf_iter_meta *meta;
+    struct bpf_link *link;
+} __attribute__((preserve_access_index));
+
+#endif
@@ -0,0 +1,62 @@
+#ifndef __BEESY_CGROUP_H
+#define __BEESY_CGROUP_H
+
+#include "task.h"
+#include "bpf_core_read.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/kernfs.h#L192
+struct kernfs_node {
+    const char *name;
+    __u64 id;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/cgroup-defs.h#L158
+struct cgroup_subsys_state {
+    struct cgroup *cgroup;
+    struct cgroup_subsys_state *parent; // NULL for the root cgroup
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/cgroup-defs.h#L420
+struct cgroup {
+    struct cgroup_subsys_state self; // must be the first member
+    struct kernfs_node *kn;
+    int level;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/cgroup_iter.c#L194
+struct bpf_iter__cgroup {
+    struct bpf_iter_meta *meta;
+    struct cgroup *cgroup;
+} __attribute__((preserve_access_index));
+
+// Open-coded css task iterator, available since Linux 6.7; see also:
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/helpers.c#L2810
+struct bpf_iter_css_task {
+    __u64 __opaque[1];
+} __attribute__((aligned(8)));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/cgroup.h#L43
+#define CSS_TASK_ITER_PROCS    (1U << 0) // only thread group leaders
+#define CSS_TASK_ITER_THREADED (1U << 1) // walk all threaded css_sets in the domain
+
+extern int bpf_iter_css_task_new(struct bpf_iter_css_task *it,
+        struct cgroup_subsys_state *css, unsigned int flags) __weak __ksym;
+extern struct task_struct *bpf_iter_css_task_next(struct bpf_iter_css_task *it) __weak __ksym;
+extern void bpf_iter_css_task_destroy(struct bpf_iter_css_task *it) __weak __ksym;
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/cgroup-defs.h#L220
+struct css_set {
+    struct cgroup *dfl_cgrp;
+} __attribute__((preserve_access_index));
+
+/*
+ * task_cgroup_id returns the ID of the cgroup of the specified task in the
+ * unified (v2) cgroup hierarchy. The cgroup ID is the inode number of the
+ * cgroup's directory in the cgroup2 filesystem.
+ */
+__u64 task_cgroup_id(struct task_struct *task)
+{
+    return BPF_CORE_READ(task, cgroups, dfl_cgrp, kn, id);
+}
+
+#endif
@@ -0,0 +1,42 @@
+#ifndef __BEESY_FILE_H
+#define __BEESY_FILE_H
+
+#include "iter.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/fs.h#L632
+struct inode {
+    unsigned short i_mode;
+    unsigned long i_ino;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/dcache.h#L49
+struct qstr {
+    const unsigned char *name;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/dcache.h#L82
+struct dentry {
+    struct qstr d_name;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/path.h#L8
+struct path {
+    struct dentry *dentry;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/fs.h#L1014
+struct file {
+    struct path f_path;
+    struct inode *f_inode;
+    void *private_data;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/task_iter.c#L400
+struct bpf_iter__task_file {
+    struct bpf_iter_meta *meta;
+    struct task_struct *task;
+    __u32 fd;
+    struct file *file;
+} __attribute__((preserve_access_index));
+
+#endif
@@ -0,0 +1,109 @@
+#ifndef __BEESY_FRAME_H
+#define __BEESY_FRAME_H
+
+#include "iter.h"
+
+/*
+ * Self-describing framing of eBPF iterator output. A framed stream starts
+ * with a beesy_stream_header, followed by any number of frames, each
+ * consisting of a beesy_frame_header followed by len payload bytes. Frame
+ * types are defined by the individual iterator programs.
+ *
+ * See also github.com/thediveo/beesy/bpfiter.Frames.
+ */
+
+#define BEESY_STREAM_MAGIC 0x59534542 // "BESY" in little endian
+#define BEESY_STREAM_VERSION 1
+
+// maximum frame payload length. All output for a single iterator element must
+// fit into the seq_file buffer of 8 pages (32 KiB with 4 KiB pages), as the
+// kernel otherwise fails the whole iteration with E2BIG instead of just
+// dropping the element. The limit thus leaves room for the stream header,
+// frame headers, and further frames of the same element.
+#define BEESY_MAX_FRAME_LEN (16*1024)
+
+struct beesy_stream_header {
+    __u32 magic;
+    __u16 version;
+    __u16 reserved;
+};
+
+struct beesy_frame_header {
+    __u16 type;
+    __u16 reserved;
+    __u32 len; // payload length in bytes, excluding this frame header
+};
+
+// number of concurrent iteration sessions that can be tracked by
+// beesy_stream_sessions.
+#define BEESY_MAX_STREAM_SESSIONS 1024
+
+// beesy_stream_sessions tracks the iteration sessions that already got their
+// stream header, mapping the session ID to the sequence number of the element
+// that emitted the stream header. The kernel discards the output of an element
+// that overflows the seq_file buffer and then reruns the same element, so
+// tracking just "header written" would lose the stream header in this case.
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, BEESY_MAX_STREAM_SESSIONS);
+    __type(key, __u64);   // bpf_iter_meta.session_id
+    __type(value, __u64); // bpf_iter_meta.seq_num
+} beesy_stream_sessions SEC(".maps");
+
+/*
+ * emit_stream_header writes the stream header when called for the first
+ * element of an iteration session that emits anything; otherwise it does
+ * nothing. Please note that this is not necessarily the element with seq_num
+ * 0, as iterator programs might skip elements.
+ *
+ * Iterator programs using framing must call emit_stream_header exactly once
+ * per element before emitting the first frame of this element.
+ */
+static __always_inline long emit_stream_header(struct bpf_iter_meta *meta)
+{
+    __u64 session_id = meta->session_id;
+    __u64 seq_num = meta->seq_num;
+    __u64 *first = bpf_map_lookup_elem(&beesy_stream_sessions, &session_id);
+    if (first != NULL && *first != seq_num) {
+        return 0;
+    }
+    bpf_map_update_elem(&beesy_stream_sessions, &session_id, &seq_num, BPF_ANY);
+    struct beesy_stream_header hdr = {
+        .magic = BEESY_STREAM_MAGIC,
+        .version = BEESY_STREAM_VERSION,
+    };
+    return bpf_seq_write(meta->seq, &hdr, sizeof(hdr));
+}
+
+/*
+ * emit_frame_header writes the header of a frame of the specified type with
+ * len payload bytes; the caller then must write exactly len payload bytes.
+ */
+static __always_inline long emit_frame_header(struct seq_file *seq, __u16 type, __u32 len)
+{
+    if (len > BEESY_MAX_FRAME_LEN) {
+        return -1;
+    }
+    struct beesy_frame_header hdr = {
+        .type = type,
+        .len = len,
+    };
+    return bpf_seq_write(seq, &hdr, sizeof(hdr));
+}
+
+/*
+ * emit_frame writes a frame of the specified type with len payload bytes.
+ */
+static __always_inline long emit_frame(struct seq_file *seq, __u16 type, const void *payload, __u32 len)
+{
+    long err = emit_frame_header(seq, type, len);
+    if (err || len == 0) {
+        return err;
+    }
+    if (len > BEESY_MAX_FRAME_LEN) { // pacify the verifier
+        len = BEESY_MAX_FRAME_LEN;
+    }
+    return bpf_seq_write(seq, (void *)payload, len);
+}
+
+#endif
@@ -0,0 +1,27 @@
+#ifndef __BEESY_KALLSYMS_H
+#define __BEESY_KALLSYMS_H
+
+#include "iter.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/kallsyms.h#L18
+#define KSYM_NAME_LEN 512
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/module.h#L39
+#define MODULE_NAME_LEN 56
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/kallsyms.c#L660
+struct kallsym_iter {
+    unsigned long value;
+    char type;
+    char name[KSYM_NAME_LEN];
+    char module_name[MODULE_NAME_LEN];
+    int exported;
+    int show_value;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/kallsyms.c#L790
+struct bpf_iter__ksym {
+    struct bpf_iter_meta *meta;
+    struct kallsym_iter *ksym;
+} __attribute__((preserve_access_index));
+
+#endif
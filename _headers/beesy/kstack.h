@@ -0,0 +1,47 @@
+#ifndef __BEESY_KSTACK_H
+#define __BEESY_KSTACK_H
+
+#include "iter.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/perf_event.h#L1236
+#define PERF_MAX_STACK_DEPTH 127
+
+// kstack is the scratch space for capturing kernel stacks, as these are way
+// too large to fit onto the eBPF stack.
+struct kstack {
+    __u64 ips[PERF_MAX_STACK_DEPTH];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct kstack);
+} kstack_scratch SEC(".maps");
+
+/*
+ * capture_kstack captures the kernel stack of the specified task into this
+ * CPU's kstack scratch space, returning the scratch space as well as the
+ * length of the captured stack in bytes via *len. In case of failure, it
+ * returns NULL and sets *len to 0.
+ *
+ * Please note that the captured stack must be written to the seq_file before
+ * doing anything that might sleep.
+ */
+struct kstack *capture_kstack(struct task_struct *task, __u32 *len)
+{
+    *len = 0;
+    __u32 zero = 0;
+    struct kstack *ks = bpf_map_lookup_elem(&kstack_scratch, &zero);
+    if (ks == NULL) {
+        return NULL;
+    }
+    long l = bpf_get_task_stack(task, ks->ips, sizeof(ks->ips), 0);
+    if (l <= 0) {
+        return NULL;
+    }
+    *len = l;
+    return ks;
+}
+
+#endif
@@ -0,0 +1,53 @@
+#ifndef __BEESY_NAMESPACES_H
+#define __BEESY_NAMESPACES_H
+
+#include "task.h"
+#include "bpf_core_read.h"
+
+// task_namespaces defines the binary representation of the inode numbers of
+// the namespaces a task is attached to. Inode numbers are zero for exited
+// tasks.
+struct task_namespaces {
+    __u32 cgroup;
+    __u32 ipc;
+    __u32 mnt;
+    __u32 net;
+    __u32 pid;
+    __u32 time;
+    __u32 user;
+    __u32 uts;
+};
+
+/*
+ * fill_task_namespaces fills in the inode numbers of the namespaces the
+ * specified task is attached to.
+ */
+void fill_task_namespaces(struct task_struct *task, struct task_namespaces *ns)
+{
+    __builtin_memset(ns, 0, sizeof(*ns));
+
+    // the active PID namespace of a task is the PID namespace at the level of
+    // the task's PID, see also:
+    // https://elixir.bootlin.com/linux/v6.12/source/include/linux/pid_namespace.h#L109
+    struct pid *thrpid = BPF_CORE_READ(task, thread_pid);
+    if (thrpid != NULL) {
+        unsigned int level = BPF_CORE_READ(thrpid, level);
+        ns->pid = BPF_CORE_READ(thrpid, numbers[level].ns, ns.inum);
+    }
+    ns->user = BPF_CORE_READ(task, real_cred, user_ns, ns.inum);
+
+    struct nsproxy *nsproxy = BPF_CORE_READ(task, nsproxy);
+    if (nsproxy == NULL) {
+        return;
+    }
+    ns->cgroup = BPF_CORE_READ(nsproxy, cgroup_ns, ns.inum);
+    ns->ipc = BPF_CORE_READ(nsproxy, ipc_ns, ns.inum);
+    ns->mnt = BPF_CORE_READ(nsproxy, mnt_ns, ns.inum);
+    ns->net = BPF_CORE_READ(nsproxy, net_ns, ns.inum);
+    if (bpf_core_field_exists(nsproxy->time_ns)) {
+        ns->time = BPF_CORE_READ(nsproxy, time_ns, ns.inum);
+    }
+    ns->uts = BPF_CORE_READ(nsproxy, uts_ns, ns.inum);
+}
+
+#endif
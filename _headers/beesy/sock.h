@@ -0,0 +1,142 @@
+#ifndef __BEESY_SOCK_H
+#define __BEESY_SOCK_H
+
+#include "iter.h"
+#include "file.h"
+#include "bpf_core_read.h"
+#include "bpf_endian.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/socket.h#L193
+#define AF_UNIX     1
+#define AF_INET     2
+#define AF_INET6    10
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/tcp_states.h#L12
+#define TCP_LISTEN  10
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/un.h#L7
+#define UNIX_PATH_MAX 108
+
+typedef __u32 uid_t;
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/refcount_types.h#L15
+typedef struct {
+    atomic_t refs;
+} refcount_t;
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/in6.h#L33
+struct in6_addr {
+    union {
+        __u8 u6_addr8[16];
+    } in6_u;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/sock.h#L150
+struct sock_common {
+    __be32 skc_daddr;
+    __be32 skc_rcv_saddr;
+    __be16 skc_dport;
+    __u16 skc_num;
+    unsigned short skc_family;
+    volatile unsigned char skc_state;
+    struct in6_addr skc_v6_daddr;
+    struct in6_addr skc_v6_rcv_saddr;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/skbuff.h#L320
+struct sk_buff_head {
+    __u32 qlen;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/net.h#L117
+struct socket {
+    struct file *file;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/sock.h#L341
+struct sock {
+    struct sock_common __sk_common;
+    struct sk_buff_head sk_receive_queue;
+    atomic_t sk_rmem_alloc;
+    refcount_t sk_wmem_alloc;
+    __u32 sk_ack_backlog;
+    __u32 sk_max_ack_backlog;
+    __u16 sk_type;
+    struct socket *sk_socket;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/inet_sock.h#L206
+struct inet_sock {
+    struct sock sk;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/inet_connection_sock.h#L82
+struct inet_connection_sock {
+    struct inet_sock icsk_inet;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/tcp.h#L199
+struct tcp_sock {
+    struct inet_connection_sock inet_conn;
+    __u32 rcv_nxt;
+    __u32 copied_seq;
+    __u32 snd_una;
+    __u32 write_seq;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/udp.h#L36
+struct udp_sock {
+    struct inet_sock inet;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/un.h#L9
+struct sockaddr_un {
+    unsigned short sun_family;
+    char sun_path[UNIX_PATH_MAX];
+};
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/af_unix.h#L33
+struct unix_address {
+    int len;
+    struct sockaddr_un name[];
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/af_unix.h#L60
+struct unix_sock {
+    struct sock sk;
+    struct unix_address *addr;
+    struct sock *peer;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/net/ipv4/tcp_ipv4.c#L3225
+struct bpf_iter__tcp {
+    struct bpf_iter_meta *meta;
+    struct sock_common *sk_common;
+    uid_t uid;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/net/ipv4/udp.c#L3160
+struct bpf_iter__udp {
+    struct bpf_iter_meta *meta;
+    struct udp_sock *udp_sk;
+    uid_t uid;
+    int bucket;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/net/unix/af_unix.c#L3560
+struct bpf_iter__unix {
+    struct bpf_iter_meta *meta;
+    struct unix_sock *unix_sk;
+    uid_t uid;
+} __attribute__((preserve_access_index));
+
+/*
+ * sock_i_ino returns the inode number of the socket inode of the specified
+ * sock, or 0 if the sock isn't associated with a socket (anymore).
+ */
+unsigned long sock_i_ino(struct sock *sk)
+{
+    return BPF_CORE_READ(sk, sk_socket, file, f_inode, i_ino);
+}
+
+#endif
@@ -0,0 +1,71 @@
+#ifndef __BEESY_STATE_H
+#define __BEESY_STATE_H
+
+#include "task.h"
+#include "bpf_core_read.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L99
+#define TASK_RUNNING            0x00000000
+#define TASK_INTERRUPTIBLE      0x00000001
+#define TASK_UNINTERRUPTIBLE    0x00000002
+#define __TASK_STOPPED          0x00000004
+#define __TASK_TRACED           0x00000008
+#define EXIT_DEAD               0x00000010
+#define EXIT_ZOMBIE             0x00000020
+#define TASK_PARKED             0x00000040
+#define TASK_NOLOAD             0x00000400
+#define TASK_RTLOCK_WAIT        0x00001000
+
+#define TASK_IDLE               (TASK_UNINTERRUPTIBLE | TASK_NOLOAD)
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L1617
+#define TASK_REPORT             0x0000007f
+#define TASK_REPORT_IDLE        (TASK_REPORT + 1)
+
+/*
+ * task_state returns the raw state of the specified task, regardless of the
+ * kernel version.
+ */
+unsigned int task_state(struct task_struct *task)
+{
+    if (bpf_core_field_exists(task->__state)) {
+        return BPF_CORE_READ(task, __state);
+    }
+    struct task_struct___pre514 *t = (void *) task;
+    return (unsigned int) BPF_CORE_READ(t, state);
+}
+
+// Number of task state indices returned by task_state_index.
+#define TASK_STATE_INDICES 9
+
+/*
+ * task_state_index returns the index of the task state as it is also used in
+ * /proc/$PID/stat: 0=R(unning), 1=S(leeping), 2=D(isk sleep), 3=T(stopped),
+ * 4=t(racing stop), 5=X (dead), 6=Z(ombie), 7=P(arked), 8=I(dle).
+ *
+ * https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L1624
+ */
+unsigned int task_state_index(struct task_struct *task)
+{
+    unsigned int tsk_state = task_state(task);
+    unsigned int state = (tsk_state | BPF_CORE_READ(task, exit_state)) & TASK_REPORT;
+
+    if ((tsk_state & TASK_IDLE) == TASK_IDLE) {
+        state = TASK_REPORT_IDLE;
+    }
+    if (tsk_state & TASK_RTLOCK_WAIT) {
+        state = TASK_UNINTERRUPTIBLE;
+    }
+    // fls(state) without a loop, as there are only a few bits to consider.
+    if (state & TASK_REPORT_IDLE) return 8;
+    if (state & TASK_PARKED) return 7;
+    if (state & EXIT_ZOMBIE) return 6;
+    if (state & EXIT_DEAD) return 5;
+    if (state & __TASK_TRACED) return 4;
+    if (state & __TASK_STOPPED) return 3;
+    if (state & TASK_UNINTERRUPTIBLE) return 2;
+    if (state & TASK_INTERRUPTIBLE) return 1;
+    return 0;
+}
+
+#endif
@@ -8,9 +8,66 @@
 // - https://elixir.bootlin.com/linux/v6.12/source/include/uapi/asm-generic/posix_types.h#L28
 typedef int pid_t;
 
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/types.h#L171
+typedef struct {
+    int counter;
+} atomic_t;
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/ns_common.h#L9
+struct ns_common {
+    unsigned int inum;
+} __attribute__((preserve_access_index));
+
 // https://elixir.bootlin.com/linux/v6.14.4/source/include/linux/pid_namespace.h#L26
 struct pid_namespace {
     unsigned int level;
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/utsname.h#L23
+struct uts_namespace {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/ipc_namespace.h#L31
+struct ipc_namespace {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/fs/mount.h#L8
+struct mnt_namespace {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/net_namespace.h#L61
+struct net {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/time_namespace.h#L19
+struct time_namespace {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/cgroup.h#L842
+struct cgroup_namespace {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/user_namespace.h#L75
+struct user_namespace {
+    struct ns_common ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/nsproxy.h#L32
+struct nsproxy {
+    struct uts_namespace *uts_ns;
+    struct ipc_namespace *ipc_ns;
+    struct mnt_namespace *mnt_ns;
+    struct pid_namespace *pid_ns_for_children;
+    struct net *net_ns;
+    struct time_namespace *time_ns;
+    struct cgroup_namespace *cgroup_ns;
 } __attribute__((preserve_access_index));
 
 // https://elixir.bootlin.com/linux/v6.14.4/source/include/linux/pid.h#L50
@@ -30,9 +87,79 @@ struct pid {
 
 // https://elixir.bootlin.com/linux/v6.14.5/source/include/linux/sched.h#L1695
 #define PF_KTHREAD 0x00200000 /* I am a kernel thread */
+#define PF_WQ_WORKER 0x00000020 /* I'm a workqueue worker */
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/mm_types.h#L779
+struct mm_struct {
+    unsigned long arg_start;
+    unsigned long arg_end;
+    unsigned long env_start;
+    unsigned long env_end;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/pid_types.h#L5
+enum pid_type {
+    PIDTYPE_PID,
+    PIDTYPE_TGID,
+    PIDTYPE_PGID,
+    PIDTYPE_SID,
+    PIDTYPE_MAX,
+};
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/tty_driver.h#L505
+struct tty_driver {
+    int major;
+    int minor_start;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/tty.h#L195
+struct tty_struct {
+    struct tty_driver *driver;
+    int index;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched/signal.h#L93
+struct signal_struct {
+    atomic_t live; // number of live threads
+
+    unsigned int is_child_subreaper:1;
+    unsigned int has_child_subreaper:1;
+
+    struct pid *pids[PIDTYPE_MAX];
+
+    struct tty_struct *tty; // NULL if no tty
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/uidgid_types.h#L7
+typedef struct {
+    __u32 val;
+} kuid_t;
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/cred.h#L111
+struct cred {
+    kuid_t uid;
+    kuid_t euid;
+    struct user_namespace *user_ns;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L547
+struct sched_entity {
+    __u64 exec_start;
+    __u64 sum_exec_runtime;
+    __u64 prev_sum_exec_runtime; // sum_exec_runtime when last put on a CPU
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L324
+struct sched_info {
+    unsigned long long last_arrival; // rq clock when last run on a CPU
+} __attribute__((preserve_access_index));
 
 // https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L778
 struct task_struct {
+    unsigned int __state;
+    int exit_state;
+    int exit_code;
+
     pid_t pid;
     pid_t tgid;
     
@@ -44,10 +171,41 @@ struct task_struct {
 
     unsigned int flags;
     void *worker_private;
+
+    struct mm_struct *mm;
+
+    struct signal_struct *signal;
+    struct sched_entity se;
+    struct sched_info sched_info; // only with CONFIG_SCHED_INFO
+    __u64 start_time;
+
+    struct nsproxy *nsproxy; // NULL for exited tasks
+    const struct cred *real_cred;
+    struct css_set *cgroups; // see cgroup.h
+
+    kuid_t loginuid;        // only with CONFIG_AUDIT
+    unsigned int sessionid; // only with CONFIG_AUDIT
+} __attribute__((preserve_access_index));
+
+// Before Linux 5.14, the task state was called "state" instead of "__state",
+// see also:
+// https://github.com/torvalds/linux/commit/2f064a59a11ff9bc22e52e9678bc601404c7cb34
+struct task_struct___pre514 {
+    long state;
 } __attribute__((preserve_access_index));
 
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/kthread.c#L41
+enum KTHREAD_BITS {
+    KTHREAD_IS_PER_CPU = 0,
+    KTHREAD_SHOULD_STOP,
+    KTHREAD_SHOULD_PARK,
+};
+
 // https://elixir.bootlin.com/linux/v6.12/source/kernel/kthread.c#L53
 struct kthread {
+    unsigned long flags;
+    unsigned int cpu;
+    void *data;
     char *full_name;
 } __attribute__((preserve_access_index));
 
@@ -55,6 +213,10 @@ struct kthread {
 // https://elixir.bootlin.com/linux/v6.14.5/source/fs/proc/array.c#L99
 #define TASKFULLNAMELEN 64
 
+// https://elixir.bootlin.com/linux/v6.12/source/tools/sched_ext/include/scx/common.bpf.h#L329
+extern void bpf_rcu_read_lock(void) __ksym;
+extern void bpf_rcu_read_unlock(void) __ksym;
+
 extern struct task_struct *bpf_task_acquire(struct task_struct *p) __ksym;
 extern void bpf_task_release(struct task_struct *p) __ksym;
 
@@ -0,0 +1,69 @@
+#ifndef __BEESY_USERMEM_H
+#define __BEESY_USERMEM_H
+
+#include "iter.h"
+
+// Size of the stack buffer used to shovel user memory of a task into a
+// seq_file; we must stay well within the 512 byte eBPF stack limit, so callers
+// must not keep large records on their stacks, see task_status_scratch.
+#define USERMEM_CHUNK_SIZE 256
+
+// Upper limit of the user memory in bytes that can be copied per call to
+// seq_write_usermem; this caps the loop iterations, so the verifier is happy.
+// Additionally, all output for a single iterator element must fit into the
+// seq_file buffer of 8 pages (32 KiB with 4 KiB pages), as the kernel otherwise
+// fails the whole iteration with E2BIG. So a task's command line and
+// environment together with its status and kernel stack must stay well below
+// this limit. See also MaxUsermemLen in options.go.
+#define USERMEM_MAX_LEN (8*1024)
+
+/*
+ * usermem_len returns the number of bytes in the user memory range [start,end)
+ * of a task, capped at maxlen as well as at USERMEM_MAX_LEN.
+ */
+__u32 usermem_len(unsigned long start, unsigned long end, __u32 maxlen)
+{
+    if (start == 0 || end <= start) {
+        return 0;
+    }
+    unsigned long len = end - start;
+    if (len > maxlen) {
+        len = maxlen;
+    }
+    if (len > USERMEM_MAX_LEN) {
+        len = USERMEM_MAX_LEN;
+    }
+    return len;
+}
+
+/*
+ * seq_write_usermem writes exactly len bytes of the user memory of *task
+ * starting at the user-space address start to the seq_file *m. Any user memory
+ * that cannot be copied gets written as zero bytes instead, so that user space
+ * can always rely on the announced data length.
+ *
+ * seq_write_usermem must only be called from sleepable eBPF programs.
+ */
+void seq_write_usermem(struct seq_file *m, struct task_struct *task,
+                       unsigned long start, __u32 len)
+{
+    char chunk[USERMEM_CHUNK_SIZE];
+
+    for (int i = 0; i < USERMEM_MAX_LEN / USERMEM_CHUNK_SIZE; i++) {
+        if (len == 0) {
+            return;
+        }
+        __u32 n = len;
+        if (n > USERMEM_CHUNK_SIZE) {
+            n = USERMEM_CHUNK_SIZE;
+        }
+        // in case of failure, bpf_copy_from_user_task zeros the destination
+        // buffer, so we don't need to care here.
+        bpf_copy_from_user_task(chunk, n, (void *) start, task, 0);
+        bpf_seq_write(m, chunk, n);
+        start += n;
+        len -= n;
+    }
+}
+
+#endif
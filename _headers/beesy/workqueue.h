@@ -0,0 +1,35 @@
+#ifndef __BEESY_WORKQUEUE_H
+#define __BEESY_WORKQUEUE_H
+
+#include "task.h"
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/workqueue.h#L107
+#define WQ_NAME_LEN 32
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/workqueue_internal.h#L17
+#define WORKER_DESC_LEN 32
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/workqueue.c#L339
+struct workqueue_struct {
+    char name[WQ_NAME_LEN];
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/workqueue.c#L255
+struct pool_workqueue {
+    struct workqueue_struct *wq;
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/workqueue.c#L181
+struct worker_pool {
+    int cpu;  // the associated CPU, or -1 if unbound
+    int node; // the associated NUMA node
+} __attribute__((preserve_access_index));
+
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/workqueue_internal.h#L24
+struct worker {
+    struct pool_workqueue *current_pwq;
+    struct worker_pool *pool;
+    char desc[WORKER_DESC_LEN];
+} __attribute__((preserve_access_index));
+
+#endif
@@ -0,0 +1,245 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package beesy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/tasks"
+)
+
+// Summary of the tasks counted by a [TaskAggregator].
+type Summary struct {
+	Tasks     int                 // total number of tasks.
+	States    [tasks.Idle + 1]int // number of tasks per state.
+	Kthreads  int                 // number of kernel threads.
+	UserTasks int                 // number of user tasks.
+	Threads   map[int32]int       // number of threads per process, indexed by PID.
+	Cgroups   map[uint64]int      // number of tasks per cgroup, indexed by cgroup ID.
+	Dropped   int                 // number of per-process and per-cgroup counts lost.
+}
+
+// Processes returns the number of processes.
+func (s Summary) Processes() int {
+	return len(s.Threads)
+}
+
+// TaskAggregator counts tasks in-kernel without transferring individual task
+// records to user space, such as for periodically scraping task metrics.
+type TaskAggregator struct {
+	mu             sync.Mutex // serializes iterations using the same counter maps.
+	prog           *ebpf.Program
+	counts         *ebpf.Map
+	processThreads *ebpf.Map
+	cgroupTasks    *ebpf.Map
+	taskIter       *link.Iter
+}
+
+// aggregation maps, see count_tasks in taskiter.bpf.c.
+var aggregationMaps = []string{"counts", "process_threads", "cgroup_tasks"}
+
+// deleteAggregation removes the aggregation maps from the specified eBPF
+// collection specification.
+func deleteAggregation(spec *ebpf.CollectionSpec) {
+	for _, name := range aggregationMaps {
+		delete(spec.Maps, name)
+	}
+}
+
+// NewTaskAggregator returns a new TaskAggregator, configured using the
+// specified options. Only the task filter options are applicable, such as
+// [WithCgroupID] or [WithKthreadsOnly], other options are ignored. Use
+// [TaskAggregator.Summary] to count the tasks and [TaskAggregator.Close] to
+// release the TaskAggregator's resources when done.
+func NewTaskAggregator(opts ...Option) (*TaskAggregator, error) {
+	o := newOptions(opts...)
+	spec, err := loadSpec(&o)
+	if err != nil {
+		return nil, err
+	}
+	for name := range spec.Programs {
+		if name != "count_tasks" {
+			delete(spec.Programs, name)
+		}
+	}
+	for _, name := range []string{"kstack_scratch", "beesy_stream_sessions"} {
+		delete(spec.Maps, name)
+	}
+	coll, err := ebpf.NewCollection(spec)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load task aggregator eBPF objects, reason: %w", err)
+	}
+	defer coll.Close()
+
+	ta := &TaskAggregator{
+		prog:           coll.DetachProgram("count_tasks"),
+		counts:         coll.DetachMap("counts"),
+		processThreads: coll.DetachMap("process_threads"),
+		cgroupTasks:    coll.DetachMap("cgroup_tasks"),
+	}
+	if ta.taskIter, err = link.AttachIter(link.IterOptions{
+		Program: ta.prog,
+	}); err != nil {
+		ta.Close()
+		return nil, fmt.Errorf("cannot attach task aggregator, reason: %w", err)
+	}
+	return ta, nil
+}
+
+// Close releases all resources associated with this TaskAggregator.
+func (ta *TaskAggregator) Close() {
+	if ta.taskIter != nil {
+		ta.taskIter.Close()
+	}
+	for _, m := range []*ebpf.Map{ta.counts, ta.processThreads, ta.cgroupTasks} {
+		if m != nil {
+			m.Close()
+		}
+	}
+	if ta.prog != nil {
+		ta.prog.Close()
+	}
+}
+
+// Summary counts the tasks visible to the caller in-kernel and returns the
+// resulting Summary.
+func (ta *TaskAggregator) Summary() (Summary, error) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	if err := ta.reset(); err != nil {
+		return Summary{}, err
+	}
+
+	f, err := ta.taskIter.Open()
+	if err != nil {
+		return Summary{}, fmt.Errorf("cannot count tasks, reason: %w", err)
+	}
+	defer f.Close()
+	// the aggregating iterator program doesn't emit anything, but we need to
+	// drive the iteration to its end.
+	if _, err := io.Copy(io.Discard, f); err != nil {
+		return Summary{}, fmt.Errorf("cannot count tasks, reason: %w", err)
+	}
+
+	var percpu []beesyTaskCounts
+	if err := ta.counts.Lookup(uint32(0), &percpu); err != nil {
+		return Summary{}, fmt.Errorf("cannot read task counters, reason: %w", err)
+	}
+	s := newSummary(percpu)
+	if s.Threads, err = drainCounters[uint32, int32](ta.processThreads); err != nil {
+		return Summary{}, fmt.Errorf("cannot read process thread counters, reason: %w", err)
+	}
+	if s.Cgroups, err = drainCounters[uint64, uint64](ta.cgroupTasks); err != nil {
+		return Summary{}, fmt.Errorf("cannot read cgroup task counters, reason: %w", err)
+	}
+	return s, nil
+}
+
+// newSummary returns a new Summary from the per-CPU task counters, without
+// the per-process and per-cgroup counters.
+func newSummary(percpu []beesyTaskCounts) Summary {
+	var s Summary
+	for _, c := range percpu {
+		for state, n := range c.States {
+			if state < len(s.States) {
+				s.States[state] += int(n)
+			}
+		}
+		s.Kthreads += int(c.Kthreads)
+		s.UserTasks += int(c.UserTasks)
+		s.Dropped += int(c.Dropped)
+	}
+	s.Tasks = s.Kthreads + s.UserTasks
+	return s
+}
+
+// drainBatchSize is the number of counters to read and delete at once.
+const drainBatchSize = 4096
+
+// drainCounters returns the counters in the specified hash map, converting
+// the map's keys into the key type K, and removes them from the map. It reads
+// and deletes the counters in batches, falling back to reading and deleting
+// them one by one on kernels without batch support.
+func drainCounters[MK uint32 | uint64, K int32 | uint64](m *ebpf.Map) (map[K]int, error) {
+	counters := map[K]int{}
+	keys := make([]MK, drainBatchSize)
+	values := make([]uint64, drainBatchSize)
+	var cursor ebpf.MapBatchCursor
+	for {
+		n, err := m.BatchLookupAndDelete(&cursor, keys, values, nil)
+		for idx := range n {
+			counters[K(keys[idx])] = int(values[idx])
+		}
+		switch {
+		case errors.Is(err, ebpf.ErrKeyNotExist):
+			return counters, nil
+		case errors.Is(err, ebpf.ErrNotSupported):
+			return drainCountersSlowly[MK, K](m)
+		case err != nil:
+			return nil, err
+		}
+	}
+}
+
+// drainCountersSlowly returns and removes the counters in the specified hash
+// map one by one, see drainCounters.
+func drainCountersSlowly[MK uint32 | uint64, K int32 | uint64](m *ebpf.Map) (map[K]int, error) {
+	counters := map[K]int{}
+	// collect the keys first, as deleting while iterating would restart the
+	// iteration.
+	var keys []MK
+	var key MK
+	var n uint64
+	entries := m.Iterate()
+	for entries.Next(&key, &n) {
+		counters[K(key)] = int(n)
+		keys = append(keys, key)
+	}
+	if err := entries.Err(); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil, err
+		}
+	}
+	return counters, nil
+}
+
+// reset zeros all task counters. As Summary drains the per-process and
+// per-cgroup counters, these usually are already empty, unless a previous
+// Summary failed.
+func (ta *TaskAggregator) reset() error {
+	cpus, err := ebpf.PossibleCPU()
+	if err != nil {
+		return fmt.Errorf("cannot reset task counters, reason: %w", err)
+	}
+	if err := ta.counts.Put(uint32(0), make([]beesyTaskCounts, cpus)); err != nil {
+		return fmt.Errorf("cannot reset task counters, reason: %w", err)
+	}
+	if _, err := drainCounters[uint32, int32](ta.processThreads); err != nil {
+		return fmt.Errorf("cannot reset process thread counters, reason: %w", err)
+	}
+	if _, err := drainCounters[uint64, uint64](ta.cgroupTasks); err != nil {
+		return fmt.Errorf("cannot reset cgroup task counters, reason: %w", err)
+	}
+	return nil
+}
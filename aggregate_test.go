@@ -0,0 +1,100 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package beesy
+
+import (
+	"os"
+	"time"
+
+	"github.com/thediveo/beesy/tasks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("task aggregation", func() {
+
+	It("sums per-CPU counters", func() {
+		s := newSummary([]beesyTaskCounts{
+			{States: [9]uint64{tasks.Running: 1, tasks.Sleeping: 2}, UserTasks: 3},
+			{States: [9]uint64{tasks.Sleeping: 3, tasks.Idle: 4}, Kthreads: 5, UserTasks: 2, Dropped: 1},
+		})
+		Expect(s.Tasks).To(Equal(10))
+		Expect(s.Kthreads).To(Equal(5))
+		Expect(s.UserTasks).To(Equal(5))
+		Expect(s.Dropped).To(Equal(1))
+		Expect(s.States[tasks.Running]).To(Equal(1))
+		Expect(s.States[tasks.Sleeping]).To(Equal(5))
+		Expect(s.States[tasks.Idle]).To(Equal(4))
+		Expect(Summary{Threads: map[int32]int{1: 1, 42: 2}}.Processes()).To(Equal(2))
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("counts tasks", func() {
+			ta := Successful(NewTaskAggregator())
+			defer ta.Close()
+
+			s := Successful(ta.Summary())
+			Expect(s.Dropped).To(BeZero())
+			Expect(s.Kthreads).NotTo(BeZero())
+			Expect(s.UserTasks).NotTo(BeZero())
+			Expect(s.Threads).To(HaveKeyWithValue(int32(os.Getpid()), BeNumerically(">=", 1)))
+			sum := 0
+			for _, n := range s.States {
+				sum += n
+			}
+			Expect(sum).To(Equal(s.Tasks))
+			sum = 0
+			for _, n := range s.Cgroups {
+				sum += n
+			}
+			Expect(sum).To(Equal(s.Tasks))
+
+			// counters must not accumulate across summaries.
+			s2 := Successful(ta.Summary())
+			Expect(s2.Tasks).To(BeNumerically("~", s.Tasks, s.Tasks/10))
+		})
+
+		It("counts only filtered tasks", func() {
+			ta := Successful(NewTaskAggregator(WithKthreadsOnly()))
+			defer ta.Close()
+
+			s := Successful(ta.Summary())
+			Expect(s.Kthreads).NotTo(BeZero())
+			Expect(s.UserTasks).To(BeZero())
+			Expect(s.Threads).NotTo(HaveKey(int32(os.Getpid())))
+		})
+
+	})
+
+})
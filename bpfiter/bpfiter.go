@@ -0,0 +1,158 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// Iterator runs an attached eBPF iterator program, decoding its output into
+// records of type T.
+type Iterator[T any] struct {
+	prog     *ebpf.Program
+	iterLink *link.Iter
+}
+
+// LoadFile returns a new Iterator for the iterator program with the specified
+// name from the eBPF object file at path. Use [Iterator.All] or
+// [Iterator.AllVolatile] to run the iterator and [Iterator.Close] to release
+// the Iterator's resources when done.
+func LoadFile[T any](path string, progName string, opts ...Option) (*Iterator[T], error) {
+	spec, err := ebpf.LoadCollectionSpec(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load eBPF object file, reason: %w", err)
+	}
+	return Load[T](spec, progName, opts...)
+}
+
+// Load returns a new Iterator for the iterator program with the specified
+// name from the collection specification, such as returned by a bpf2go-generated
+// “load<Ident>” function. Only the specified program gets loaded, any other
+// programs in the specification are ignored. The passed specification is left
+// untouched.
+func Load[T any](spec *ebpf.CollectionSpec, progName string, opts ...Option) (*Iterator[T], error) {
+	o := newOptions(opts...)
+	if unsafe.Sizeof(*new(T)) == 0 {
+		return nil, fmt.Errorf("invalid zero-sized record type %T", *new(T))
+	}
+	if _, ok := spec.Programs[progName]; !ok {
+		return nil, fmt.Errorf("no iterator program %q", progName)
+	}
+	if o.recordType != "" {
+		if err := CheckLayout[T](spec.Types, o.recordType); err != nil {
+			return nil, err
+		}
+	}
+	it := &Iterator[T]{}
+	var err error
+	if it.prog, it.iterLink, err = load(spec, progName, &o); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// load loads and attaches the iterator program with the specified name from
+// the collection specification, as configured by the options.
+func load(spec *ebpf.CollectionSpec, progName string, o *options) (*ebpf.Program, *link.Iter, error) {
+	spec = spec.Copy()
+	for name, value := range o.variables {
+		v, ok := spec.Variables[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("no variable %q", name)
+		}
+		if err := v.Set(value); err != nil {
+			return nil, nil, fmt.Errorf("cannot set variable %s, reason: %w", name, err)
+		}
+	}
+	for name := range spec.Programs {
+		if name != progName {
+			delete(spec.Programs, name)
+		}
+	}
+	coll, err := ebpf.NewCollection(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load iterator eBPF objects, reason: %w", err)
+	}
+	defer coll.Close()
+
+	prog := coll.DetachProgram(progName)
+	var iterLink *link.Iter
+	if o.cgroupFD >= 0 {
+		iterLink, err = iteriter.AttachCgroupIter(prog, o.cgroupFD, o.cgroupOrder)
+	} else {
+		iterLink, err = link.AttachIter(link.IterOptions{
+			Program: prog,
+			Map:     o.iterMap,
+		})
+	}
+	if err != nil {
+		prog.Close()
+		return nil, nil, fmt.Errorf("cannot attach iterator, reason: %w", err)
+	}
+	return prog, iterLink, nil
+}
+
+// Close releases all resources associated with this Iterator.
+func (it *Iterator[T]) Close() {
+	if it.iterLink != nil {
+		it.iterLink.Close()
+		it.iterLink = nil
+	}
+	if it.prog != nil {
+		it.prog.Close()
+		it.prog = nil
+	}
+}
+
+// Open runs the iterator program, returning a buffered reader for the raw
+// output of the program. Use Open for iterator programs emitting framed
+// streams in combination with [Frames] or [Decoder.Decode]. The caller must
+// close the returned reader when done. Open makes an Iterator a [Source], so
+// its output can be captured using a [Recorder].
+func (it *Iterator[T]) Open() (io.ReadCloser, error) {
+	f, err := it.iterLink.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot run iterator, reason: %w", err)
+	}
+	return &bufferedReadCloser{Reader: iteriter.NewReader(f), Closer: f}, nil
+}
+
+// bufferedReadCloser reads buffered from an underlying io.ReadCloser.
+type bufferedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// All runs the iterator program and returns an iterator over the records
+// emitted. In case of an iterator failure, the iterator will return a zero
+// record together with an error and then end the sequence. See also
+// [Records].
+func (it *Iterator[T]) All() iter.Seq2[T, error] {
+	return RecordsFrom[T](it.iterLink)
+}
+
+// AllVolatile runs the iterator program and returns an iterator over
+// references to the records emitted, with the references only valid within
+// the caller's iteration body. See also [RecordsVolatile].
+func (it *Iterator[T]) AllVolatile() iter.Seq2[*T, error] {
+	return RecordsVolatileFrom[T](it.iterLink)
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// taskIterSpec returns a collection specification with an “iter/task”
+// program emitting a single uint64 record with the value 42 for each task.
+func taskIterSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"dump_42s": {
+				Type:       ebpf.Tracing,
+				AttachType: ebpf.AttachTraceIter,
+				AttachTo:   "task",
+				License:    "GPL",
+				Instructions: asm.Instructions{
+					asm.LoadMem(asm.R6, asm.R1, 0, asm.DWord), // ctx->meta
+					asm.LoadMem(asm.R7, asm.R1, 8, asm.DWord), // ctx->task
+					asm.JEq.Imm(asm.R7, 0, "exit"),
+					asm.LoadMem(asm.R1, asm.R6, 0, asm.DWord), // meta->seq
+					asm.StoreImm(asm.RFP, -8, 42, asm.DWord),
+					asm.Mov.Reg(asm.R2, asm.RFP),
+					asm.Add.Imm(asm.R2, -8),
+					asm.Mov.Imm(asm.R3, 8),
+					asm.FnSeqWrite.Call(),
+					asm.Mov.Imm(asm.R0, 0).WithSymbol("exit"),
+					asm.Return(),
+				},
+			},
+		},
+	}
+}
+
+// cgroupIterSpec returns a collection specification with an “iter/cgroup”
+// program not emitting anything.
+func cgroupIterSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"dump_nothing": {
+				Type:       ebpf.Tracing,
+				AttachType: ebpf.AttachTraceIter,
+				AttachTo:   "cgroup",
+				License:    "GPL",
+				Instructions: asm.Instructions{
+					asm.Mov.Imm(asm.R0, 0),
+					asm.Return(),
+				},
+			},
+		},
+	}
+}
+
+// btfSpec returns BTF information describing the specified types.
+func btfSpec(types ...btf.Type) *btf.Spec {
+	b := Successful(btf.NewBuilder(types))
+	return Successful(btf.LoadSpecFromReader(bytes.NewReader(Successful(b.Marshal(nil, nil)))))
+}
+
+var _ = Describe("iterators", func() {
+
+	It("rejects invalid configurations", func() {
+		Expect(LoadFile[record]("./nada.bpf.o", "dump_42s")).Error().To(
+			MatchError(ContainSubstring("cannot load eBPF object file")))
+		Expect(Load[struct{}](taskIterSpec(), "dump_42s")).Error().To(
+			MatchError(ContainSubstring("invalid zero-sized record type")))
+		Expect(Load[uint64](taskIterSpec(), "dump_foos")).Error().To(
+			MatchError(`no iterator program "dump_foos"`))
+		Expect(Load[uint64](taskIterSpec(), "dump_42s", WithVariable("foo", uint32(0)))).Error().To(
+			MatchError(`no variable "foo"`))
+		Expect(Load[uint64](taskIterSpec(), "dump_42s", WithRecordType("foo_info"))).Error().To(
+			MatchError(ContainSubstring("no BTF information")))
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("runs an iterator program", func() {
+			it := Successful(Load[uint64](taskIterSpec(), "dump_42s"))
+			defer it.Close()
+
+			count := 0
+			for v, err := range it.All() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(v).To(Equal(uint64(42)))
+				count++
+			}
+			Expect(count).To(BeNumerically(">", 1))
+
+			volatileCount := 0
+			for v, err := range it.AllVolatile() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(*v).To(Equal(uint64(42)))
+				volatileCount++
+			}
+			Expect(volatileCount).To(BeNumerically("~", count, 50))
+		})
+
+		It("reads raw iterator output", func() {
+			it := Successful(Load[uint64](taskIterSpec(), "dump_42s"))
+			defer it.Close()
+			r := Successful(it.Open())
+			defer r.Close()
+			b := Successful(io.ReadAll(r))
+			Expect(b).NotTo(BeEmpty())
+			Expect(len(b) % 8).To(BeZero())
+		})
+
+		It("reports truncated records", func() {
+			it := Successful(Load[[3]uint64](taskIterSpec(), "dump_42s"))
+			defer it.Close()
+			var err error
+			for _, err = range it.All() {
+			}
+			Expect(err).To(Or(Not(HaveOccurred()), MatchError(ErrTruncatedRecord)))
+		})
+
+		It("runs a cgroup iterator program", func() {
+			var fsstat unix.Statfs_t
+			if unix.Statfs("/sys/fs/cgroup", &fsstat) != nil || fsstat.Type != unix.CGROUP2_SUPER_MAGIC {
+				Skip("needs unified cgroup hierarchy")
+			}
+			fd := Successful(unix.Open("/sys/fs/cgroup", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0))
+			defer unix.Close(fd)
+			it := Successful(Load[uint64](cgroupIterSpec(), "dump_nothing", WithCgroup(fd, CgroupSelfOnly)))
+			defer it.Close()
+			for range it.All() {
+				Fail("unexpected record")
+			}
+		})
+
+		It("reports attach errors", func() {
+			Expect(Load[uint64](cgroupIterSpec(), "dump_nothing", WithCgroup(-2, CgroupSelfOnly))).Error().To(
+				MatchError(ContainSubstring("cannot attach iterator")))
+		})
+
+	})
+
+})
@@ -0,0 +1,83 @@
+/*
+Package bpfiter runs user-supplied eBPF iterator programs, such as “iter/task”
+programs, and decodes their output into fixed-size Go records.
+
+Package bpfiter takes care of the usual plumbing: loading only the requested
+iterator program from a (CO-RE) collection specification or object file,
+configuring its “const volatile” variables, attaching it, and decoding the
+emitted records. The records must be written by the iterator program in one
+piece per element using “bpf_seq_write” and must exactly match the memory
+layout of the Go record type.
+
+	type taskInfo struct {
+		PID  int32
+		TGID int32
+	}
+
+	it, err := bpfiter.LoadFile[taskInfo]("query.bpf.o", "dump_tasks",
+		bpfiter.WithRecordType("task_info"))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for info, err := range it.All() {
+		if err != nil {
+			return err
+		}
+		fmt.Println(info.PID, info.TGID)
+	}
+
+Use [WithRecordType] to have the record layout checked against the layout of
+the record's C type as described by the object's BTF information when loading,
+catching Go and C struct layouts that got out of sync. Independent of this,
+reading a truncated record fails with [ErrTruncatedRecord].
+
+# Dynamic Decoding
+
+Instead of decoding records into Go types matching the record layout exactly,
+[LoadDynamic] decodes records based on the BTF information of the record's C
+type embedded in the eBPF object. [DynamicIterator.All] yields generic field
+maps, while [AllInto] fills user-supplied structs with fields matched by name.
+Newer eBPF objects thus can add record fields without breaking existing Go
+consumers, and structs incompatible with the record layout get rejected before
+running the iterator program.
+
+	it, err := bpfiter.LoadDynamic(spec, "dump_tasks", "task_info")
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for info, err := range bpfiter.AllInto[taskInfo](it) {
+		...
+	}
+
+# Framed Streams
+
+Iterator programs emitting variable-length data, or different kinds of
+records in a single iteration pass, can instead emit a self-describing framed
+stream using emit_stream_header and emit_frame from _headers/beesy/frame.h. A
+framed stream starts with a versioned stream header, followed by frames each
+consisting of a frame type, payload length, and payload. Load such programs as
+Iterator[[Frame]] and then use [Iterator.Open] together with [Frames], or with
+a [Decoder] dispatching frames to handlers based on their types.
+
+	d := bpfiter.NewDecoder()
+	bpfiter.HandleRecord(d, frameTask, func(t taskInfo) error { ... })
+	d.Handle(frameCmdline, func(f bpfiter.Frame) error { ... })
+	r, err := it.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	err = d.Decode(r)
+
+# Sources, Recording, and Replay
+
+Decoding works on any [Source] of raw iterator output, not only on live
+iterators: a [Recorder] captures the raw output of a Source to a file, and a
+[Replayer] later feeds it back, such as for offline analysis or for testing
+decoders without privileges and a live kernel. [RecordsFrom] iterates over
+the records of any Source and [NewDynamicFromSource] decodes them dynamically;
+the beesy task iterator and PID horizon similarly accept Sources.
+*/
+package bpfiter
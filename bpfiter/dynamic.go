@@ -0,0 +1,192 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// DynamicIterator runs an attached eBPF iterator program, decoding its output
+// dynamically based on the BTF information of the record type, see also
+// [Layout]. Alternatively, a DynamicIterator decodes the raw iterator output
+// from a [Source], see [NewDynamicFromSource].
+type DynamicIterator struct {
+	prog     *ebpf.Program
+	iterLink *link.Iter
+	source   Source
+	layout   *Layout
+}
+
+// LoadDynamic returns a new DynamicIterator for the iterator program with the
+// specified name from the collection specification, decoding the records
+// emitted by the program based on the BTF information of the named record
+// type. Use [DynamicIterator.All] or [AllInto] to run the iterator and
+// [DynamicIterator.Close] to release the DynamicIterator's resources when
+// done.
+//
+// Any [WithRecordType] option is ignored, as the record type is already
+// specified.
+func LoadDynamic(spec *ebpf.CollectionSpec, progName string, recordType string, opts ...Option) (*DynamicIterator, error) {
+	o := newOptions(opts...)
+	if _, ok := spec.Programs[progName]; !ok {
+		return nil, fmt.Errorf("no iterator program %q", progName)
+	}
+	layout, err := NewLayout(spec.Types, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if layout.Size() == 0 {
+		return nil, fmt.Errorf("invalid zero-sized record type %s", recordType)
+	}
+	it := &DynamicIterator{layout: layout}
+	if it.prog, it.iterLink, err = load(spec, progName, &o); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// NewDynamicFromSource returns a new DynamicIterator decoding the records of
+// the specified layout from the raw iterator output of the specified source
+// instead of running an eBPF iterator program, such as replaying a recording
+// made with a [Recorder] from a DynamicIterator using a [Replayer].
+func NewDynamicFromSource(src Source, layout *Layout) (*DynamicIterator, error) {
+	if layout.Size() == 0 {
+		return nil, fmt.Errorf("invalid zero-sized record type %s", layout.Name())
+	}
+	return &DynamicIterator{source: src, layout: layout}, nil
+}
+
+// Layout returns the record layout of this DynamicIterator.
+func (it *DynamicIterator) Layout() *Layout {
+	return it.layout
+}
+
+// Close releases all resources associated with this DynamicIterator.
+func (it *DynamicIterator) Close() {
+	if it.iterLink != nil {
+		it.iterLink.Close()
+		it.iterLink = nil
+	}
+	if it.prog != nil {
+		it.prog.Close()
+		it.prog = nil
+	}
+}
+
+// Open runs the iterator program, returning a buffered reader for the raw
+// output of the program. The caller must close the returned reader when done.
+// Open makes a DynamicIterator a [Source], so its output can be captured
+// using a [Recorder]. For a DynamicIterator created by [NewDynamicFromSource],
+// Open starts a new iteration of its source instead.
+func (it *DynamicIterator) Open() (io.ReadCloser, error) {
+	if it.source != nil {
+		r, err := it.source.Open()
+		if err != nil {
+			return nil, fmt.Errorf("cannot run iterator, reason: %w", err)
+		}
+		return r, nil
+	}
+	f, err := it.iterLink.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot run iterator, reason: %w", err)
+	}
+	return &bufferedReadCloser{Reader: iteriter.NewReader(f), Closer: f}, nil
+}
+
+// Source returns the source of the raw iterator output of this
+// DynamicIterator.
+func (it *DynamicIterator) Source() Source {
+	if it.source != nil {
+		return it.source
+	}
+	return it
+}
+
+// All runs the iterator program and returns an iterator over the records
+// emitted, decoded into field maps, see also [Layout.Decode]. In case of an
+// iterator failure, the iterator will return a nil map together with an error
+// and then end the sequence.
+func (it *DynamicIterator) All() iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		it.records(func(record []byte, err error) bool {
+			if err != nil {
+				return yield(nil, err)
+			}
+			values, err := it.layout.Decode(record)
+			return yield(values, err) && err == nil
+		})
+	}
+}
+
+// AllInto runs the iterator program of the specified DynamicIterator and
+// returns an iterator over the records emitted, unmarshalled into values of
+// the struct type T, see also [Layout.Unmarshal]. If T is incompatible with
+// the record layout, the iterator returns a zero T together with an error
+// without running the iterator program.
+func AllInto[T any](it *DynamicIterator) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if reflect.TypeFor[T]().Kind() != reflect.Struct {
+			yield(zero, fmt.Errorf("cannot unmarshal %s into %T, reason: not a struct", it.layout.name, zero))
+			return
+		}
+		if err := it.layout.Compatible(&zero); err != nil {
+			yield(zero, err)
+			return
+		}
+		it.records(func(record []byte, err error) bool {
+			var v T
+			if err == nil {
+				err = it.layout.Unmarshal(record, &v)
+			}
+			return yield(v, err) && err == nil
+		})
+	}
+}
+
+// records starts a new iteration and yields the raw records. The records are
+// only valid within yield.
+func (it *DynamicIterator) records(yield func([]byte, error) bool) {
+	r, err := it.Open()
+	if err != nil {
+		yield(nil, err)
+		return
+	}
+	defer r.Close()
+	record := make([]byte, it.layout.size)
+	for {
+		n, err := io.ReadFull(r, record)
+		switch {
+		case err == io.EOF:
+			return
+		case err == io.ErrUnexpectedEOF:
+			yield(nil, fmt.Errorf("%w, got only %d out of %d bytes", ErrTruncatedRecord, n, len(record)))
+			return
+		case err != nil:
+			yield(nil, fmt.Errorf("cannot read record, reason: %w", err))
+			return
+		}
+		if !yield(record, nil) {
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"os"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// dynamicTaskIterSpec returns the taskIterSpec together with BTF information
+// describing the records emitted.
+func dynamicTaskIterSpec() *ebpf.CollectionSpec {
+	spec := taskIterSpec()
+	spec.Types = btfSpec(
+		&btf.Struct{Name: "answer", Size: 8, Members: []btf.Member{
+			{Name: "value", Type: u64},
+		}},
+		&btf.Struct{Name: "empty"},
+		&btf.Struct{Name: "large_answer", Size: 24},
+	)
+	return spec
+}
+
+var _ = Describe("dynamic iterators", func() {
+
+	It("rejects invalid configurations", func() {
+		Expect(LoadDynamic(dynamicTaskIterSpec(), "dump_foos", "answer")).Error().To(
+			MatchError(`no iterator program "dump_foos"`))
+		Expect(LoadDynamic(dynamicTaskIterSpec(), "dump_42s", "question")).Error().To(
+			MatchError(ContainSubstring("cannot determine layout of question")))
+		Expect(LoadDynamic(dynamicTaskIterSpec(), "dump_42s", "empty")).Error().To(
+			MatchError(ContainSubstring("invalid zero-sized record type")))
+	})
+
+	It("decodes records dynamically from sources", func() {
+		layout := Successful(NewLayout(dynamicTaskIterSpec().Types, "answer"))
+		src := BytesSource{42, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+		it := Successful(NewDynamicFromSource(src, layout))
+		defer it.Close()
+		Expect(it.Source()).To(Equal(Source(src)))
+
+		var values []map[string]any
+		for v, err := range it.All() {
+			Expect(err).NotTo(HaveOccurred())
+			values = append(values, v)
+		}
+		Expect(values).To(HaveExactElements(
+			map[string]any{"value": uint64(42)},
+			map[string]any{"value": uint64(1)}))
+
+		type answer struct{ Value uint64 }
+		var answers []answer
+		for a, err := range AllInto[answer](it) {
+			Expect(err).NotTo(HaveOccurred())
+			answers = append(answers, a)
+		}
+		Expect(answers).To(HaveExactElements(answer{42}, answer{1}))
+
+		it = Successful(NewDynamicFromSource(src[:12], layout))
+		var errs []error
+		for _, err := range it.All() {
+			errs = append(errs, err)
+		}
+		Expect(errs).To(HaveExactElements(BeNil(), MatchError(ErrTruncatedRecord)))
+	})
+
+	It("rejects invalid sources", func() {
+		Expect(NewDynamicFromSource(BytesSource{}, Successful(NewLayout(dynamicTaskIterSpec().Types, "empty")))).Error().To(
+			MatchError(ContainSubstring("invalid zero-sized record type")))
+
+		it := Successful(NewDynamicFromSource(failingSource{}, Successful(NewLayout(dynamicTaskIterSpec().Types, "answer"))))
+		for _, err := range it.All() {
+			Expect(err).To(MatchError(ContainSubstring("cannot run iterator, reason: D'OH!")))
+		}
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("decodes records dynamically", func() {
+			it := Successful(LoadDynamic(dynamicTaskIterSpec(), "dump_42s", "answer"))
+			defer it.Close()
+			Expect(it.Layout().Name()).To(Equal("answer"))
+			Expect(it.Source()).To(BeIdenticalTo(it))
+
+			count := 0
+			for values, err := range it.All() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(values).To(Equal(map[string]any{"value": uint64(42)}))
+				count++
+			}
+			Expect(count).To(BeNumerically(">", 1))
+
+			type answer struct {
+				Value int
+			}
+			count = 0
+			for a, err := range AllInto[answer](it) {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(a.Value).To(Equal(42))
+				count++
+			}
+			Expect(count).To(BeNumerically(">", 1))
+		})
+
+		It("rejects incompatible types", func() {
+			it := Successful(LoadDynamic(dynamicTaskIterSpec(), "dump_42s", "answer"))
+			defer it.Close()
+			for _, err := range AllInto[struct{ Value string }](it) {
+				Expect(err).To(MatchError(ContainSubstring("incompatible type")))
+			}
+			for _, err := range AllInto[int](it) {
+				Expect(err).To(MatchError(ContainSubstring("not a struct")))
+			}
+		})
+
+		It("reports truncated records", func() {
+			it := Successful(LoadDynamic(dynamicTaskIterSpec(), "dump_42s", "large_answer"))
+			defer it.Close()
+			var err error
+			for _, err = range it.All() {
+			}
+			Expect(err).To(Or(Not(HaveOccurred()), MatchError(ErrTruncatedRecord)))
+		})
+
+	})
+
+})
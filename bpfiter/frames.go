@@ -0,0 +1,227 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"unsafe"
+)
+
+// Stream framing parameters, see also BEESY_xxx in _headers/beesy/frame.h.
+const (
+	StreamMagic   = 0x59534542 // “BESY” in little endian.
+	StreamVersion = 1          // maximum supported stream version.
+	MaxFrameLen   = 16 * 1024  // maximum frame payload length.
+)
+
+// ErrInvalidStream signals a framed stream with an invalid or unsupported
+// stream header.
+var ErrInvalidStream = errors.New("invalid stream")
+
+// streamHeader is the binary representation of the header of a framed
+// stream, see struct beesy_stream_header.
+type streamHeader struct {
+	Magic   uint32
+	Version uint16
+	_       uint16
+}
+
+// frameHeader is the binary representation of the header of a single frame,
+// see struct beesy_frame_header.
+type frameHeader struct {
+	Type uint16
+	_    uint16
+	Len  uint32
+}
+
+// Frame is a single typed frame of a framed stream emitted by an iterator
+// program using emit_stream_header and emit_frame from
+// _headers/beesy/frame.h.
+type Frame struct {
+	Type    uint16 // program-specific frame type.
+	Payload []byte // frame payload.
+}
+
+// Frames returns an iterator over the frames of the framed stream read from
+// r. In case of a read failure, an invalid stream header, or a truncated
+// frame, the iterator will return a zero Frame together with an error and
+// then end the sequence. An empty stream without even a stream header is
+// valid and results in an empty sequence, as iterator programs emit the
+// stream header only together with their first element.
+//
+// Frame payloads are limited to [MaxFrameLen] bytes: all output of an
+// iterator program for a single element must fit into the iterator's seq_file
+// buffer of 8 pages, that is, 32 KiB with 4 KiB pages, as the kernel otherwise
+// fails the whole iteration with E2BIG. The limit thus leaves room for the
+// stream and frame headers as well as further frames of the same element.
+//
+// Frames returns frames with their own payload buffers; please see
+// [FramesVolatile] for an optimized version reusing the payload buffer.
+func Frames(r io.Reader) iter.Seq2[Frame, error] {
+	return func(yield func(Frame, error) bool) {
+		frames(r, false, yield)
+	}
+}
+
+// FramesVolatile returns an iterator over the frames of the framed stream
+// read from r, see also [Frames]. The payload of a yielded Frame is only
+// valid within the caller's iteration body. If an iteration body needs to
+// keep a payload for longer, it must copy it itself.
+func FramesVolatile(r io.Reader) iter.Seq2[Frame, error] {
+	return func(yield func(Frame, error) bool) {
+		frames(r, true, yield)
+	}
+}
+
+// frames yields the frames read from r, reusing the payload buffer if
+// volatile.
+func frames(r io.Reader, volatile bool, yield func(Frame, error) bool) {
+	if err := readStreamHeader(r); err != nil {
+		if err != io.EOF {
+			yield(Frame{}, err)
+		}
+		return
+	}
+	var buf []byte
+	for {
+		var hdr frameHeader
+		_, err := io.ReadFull(r, unsafe.Slice((*byte)(unsafe.Pointer(&hdr)), unsafe.Sizeof(hdr)))
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			yield(Frame{}, frameError(err))
+			return
+		}
+		if hdr.Len > MaxFrameLen {
+			yield(Frame{}, fmt.Errorf("invalid frame payload length %d", hdr.Len))
+			return
+		}
+		if !volatile || cap(buf) < int(hdr.Len) {
+			buf = make([]byte, hdr.Len)
+		}
+		payload := buf[:hdr.Len]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			yield(Frame{}, frameError(err))
+			return
+		}
+		if !yield(Frame{Type: hdr.Type, Payload: payload}, nil) {
+			return
+		}
+	}
+}
+
+// readStreamHeader reads and checks the stream header from r, returning
+// io.EOF in case of an empty stream.
+func readStreamHeader(r io.Reader) error {
+	var hdr streamHeader
+	_, err := io.ReadFull(r, unsafe.Slice((*byte)(unsafe.Pointer(&hdr)), unsafe.Sizeof(hdr)))
+	switch {
+	case err == io.EOF:
+		return err
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w, truncated stream header", ErrInvalidStream)
+	case err != nil:
+		return fmt.Errorf("cannot read stream header, reason: %w", err)
+	}
+	if hdr.Magic != StreamMagic {
+		return fmt.Errorf("%w, bad magic %#08x", ErrInvalidStream, hdr.Magic)
+	}
+	if hdr.Version == 0 || hdr.Version > StreamVersion {
+		return fmt.Errorf("%w, unsupported version %d", ErrInvalidStream, hdr.Version)
+	}
+	return nil
+}
+
+// frameError returns the error to report for a failed frame read.
+func frameError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w, incomplete frame", ErrTruncatedRecord)
+	}
+	return fmt.Errorf("cannot read frame, reason: %w", err)
+}
+
+// Payload returns the payload of the specified frame decoded as a record of
+// type T. The payload must be at least as large as T; any additional payload
+// bytes are ignored, so that iterator programs can append new fields to their
+// records without breaking existing consumers.
+func Payload[T any](f Frame) (T, error) {
+	var v T
+	size := int(unsafe.Sizeof(v))
+	if len(f.Payload) < size {
+		return v, fmt.Errorf("%w, frame type %d has only %d out of %d bytes",
+			ErrTruncatedRecord, f.Type, len(f.Payload), size)
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&v)), size), f.Payload)
+	return v, nil
+}
+
+// Decoder decodes framed streams, dispatching the individual frames to the
+// handlers registered for their frame types. Frames of types without a
+// registered handler are skipped.
+type Decoder struct {
+	handlers map[uint16]func(Frame) error
+}
+
+// NewDecoder returns a new Decoder without any frame handlers registered yet.
+// Use [Decoder.Handle] and [HandleRecord] to register frame handlers.
+func NewDecoder() *Decoder {
+	return &Decoder{handlers: map[uint16]func(Frame) error{}}
+}
+
+// Handle registers the handler for frames of the specified type, replacing
+// any previously registered handler. The frame payload passed to the handler
+// is only valid during the handler call.
+func (d *Decoder) Handle(typ uint16, handler func(Frame) error) {
+	d.handlers[typ] = handler
+}
+
+// HandleRecord registers a handler for frames of the specified type, passing
+// the frame payloads decoded as records of type T to the handler, see also
+// [Payload].
+func HandleRecord[T any](d *Decoder, typ uint16, handler func(T) error) {
+	d.Handle(typ, func(f Frame) error {
+		v, err := Payload[T](f)
+		if err != nil {
+			return err
+		}
+		return handler(v)
+	})
+}
+
+// Decode decodes the framed stream read from r, passing the frames to the
+// registered handlers. Decode stops at the first error returned by a handler,
+// returning this error.
+func (d *Decoder) Decode(r io.Reader) error {
+	for f, err := range FramesVolatile(r) {
+		if err != nil {
+			return err
+		}
+		handler, ok := d.handlers[f.Type]
+		if !ok {
+			continue
+		}
+		if err := handler(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
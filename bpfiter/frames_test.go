@@ -0,0 +1,180 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing/iotest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// stream returns a framed stream consisting of the specified frames.
+func stream(frames ...Frame) []byte {
+	b := binary.NativeEndian.AppendUint32(nil, StreamMagic)
+	b = binary.NativeEndian.AppendUint16(b, StreamVersion)
+	b = binary.NativeEndian.AppendUint16(b, 0)
+	for _, f := range frames {
+		b = binary.NativeEndian.AppendUint16(b, f.Type)
+		b = binary.NativeEndian.AppendUint16(b, 0)
+		b = binary.NativeEndian.AppendUint32(b, uint32(len(f.Payload)))
+		b = append(b, f.Payload...)
+	}
+	return b
+}
+
+var _ = Describe("framed streams", func() {
+
+	frames := []Frame{
+		{Type: 1, Payload: []byte{1, 0, 0, 0, 2, 0, 3, 0}},
+		{Type: 2, Payload: []byte("/usr/bin/foo")},
+		{Type: 3, Payload: []byte{}},
+		{Type: 1, Payload: []byte{4, 0, 0, 0, 5, 0, 6, 0, 42}},
+	}
+
+	It("iterates over frames", func() {
+		var fs []Frame
+		for f, err := range Frames(iotest.HalfReader(bytes.NewReader(stream(frames...)))) {
+			Expect(err).NotTo(HaveOccurred())
+			fs = append(fs, f)
+		}
+		Expect(fs).To(Equal(frames))
+	})
+
+	It("iterates over volatile frames", func() {
+		var types []uint16
+		for f, err := range FramesVolatile(bytes.NewReader(stream(frames...))) {
+			Expect(err).NotTo(HaveOccurred())
+			types = append(types, f.Type)
+			if f.Type == 2 {
+				Expect(string(f.Payload)).To(Equal("/usr/bin/foo"))
+				break
+			}
+		}
+		Expect(types).To(Equal([]uint16{1, 2}))
+	})
+
+	It("accepts empty streams", func() {
+		for range Frames(bytes.NewReader(nil)) {
+			Fail("unexpected frame")
+		}
+		for range Frames(bytes.NewReader(stream())) {
+			Fail("unexpected frame")
+		}
+	})
+
+	DescribeTable("rejects invalid streams",
+		func(b []byte, expected any) {
+			var errs []error
+			for f, err := range Frames(bytes.NewReader(b)) {
+				if err != nil {
+					Expect(f).To(BeZero())
+					errs = append(errs, err)
+				}
+			}
+			Expect(errs).To(ConsistOf(MatchError(expected)))
+		},
+		Entry("truncated stream header", stream()[:5], ErrInvalidStream),
+		Entry("bad magic", append([]byte{0}, stream()[1:]...), ErrInvalidStream),
+		Entry("unsupported version", func() []byte {
+			b := stream()
+			b[4] = StreamVersion + 1
+			return b
+		}(), ErrInvalidStream),
+		Entry("truncated frame header", stream(frames[0])[:10], ErrTruncatedRecord),
+		Entry("truncated frame payload", func() []byte {
+			b := stream(frames[0])
+			return b[:len(b)-1]
+		}(), ErrTruncatedRecord),
+		Entry("missing frame payload", func() []byte {
+			b := stream(frames[0])
+			return b[:len(b)-len(frames[0].Payload)]
+		}(), ErrTruncatedRecord),
+		Entry("oversized frame", func() []byte {
+			b := stream(Frame{Type: 1})
+			binary.NativeEndian.PutUint32(b[12:], MaxFrameLen+1)
+			return b
+		}(), ContainSubstring("invalid frame payload length")),
+	)
+
+	It("decodes frames at the maximum payload length", func() {
+		payload := bytes.Repeat([]byte{42}, MaxFrameLen)
+		var fs []Frame
+		for f, err := range Frames(bytes.NewReader(stream(Frame{Type: 1, Payload: payload}))) {
+			Expect(err).NotTo(HaveOccurred())
+			fs = append(fs, f)
+		}
+		Expect(fs).To(HaveExactElements(Frame{Type: 1, Payload: payload}))
+	})
+
+	It("reports read errors", func() {
+		for _, err := range Frames(iotest.ErrReader(errors.New("D'OH!"))) {
+			Expect(err).To(MatchError(ContainSubstring("cannot read stream header")))
+		}
+		r := io.MultiReader(bytes.NewReader(stream()), iotest.ErrReader(errors.New("D'OH!")))
+		for _, err := range Frames(r) {
+			Expect(err).To(MatchError(ContainSubstring("cannot read frame")))
+		}
+	})
+
+	It("decodes payloads", func() {
+		Expect(Payload[record](frames[0])).To(Equal(record{1, 2, 3}))
+		Expect(Payload[record](frames[3])).To(Equal(record{4, 5, 6}))
+		Expect(Payload[record](frames[2])).Error().To(MatchError(ErrTruncatedRecord))
+	})
+
+	It("dispatches frames to handlers", func() {
+		var recs []record
+		var paths []string
+		d := NewDecoder()
+		HandleRecord(d, 1, func(r record) error {
+			recs = append(recs, r)
+			return nil
+		})
+		d.Handle(2, func(f Frame) error {
+			paths = append(paths, string(f.Payload))
+			return nil
+		})
+		Expect(d.Decode(bytes.NewReader(stream(frames...)))).To(Succeed())
+		Expect(recs).To(Equal([]record{{1, 2, 3}, {4, 5, 6}}))
+		Expect(paths).To(Equal([]string{"/usr/bin/foo"}))
+	})
+
+	It("stops decoding on errors", func() {
+		d := NewDecoder()
+		HandleRecord(d, 3, func(r record) error { return nil })
+		Expect(d.Decode(bytes.NewReader(stream(frames...)))).To(MatchError(ErrTruncatedRecord))
+
+		d = NewDecoder()
+		calls := 0
+		d.Handle(1, func(Frame) error {
+			calls++
+			return errors.New("D'OH!")
+		})
+		Expect(d.Decode(bytes.NewReader(stream(frames...)))).To(MatchError("D'OH!"))
+		Expect(calls).To(Equal(1))
+
+		Expect(NewDecoder().Decode(bytes.NewReader(stream()[:3]))).To(MatchError(ErrInvalidStream))
+	})
+
+	It("decodes empty streams", func() {
+		Expect(NewDecoder().Decode(bytes.NewReader(nil))).To(Succeed())
+	})
+
+})
@@ -0,0 +1,575 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+// Layout describes the binary layout of a record as emitted by an iterator
+// program, based on the BTF information of the record's C struct type. A
+// Layout decodes records dynamically, either into generic field maps or into
+// user-supplied Go structs with fields matched by name, so that consumers
+// don't need to be recompiled when iterator programs gain new record fields.
+//
+// Please note that Layout assumes records to be in the host's native byte
+// order, and bitfields to be laid out as on little-endian hosts.
+type Layout struct {
+	name   string
+	size   int
+	fields []field
+}
+
+// field describes a single (top-level) field of a record.
+type field struct {
+	name       string
+	offset     int // in bits
+	bitfieldSz int // in bits, or zero if not a bitfield
+	typ        btf.Type
+}
+
+// NewLayout returns the Layout of the struct type with the specified name in
+// the specified BTF information, such as the BTF information of a collection
+// specification.
+func NewLayout(types *btf.Spec, name string) (*Layout, error) {
+	if types == nil {
+		return nil, fmt.Errorf("cannot determine layout of %s, reason: no BTF information", name)
+	}
+	typ, err := types.AnyTypeByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine layout of %s, reason: %w", name, err)
+	}
+	s, ok := btf.As[*btf.Struct](typ)
+	if !ok {
+		return nil, fmt.Errorf("cannot determine layout of %s, reason: not a struct but %s", name, typ)
+	}
+	return newLayout(s), nil
+}
+
+// newLayout returns the Layout of the specified struct type.
+func newLayout(s *btf.Struct) *Layout {
+	l := &Layout{
+		name: s.Name,
+		size: int(s.Size),
+	}
+	l.fields = appendFields(nil, s.Members, 0)
+	return l
+}
+
+// appendFields appends the specified members as fields, flattening
+// anonymous struct and union members.
+func appendFields(fields []field, members []btf.Member, offset int) []field {
+	for _, m := range members {
+		if m.Name == "" {
+			switch typ := btf.UnderlyingType(m.Type).(type) {
+			case *btf.Struct:
+				fields = appendFields(fields, typ.Members, offset+int(m.Offset))
+				continue
+			case *btf.Union:
+				fields = appendFields(fields, typ.Members, offset+int(m.Offset))
+				continue
+			}
+		}
+		fields = append(fields, field{
+			name:       m.Name,
+			offset:     offset + int(m.Offset),
+			bitfieldSz: int(m.BitfieldSize),
+			typ:        btf.UnderlyingType(m.Type),
+		})
+	}
+	return fields
+}
+
+// Name returns the name of the record type.
+func (l *Layout) Name() string { return l.name }
+
+// Size returns the size of a record in bytes.
+func (l *Layout) Size() int { return l.size }
+
+// Fields returns the names of the record fields in order of their
+// definition.
+func (l *Layout) Fields() []string {
+	names := make([]string, 0, len(l.fields))
+	for _, f := range l.fields {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// Decode returns the fields of the specified record as a map of field names
+// to field values, where the values are represented as follows:
+//   - signed integers and enums as int64, unsigned integers, enums, and
+//     pointers as uint64, and booleans as bool.
+//   - floats as float32 or float64.
+//   - char arrays as strings, up to the first zero byte, and other byte
+//     arrays as []byte.
+//   - other arrays as []any.
+//   - nested structs and unions as map[string]any.
+func (l *Layout) Decode(record []byte) (map[string]any, error) {
+	if len(record) < l.size {
+		return nil, fmt.Errorf("%w, %s has only %d out of %d bytes",
+			ErrTruncatedRecord, l.name, len(record), l.size)
+	}
+	return decodeFields(l.fields, record), nil
+}
+
+// decodeFields returns the specified fields decoded from b.
+func decodeFields(fields []field, b []byte) map[string]any {
+	values := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if f.bitfieldSz != 0 {
+			values[f.name] = decodeBitfield(f, b)
+			continue
+		}
+		values[f.name] = decodeValue(f.typ, b[f.offset/8:])
+	}
+	return values
+}
+
+// decodeBitfield returns the value of the specified bitfield decoded from b.
+func decodeBitfield(f field, b []byte) any {
+	var buf [8]byte
+	copy(buf[:], b[f.offset/8:])
+	v := binary.LittleEndian.Uint64(buf[:]) >> (f.offset % 8)
+	v &= math.MaxUint64 >> (64 - f.bitfieldSz)
+	if i, ok := f.typ.(*btf.Int); ok && i.Encoding&btf.Signed != 0 {
+		shift := 64 - f.bitfieldSz
+		return int64(v<<shift) >> shift
+	}
+	return v
+}
+
+// decodeValue returns the value of the specified type decoded from b.
+func decodeValue(typ btf.Type, b []byte) any {
+	switch typ := btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		if typ.Encoding&btf.Bool != 0 {
+			return b[0] != 0
+		}
+		return decodeInt(b, int(typ.Size), typ.Encoding&btf.Signed != 0)
+	case *btf.Enum:
+		return decodeInt(b, int(typ.Size), typ.Signed)
+	case *btf.Pointer:
+		return decodeInt(b, 8, false)
+	case *btf.Float:
+		if typ.Size == 4 {
+			return math.Float32frombits(binary.NativeEndian.Uint32(b))
+		}
+		return math.Float64frombits(binary.NativeEndian.Uint64(b))
+	case *btf.Array:
+		elemSize, err := btf.Sizeof(typ.Type)
+		if err != nil {
+			return nil
+		}
+		n := int(typ.Nelems)
+		if elemSize == 1 {
+			if isChar(typ.Type) {
+				s := b[:n]
+				if idx := bytes.IndexByte(s, 0); idx >= 0 {
+					s = s[:idx]
+				}
+				return string(s)
+			}
+			if _, ok := btf.As[*btf.Int](typ.Type); ok {
+				return bytes.Clone(b[:n])
+			}
+		}
+		elems := make([]any, n)
+		for idx := range elems {
+			elems[idx] = decodeValue(typ.Type, b[idx*elemSize:])
+		}
+		return elems
+	case *btf.Struct:
+		return decodeFields(appendFields(nil, typ.Members, 0), b)
+	case *btf.Union:
+		return decodeFields(appendFields(nil, typ.Members, 0), b)
+	}
+	size, err := btf.Sizeof(typ)
+	if err != nil {
+		return nil
+	}
+	return bytes.Clone(b[:size])
+}
+
+// decodeInt returns the integer of the specified size decoded from b.
+func decodeInt(b []byte, size int, signed bool) any {
+	var u uint64
+	switch size {
+	case 1:
+		u = uint64(b[0])
+		if signed {
+			return int64(int8(u))
+		}
+	case 2:
+		u = uint64(binary.NativeEndian.Uint16(b))
+		if signed {
+			return int64(int16(u))
+		}
+	case 4:
+		u = uint64(binary.NativeEndian.Uint32(b))
+		if signed {
+			return int64(int32(u))
+		}
+	case 8:
+		u = binary.NativeEndian.Uint64(b)
+		if signed {
+			return int64(u)
+		}
+	default:
+		return bytes.Clone(b[:size])
+	}
+	return u
+}
+
+// isChar returns true if the specified type is a (signed or unsigned) char,
+// as opposed to an (u)int8_t.
+func isChar(typ btf.Type) bool {
+	i, ok := btf.As[*btf.Int](typ)
+	if !ok {
+		return false
+	}
+	return i.Encoding&btf.Char != 0 || i.Name == "char"
+}
+
+// Unmarshal decodes the specified record into the struct pointed to by v.
+// Record fields are matched to exported struct fields by their “bpf” struct
+// tag, or otherwise by their names ignoring case and underscores, such as the
+// record field “parent_id” matching the struct field “ParentID”. A struct tag
+// of “bpf:"-"” skips a struct field. Struct fields without a matching record
+// field, as well as record fields without a matching struct field are left
+// alone.
+func (l *Layout) Unmarshal(record []byte, v any) error {
+	values, err := l.Decode(record)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal %s into %T, reason: not a pointer to a struct", l.name, v)
+	}
+	if err := assignStruct(rv.Elem(), values); err != nil {
+		return fmt.Errorf("cannot unmarshal %s into %T, reason: %w", l.name, v, err)
+	}
+	return nil
+}
+
+// Compatible returns nil if records can be unmarshalled into the struct
+// pointed to by v, otherwise an error describing the first incompatible
+// field. In particular, integer struct fields must be at least as wide as
+// their record fields. Compatible leaves the struct v points to untouched.
+func (l *Layout) Compatible(v any) error {
+	typ := reflect.TypeOf(v)
+	if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal %s into %T, reason: not a pointer to a struct", l.name, v)
+	}
+	if err := assignStruct(reflect.New(typ.Elem()).Elem(), probeFields(l.fields)); err != nil {
+		return fmt.Errorf("cannot unmarshal %s into %T, reason: %w", l.name, v, err)
+	}
+	return nil
+}
+
+// intWidth describes an integer record field in place of its value when
+// checking struct fields for compatibility, see [Layout.Compatible].
+type intWidth struct {
+	bits   int
+	signed bool
+}
+
+// probeFields returns the specified fields as decoded from a zero record,
+// but with integer values replaced by their widths.
+func probeFields(fields []field) map[string]any {
+	values := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if f.bitfieldSz != 0 {
+			i, ok := f.typ.(*btf.Int)
+			values[f.name] = intWidth{bits: f.bitfieldSz, signed: ok && i.Encoding&btf.Signed != 0}
+			continue
+		}
+		values[f.name] = probeValue(f.typ)
+	}
+	return values
+}
+
+// probeValue returns the value of the specified type as decoded from zero
+// bytes, but with integer values replaced by their widths.
+func probeValue(typ btf.Type) any {
+	switch typ := btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		if typ.Encoding&btf.Bool == 0 && isIntSize(typ.Size) {
+			return intWidth{bits: int(typ.Size) * 8, signed: typ.Encoding&btf.Signed != 0}
+		}
+	case *btf.Enum:
+		if isIntSize(typ.Size) {
+			return intWidth{bits: int(typ.Size) * 8, signed: typ.Signed}
+		}
+	case *btf.Pointer:
+		return intWidth{bits: 64}
+	case *btf.Array:
+		if elems, ok := zeroValue(typ).([]any); ok {
+			for idx := range elems {
+				elems[idx] = probeValue(typ.Type)
+			}
+			return elems
+		}
+	case *btf.Struct:
+		return probeFields(appendFields(nil, typ.Members, 0))
+	case *btf.Union:
+		return probeFields(appendFields(nil, typ.Members, 0))
+	}
+	return zeroValue(typ)
+}
+
+// zeroValue returns the value of the specified type decoded from zero bytes.
+func zeroValue(typ btf.Type) any {
+	size, err := btf.Sizeof(typ)
+	if err != nil {
+		return nil
+	}
+	return decodeValue(typ, make([]byte, size))
+}
+
+// isIntSize returns true if the specified size in bytes is the size of an
+// integer decoded as int64 or uint64, see decodeInt.
+func isIntSize(size uint32) bool {
+	return size == 1 || size == 2 || size == 4 || size == 8
+}
+
+// assignStruct assigns the specified field values to the matching fields of
+// the struct dst.
+func assignStruct(dst reflect.Value, values map[string]any) error {
+	names := make(map[string]string, len(values))
+	for name := range values {
+		names[normalizedName(name)] = name
+	}
+	typ := dst.Type()
+	for idx := range typ.NumField() {
+		sf := typ.Field(idx)
+		if !sf.IsExported() {
+			continue
+		}
+		name, ok := sf.Tag.Lookup("bpf")
+		if name == "-" {
+			continue
+		}
+		if !ok {
+			if name, ok = names[normalizedName(sf.Name)]; !ok {
+				continue
+			}
+		}
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		if err := assign(dst.Field(idx), value); err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// normalizedName returns the specified field name in lower case and without
+// any underscores.
+func normalizedName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// errIncompatible signals a decoded value not assignable to a struct field.
+var errIncompatible = errors.New("incompatible type")
+
+// assign assigns the specified decoded value to dst. Integer values that
+// overflow dst are incompatible.
+func assign(dst reflect.Value, value any) error {
+	switch v := value.(type) {
+	case intWidth:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if dst.Type().Bits() < v.bits {
+				return fmt.Errorf("%w %s for %d bit integer", errIncompatible, dst.Type(), v.bits)
+			}
+			return nil
+		case reflect.Bool:
+			if !v.signed {
+				return nil
+			}
+		}
+	case int64:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if dst.OverflowInt(v) {
+				return fmt.Errorf("%w %s for value %d", errIncompatible, dst.Type(), v)
+			}
+			dst.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v < 0 || dst.OverflowUint(uint64(v)) {
+				return fmt.Errorf("%w %s for value %d", errIncompatible, dst.Type(), v)
+			}
+			dst.SetUint(uint64(v))
+			return nil
+		}
+	case uint64:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v > math.MaxInt64 || dst.OverflowInt(int64(v)) {
+				return fmt.Errorf("%w %s for value %d", errIncompatible, dst.Type(), v)
+			}
+			dst.SetInt(int64(v))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if dst.OverflowUint(v) {
+				return fmt.Errorf("%w %s for value %d", errIncompatible, dst.Type(), v)
+			}
+			dst.SetUint(v)
+			return nil
+		case reflect.Bool:
+			dst.SetBool(v != 0)
+			return nil
+		}
+	case bool:
+		if dst.Kind() == reflect.Bool {
+			dst.SetBool(v)
+			return nil
+		}
+	case float32:
+		if dst.Kind() == reflect.Float32 || dst.Kind() == reflect.Float64 {
+			dst.SetFloat(float64(v))
+			return nil
+		}
+	case float64:
+		if dst.Kind() == reflect.Float32 || dst.Kind() == reflect.Float64 {
+			dst.SetFloat(v)
+			return nil
+		}
+	case string:
+		switch {
+		case dst.Kind() == reflect.String:
+			dst.SetString(v)
+			return nil
+		case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+			dst.SetBytes([]byte(v))
+			return nil
+		}
+	case []byte:
+		switch {
+		case dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+			dst.SetBytes(v)
+			return nil
+		case dst.Kind() == reflect.Array && dst.Type().Elem().Kind() == reflect.Uint8:
+			reflect.Copy(dst, reflect.ValueOf(v))
+			return nil
+		}
+	case []any:
+		switch dst.Kind() {
+		case reflect.Slice:
+			dst.Set(reflect.MakeSlice(dst.Type(), len(v), len(v)))
+		case reflect.Array:
+		default:
+			return fmt.Errorf("%w %s for array", errIncompatible, dst.Type())
+		}
+		for idx := range min(len(v), dst.Len()) {
+			if err := assign(dst.Index(idx), v[idx]); err != nil {
+				return fmt.Errorf("element %d: %w", idx, err)
+			}
+		}
+		return nil
+	case map[string]any:
+		if dst.Kind() == reflect.Struct {
+			return assignStruct(dst, v)
+		}
+		if dst.Kind() == reflect.Map && dst.Type().Key().Kind() == reflect.String &&
+			reflect.TypeOf(v).AssignableTo(dst.Type()) {
+			dst.Set(reflect.ValueOf(v))
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %s for value of type %T", errIncompatible, dst.Type(), value)
+}
+
+// CheckLayout checks that the Go record type T matches the layout of the
+// named record struct type in the specified BTF information, such as the BTF
+// information of a collection specification. CheckLayout returns a
+// descriptive error in case of a mismatch instead of later silently misreading
+// records.
+//
+// The sizes of T and the record type must match. Additionally, if T is a
+// struct, then each of its non-blank fields must match a record field by name,
+// see [Layout.Unmarshal], with the same offset and size. Record fields without
+// matching struct fields are considered to be padding and thus are accepted.
+func CheckLayout[T any](types *btf.Spec, name string) error {
+	l, err := NewLayout(types, name)
+	if err != nil {
+		return err
+	}
+	return l.check(reflect.TypeFor[T]())
+}
+
+// check checks that the Go type typ matches this record layout.
+func (l *Layout) check(typ reflect.Type) error {
+	if int(typ.Size()) != l.size {
+		return fmt.Errorf("record type %s has size %d, but Go type %s has size %d",
+			l.name, l.size, typ, typ.Size())
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make(map[string]field, len(l.fields))
+	for _, f := range l.fields {
+		fields[normalizedName(f.name)] = f
+	}
+	for idx := range typ.NumField() {
+		sf := typ.Field(idx)
+		if sf.Name == "_" {
+			continue
+		}
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("bpf"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		f, ok := fields[normalizedName(name)]
+		if !ok {
+			return fmt.Errorf("Go field %s.%s has no matching field in record type %s",
+				typ, sf.Name, l.name)
+		}
+		if f.bitfieldSz != 0 {
+			return fmt.Errorf("Go field %s.%s matches bitfield %s.%s",
+				typ, sf.Name, l.name, f.name)
+		}
+		if int(sf.Offset)*8 != f.offset {
+			return fmt.Errorf("Go field %s.%s has offset %d, but %s.%s has offset %d",
+				typ, sf.Name, sf.Offset, l.name, f.name, f.offset/8)
+		}
+		size, err := btf.Sizeof(f.typ)
+		if err != nil {
+			return fmt.Errorf("cannot check record type %s, reason: %w", l.name, err)
+		}
+		if int(sf.Type.Size()) != size {
+			return fmt.Errorf("Go field %s.%s has size %d, but %s.%s has size %d",
+				typ, sf.Name, sf.Type.Size(), l.name, f.name, size)
+		}
+	}
+	return nil
+}
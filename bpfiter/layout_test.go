@@ -0,0 +1,279 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/cilium/ebpf/btf"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var (
+	u8   = &btf.Int{Name: "unsigned char", Size: 1}
+	char = &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}
+	u16  = &btf.Int{Name: "unsigned short", Size: 2}
+	s32  = &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}
+	u32  = &btf.Int{Name: "unsigned int", Size: 4}
+	u64  = &btf.Int{Name: "unsigned long long", Size: 8}
+	bl   = &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}
+	f64  = &btf.Float{Name: "double", Size: 8}
+)
+
+// taskInfoType returns the BTF type of a test record, with the following
+// binary layout:
+//
+//	 0: u32 pid
+//	 4: int prio
+//	 8: char comm[8]
+//	16: u8 tag[2]
+//	18: u16 flags:3, kind:5 (bitfields)
+//	20: union { u32 uid; int sid; } (anonymous)
+//	24: struct { u16 lo; u16 hi; } range
+//	28: u32 nrs[2]
+//	36: bool kthread
+//	37: (padding)
+//	40: u64 *ptr
+//	48: double load
+//	56: enum state (signed)
+//	60: (padding)
+//	64
+func taskInfoType() *btf.Struct {
+	return &btf.Struct{
+		Name: "task_info",
+		Size: 64,
+		Members: []btf.Member{
+			{Name: "pid", Type: &btf.Typedef{Name: "__u32", Type: u32}, Offset: 0},
+			{Name: "prio", Type: &btf.Const{Type: s32}, Offset: 4 * 8},
+			{Name: "comm", Type: &btf.Array{Index: u32, Type: char, Nelems: 8}, Offset: 8 * 8},
+			{Name: "tag", Type: &btf.Array{Index: u32, Type: u8, Nelems: 2}, Offset: 16 * 8},
+			{Name: "flags", Type: u16, Offset: 18 * 8, BitfieldSize: 3},
+			{Name: "kind", Type: s32, Offset: 18*8 + 3, BitfieldSize: 5},
+			{Type: &btf.Union{Size: 4, Members: []btf.Member{
+				{Name: "uid", Type: u32},
+				{Name: "sid", Type: s32},
+			}}, Offset: 20 * 8},
+			{Name: "range", Type: &btf.Struct{Name: "range", Size: 4, Members: []btf.Member{
+				{Name: "lo", Type: u16},
+				{Name: "hi", Type: u16, Offset: 16},
+			}}, Offset: 24 * 8},
+			{Name: "nrs", Type: &btf.Array{Index: u32, Type: u32, Nelems: 2}, Offset: 28 * 8},
+			{Name: "kthread", Type: bl, Offset: 36 * 8},
+			{Name: "ptr", Type: &btf.Pointer{Target: u64}, Offset: 40 * 8},
+			{Name: "load", Type: f64, Offset: 48 * 8},
+			{Name: "state", Type: &btf.Enum{Name: "state", Size: 4, Signed: true}, Offset: 56 * 8},
+		},
+	}
+}
+
+// taskInfoRecord returns a binary test record matching taskInfoType.
+func taskInfoRecord() []byte {
+	b := make([]byte, 64)
+	binary.NativeEndian.PutUint32(b[0:], 42)
+	binary.NativeEndian.PutUint32(b[4:], math.MaxUint32) // -1
+	copy(b[8:], "foo\x00bar")
+	copy(b[16:], []byte{0xbe, 0xef})
+	b[18] = 0b11111_101 // flags 5, kind -1
+	binary.NativeEndian.PutUint32(b[20:], 1000)
+	binary.NativeEndian.PutUint16(b[24:], 1)
+	binary.NativeEndian.PutUint16(b[26:], 2)
+	binary.NativeEndian.PutUint32(b[28:], 3)
+	binary.NativeEndian.PutUint32(b[32:], 4)
+	b[36] = 1
+	binary.NativeEndian.PutUint64(b[40:], 0xdeadbeef)
+	binary.NativeEndian.PutUint64(b[48:], math.Float64bits(0.5))
+	binary.NativeEndian.PutUint32(b[56:], math.MaxUint32-1) // -2
+	return b
+}
+
+type taskInfo struct {
+	PID     uint32
+	Prio    int
+	Comm    string
+	Tag     [2]byte
+	Flags   uint8
+	Kind    int8
+	UID     uint32
+	Range   struct{ Lo, Hi uint16 }
+	Numbers []uint32 `bpf:"nrs"`
+	KThread bool
+	Load    float64
+	State   int32
+	Ptr     uint64 `bpf:"-"`
+	Missing string
+	private int
+}
+
+var _ = Describe("record layouts", func() {
+
+	It("determines layouts from BTF information", func() {
+		types := btfSpec(taskInfoType(), &btf.Typedef{Name: "foo_t", Type: u32})
+		l := Successful(NewLayout(types, "task_info"))
+		Expect(l.Name()).To(Equal("task_info"))
+		Expect(l.Size()).To(Equal(64))
+		Expect(l.Fields()).To(Equal([]string{
+			"pid", "prio", "comm", "tag", "flags", "kind", "uid", "sid",
+			"range", "nrs", "kthread", "ptr", "load", "state"}))
+
+		Expect(NewLayout(nil, "task_info")).Error().To(MatchError(ContainSubstring("no BTF information")))
+		Expect(NewLayout(types, "bar_info")).Error().To(MatchError(ContainSubstring("cannot determine layout of bar_info")))
+		Expect(NewLayout(types, "foo_t")).Error().To(MatchError(ContainSubstring("not a struct")))
+	})
+
+	It("decodes records into field maps", func() {
+		l := newLayout(taskInfoType())
+		Expect(l.Decode(taskInfoRecord())).To(Equal(map[string]any{
+			"pid":     uint64(42),
+			"prio":    int64(-1),
+			"comm":    "foo",
+			"tag":     []byte{0xbe, 0xef},
+			"flags":   uint64(5),
+			"kind":    int64(-1),
+			"uid":     uint64(1000),
+			"sid":     int64(1000),
+			"range":   map[string]any{"lo": uint64(1), "hi": uint64(2)},
+			"nrs":     []any{uint64(3), uint64(4)},
+			"kthread": true,
+			"ptr":     uint64(0xdeadbeef),
+			"load":    0.5,
+			"state":   int64(-2),
+		}))
+		Expect(l.Decode(taskInfoRecord()[:63])).Error().To(MatchError(ErrTruncatedRecord))
+	})
+
+	It("unmarshals records into structs", func() {
+		l := newLayout(taskInfoType())
+		var ti taskInfo
+		Expect(l.Compatible(&ti)).To(Succeed())
+		Expect(l.Unmarshal(taskInfoRecord(), &ti)).To(Succeed())
+		Expect(ti).To(Equal(taskInfo{
+			PID:     42,
+			Prio:    -1,
+			Comm:    "foo",
+			Tag:     [2]byte{0xbe, 0xef},
+			Flags:   5,
+			Kind:    -1,
+			UID:     1000,
+			Range:   struct{ Lo, Hi uint16 }{1, 2},
+			Numbers: []uint32{3, 4},
+			KThread: true,
+			Load:    0.5,
+			State:   -2,
+		}))
+	})
+
+	It("rejects incompatible structs", func() {
+		l := newLayout(taskInfoType())
+		Expect(l.Compatible(&struct{ Comm int }{})).To(
+			MatchError(ContainSubstring("field Comm: incompatible type int for value of type string")))
+		Expect(l.Compatible(&struct{ Nrs string }{})).To(
+			MatchError(ContainSubstring("field Nrs: incompatible type string for array")))
+		Expect(l.Compatible(&struct{ Nrs []string }{})).To(
+			MatchError(ContainSubstring("field Nrs: element 0: incompatible type string")))
+		Expect(l.Compatible(&struct{ PID uint16 }{})).To(
+			MatchError(ContainSubstring("field PID: incompatible type uint16 for 32 bit integer")))
+		Expect(l.Compatible(&struct{ Ptr int8 }{})).To(
+			MatchError(ContainSubstring("field Ptr: incompatible type int8 for 64 bit integer")))
+		Expect(l.Compatible(&struct{ Nrs []uint8 }{})).To(
+			MatchError(ContainSubstring("field Nrs: element 0: incompatible type uint8 for 32 bit integer")))
+		Expect(l.Compatible(&struct{ Kind bool }{})).To(
+			MatchError(ContainSubstring("field Kind: incompatible type bool")))
+		Expect(l.Compatible(struct{}{})).To(MatchError(ContainSubstring("not a pointer to a struct")))
+		Expect(l.Compatible(&[]int{})).To(MatchError(ContainSubstring("not a pointer to a struct")))
+		Expect(l.Compatible(nil)).To(MatchError(ContainSubstring("not a pointer to a struct")))
+		Expect(l.Unmarshal(taskInfoRecord(), 42)).To(MatchError(ContainSubstring("not a pointer to a struct")))
+		Expect(l.Unmarshal(taskInfoRecord()[:1], &taskInfo{})).To(MatchError(ErrTruncatedRecord))
+	})
+
+	It("rejects values overflowing struct fields", func() {
+		l := newLayout(taskInfoType())
+		Expect(l.Unmarshal(taskInfoRecord(), &struct{ UID int8 }{})).To(
+			MatchError(ContainSubstring("field UID: incompatible type int8 for value 1000")))
+		Expect(l.Unmarshal(taskInfoRecord(), &struct{ Prio uint64 }{})).To(
+			MatchError(ContainSubstring("field Prio: incompatible type uint64 for value -1")))
+		Expect(l.Unmarshal(taskInfoRecord(), &struct{ Ptr uint16 }{})).To(
+			MatchError(ContainSubstring("field Ptr: incompatible type uint16 for value 3735928559")))
+		Expect(l.Unmarshal(taskInfoRecord(), &struct{ PID uint8 }{})).To(Succeed())
+	})
+
+	DescribeTable("checks layouts",
+		func(members []btf.Member, size int, expected any) {
+			types := btfSpec(&btf.Struct{Name: "record", Size: uint32(size), Members: members})
+			err := CheckLayout[record](types, "record")
+			if expected == nil {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("matching layout", []btf.Member{
+			{Name: "a", Type: u32},
+			{Name: "b", Type: u16, Offset: 32},
+			{Name: "c", Type: u16, Offset: 48},
+		}, 8, nil),
+		Entry("additional record padding fields", []btf.Member{
+			{Name: "a", Type: u32},
+			{Name: "b", Type: u16, Offset: 32},
+			{Name: "c", Type: u16, Offset: 48},
+			{Name: "d", Type: u8, Offset: 56, BitfieldSize: 1},
+		}, 8, nil),
+		Entry("size mismatch", []btf.Member{
+			{Name: "a", Type: u32},
+		}, 4, "record type record has size 4, but Go type bpfiter.record has size 8"),
+		Entry("missing field", []btf.Member{
+			{Name: "a", Type: u32},
+			{Name: "b", Type: u16, Offset: 32},
+		}, 8, "Go field bpfiter.record.C has no matching field in record type record"),
+		Entry("offset mismatch", []btf.Member{
+			{Name: "a", Type: u32},
+			{Name: "c", Type: u16, Offset: 32},
+			{Name: "b", Type: u16, Offset: 48},
+		}, 8, "Go field bpfiter.record.B has offset 4, but record.b has offset 6"),
+		Entry("size mismatch", []btf.Member{
+			{Name: "a", Type: u16},
+			{Name: "b", Type: u16, Offset: 32},
+			{Name: "c", Type: u16, Offset: 48},
+		}, 8, "Go field bpfiter.record.A has size 4, but record.a has size 2"),
+		Entry("bitfield", []btf.Member{
+			{Name: "a", Type: u32, BitfieldSize: 3},
+			{Name: "b", Type: u16, Offset: 32},
+			{Name: "c", Type: u16, Offset: 48},
+		}, 8, "Go field bpfiter.record.A matches bitfield record.a"),
+	)
+
+	It("checks non-struct and tagged layouts", func() {
+		types := btfSpec(&btf.Struct{Name: "record", Size: 8, Members: []btf.Member{
+			{Name: "value", Type: u64},
+		}})
+		Expect(CheckLayout[uint64](types, "record")).To(Succeed())
+		Expect(CheckLayout[uint32](types, "record")).To(MatchError(ContainSubstring("has size")))
+		Expect(CheckLayout[struct {
+			V uint64 `bpf:"value"`
+		}](types, "record")).To(Succeed())
+		Expect(CheckLayout[struct {
+			_ [4]byte
+			V uint32 `bpf:"-"`
+		}](types, "record")).To(Succeed())
+		Expect(CheckLayout[uint64](types, "foo_info")).To(
+			MatchError(ContainSubstring("cannot determine layout of foo_info")))
+		Expect(CheckLayout[uint64](nil, "record")).To(
+			MatchError(ContainSubstring("no BTF information")))
+	})
+
+})
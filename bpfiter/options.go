@@ -0,0 +1,91 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// Option configures an [Iterator] when loading it using [Load] or [LoadFile].
+type Option func(*options)
+
+type options struct {
+	iterMap     *ebpf.Map
+	cgroupFD    int
+	cgroupOrder CgroupOrder
+	variables   map[string]any
+	recordType  string
+}
+
+// CgroupOrder specifies the order in which an “iter/cgroup” program walks the
+// cgroup hierarchy.
+type CgroupOrder = iteriter.CgroupIterOrder
+
+// Cgroup iterator walk orders.
+const (
+	CgroupSelfOnly        = iteriter.CgroupIterSelfOnly        // only the specified cgroup.
+	CgroupDescendantsPre  = iteriter.CgroupIterDescendantsPre  // pre-order walk of the descendants, including self.
+	CgroupDescendantsPost = iteriter.CgroupIterDescendantsPost // post-order walk of the descendants, including self.
+	CgroupAncestorsUp     = iteriter.CgroupIterAncestorsUp     // walk of the ancestors upwards, including self.
+)
+
+// newOptions returns the options for the specified Option functions.
+func newOptions(opts ...Option) options {
+	o := options{
+		cgroupFD:  -1,
+		variables: map[string]any{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMap attaches an “iter/bpf_map_elem” or “iter/bpf_sk_storage_map”
+// program to the specified map.
+func WithMap(m *ebpf.Map) Option {
+	return func(o *options) {
+		o.iterMap = m
+	}
+}
+
+// WithCgroup attaches an “iter/cgroup” program to the cgroup referenced by the
+// open file descriptor fd, walking the cgroup hierarchy in the specified
+// order. The caller can close fd after loading the iterator.
+func WithCgroup(fd int, order CgroupOrder) Option {
+	return func(o *options) {
+		o.cgroupFD = fd
+		o.cgroupOrder = order
+	}
+}
+
+// WithVariable sets the “const volatile” (or global) variable with the
+// specified name to value before loading the iterator program. The value must
+// exactly match the size of the variable.
+func WithVariable(name string, value any) Option {
+	return func(o *options) {
+		o.variables[name] = value
+	}
+}
+
+// WithRecordType checks the layout of the Go record type against the layout
+// of the named C struct type in the BTF information of the collection
+// specification when loading the iterator, see also [CheckLayout].
+func WithRecordType(name string) Option {
+	return func(o *options) {
+		o.recordType = name
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// ErrTruncatedRecord signals that an iterator's output ended in the middle of
+// a record. Errors signalling truncated records additionally match
+// io.ErrUnexpectedEOF.
+var ErrTruncatedRecord = iteriter.ErrTruncatedRecord
+
+// Records returns an iterator over the fixed-size records of type T read from
+// r. In case of a read failure or a truncated record, the iterator will
+// return a zero record together with an error and then end the sequence. The
+// iterator will never emit io.EOF as this would be pretty useless for an
+// iterator.
+//
+// Records returns the records as values and not as references; please see
+// [RecordsVolatile] for an optimized version that passes records by reference
+// and with the records only valid within the caller's iteration body.
+func Records[T any](r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			var v T
+			err := iteriter.ReadRecord(r, &v)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// RecordsVolatile returns an iterator over the fixed-size records of type T
+// read from r. In case of a read failure or a truncated record, the iterator
+// will return a reference to a zero record together with an error and then
+// end the sequence.
+//
+// RecordsVolatile returns a reference to the current record instead of a
+// (copy of) the current record itself. This reference is only valid within the
+// caller's iteration body and the reference and record referenced become
+// invalid after returning from the iteration body. If an iteration body needs
+// to keep yielded records for longer, it must create (shallow) copies itself.
+func RecordsVolatile[T any](r io.Reader) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		var v T
+		for {
+			err := iteriter.ReadRecord(r, &v)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(&zero, err)
+				return
+			}
+			if !yield(&v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// RecordsFrom starts a new iteration of the specified source and returns an
+// iterator over the fixed-size records of type T read from it, see also
+// [Records].
+func RecordsFrom[T any](src Source) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		f, err := src.Open()
+		if err != nil {
+			var zero T
+			yield(zero, fmt.Errorf("cannot run iterator, reason: %w", err))
+			return
+		}
+		defer f.Close()
+		Records[T](iteriter.NewReader(f))(yield)
+	}
+}
+
+// RecordsVolatileFrom starts a new iteration of the specified source and
+// returns an iterator over references to the fixed-size records of type T read
+// from it, see also [RecordsVolatile].
+func RecordsVolatileFrom[T any](src Source) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		f, err := src.Open()
+		if err != nil {
+			var zero T
+			yield(&zero, fmt.Errorf("cannot run iterator, reason: %w", err))
+			return
+		}
+		defer f.Close()
+		RecordsVolatile[T](iteriter.NewReader(f))(yield)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing/iotest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type record struct {
+	A uint32
+	B uint16
+	C uint16
+}
+
+var _ = Describe("records", func() {
+
+	data := []byte{
+		1, 0, 0, 0, 2, 0, 3, 0,
+		4, 0, 0, 0, 5, 0, 6, 0,
+	}
+
+	It("decodes records", func() {
+		var recs []record
+		for rec, err := range Records[record](bytes.NewReader(data)) {
+			Expect(err).NotTo(HaveOccurred())
+			recs = append(recs, rec)
+		}
+		Expect(recs).To(Equal([]record{{1, 2, 3}, {4, 5, 6}}))
+	})
+
+	It("decodes records from short reads", func() {
+		var recs []record
+		for rec, err := range Records[record](iotest.OneByteReader(bytes.NewReader(data))) {
+			Expect(err).NotTo(HaveOccurred())
+			recs = append(recs, rec)
+		}
+		Expect(recs).To(HaveLen(2))
+	})
+
+	It("decodes volatile records", func() {
+		var as []uint32
+		for rec, err := range RecordsVolatile[record](bytes.NewReader(data)) {
+			Expect(err).NotTo(HaveOccurred())
+			as = append(as, rec.A)
+		}
+		Expect(as).To(Equal([]uint32{1, 4}))
+	})
+
+	It("stops early", func() {
+		count := 0
+		for range Records[record](bytes.NewReader(data)) {
+			count++
+			break
+		}
+		for range RecordsVolatile[record](bytes.NewReader(data)) {
+			count++
+			break
+		}
+		Expect(count).To(Equal(2))
+	})
+
+	It("reports truncated records", func() {
+		var errs []error
+		for rec, err := range Records[record](bytes.NewReader(data[:len(data)-1])) {
+			if err != nil {
+				Expect(rec).To(BeZero())
+				errs = append(errs, err)
+			}
+		}
+		Expect(errs).To(ConsistOf(MatchError(ErrTruncatedRecord)))
+
+		errs = nil
+		for rec, err := range RecordsVolatile[record](bytes.NewReader(data[:3])) {
+			Expect(*rec).To(BeZero())
+			errs = append(errs, err)
+		}
+		Expect(errs).To(ConsistOf(MatchError(ErrTruncatedRecord)))
+	})
+
+	It("reports read errors", func() {
+		r := io.MultiReader(bytes.NewReader(data[:8]), iotest.ErrReader(errors.New("D'OH!")))
+		var errs []error
+		for _, err := range Records[record](r) {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		Expect(errs).To(ConsistOf(MatchError(ContainSubstring("D'OH!"))))
+	})
+
+})
@@ -0,0 +1,130 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// Source is a source of raw iterator output, such as an attached iterator
+// link, an [Iterator], a [Recorder], or a [Replayer]. Each call to Open starts
+// a new iteration.
+type Source = iteriter.Source
+
+// Recorder is a [Source] capturing the raw output of another Source to a file
+// while it is being read, for later replay using a [Replayer]. Each iteration
+// started by Open replaces the file with the output of this iteration.
+//
+// Please note that the file is only replaced when the iteration was read to
+// its end; otherwise, any previous recording is kept.
+type Recorder struct {
+	src  Source
+	path string
+}
+
+// NewRecorder returns a new Recorder capturing the raw output of src to the
+// file at path.
+func NewRecorder(src Source, path string) *Recorder {
+	return &Recorder{src: src, path: path}
+}
+
+// Open starts a new iteration of the recorded Source, returning a reader that
+// captures all data read to a temporary file in the same directory as the
+// recording file. Closing the reader after reading it to its end replaces the
+// recording file with the temporary file.
+func (r *Recorder) Open() (io.ReadCloser, error) {
+	rc, err := r.src.Open()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(r.path), "."+filepath.Base(r.path)+"-*")
+	if err != nil {
+		_ = rc.Close()
+		return nil, fmt.Errorf("cannot create recording, reason: %w", err)
+	}
+	return &recording{tee: io.TeeReader(rc, f), src: rc, file: f, path: r.path}, nil
+}
+
+// recording reads from a source while writing all data read to a temporary
+// file, replacing the recording file on close when complete.
+type recording struct {
+	tee      io.Reader
+	src      io.Closer
+	file     *os.File
+	path     string
+	complete bool // source was read to its end.
+}
+
+// Read reads from the source, writing the data read to the temporary file.
+func (r *recording) Read(p []byte) (int, error) {
+	n, err := r.tee.Read(p)
+	if err == io.EOF {
+		r.complete = true
+	}
+	return n, err
+}
+
+// Close closes the source as well as the temporary file, and then replaces
+// the recording file with the temporary file if the recording is complete.
+// Otherwise, it removes the temporary file.
+func (r *recording) Close() error {
+	err := errors.Join(r.src.Close(), r.file.Close())
+	if err != nil || !r.complete {
+		return errors.Join(err, os.Remove(r.file.Name()))
+	}
+	if err := os.Rename(r.file.Name(), r.path); err != nil {
+		return errors.Join(
+			fmt.Errorf("cannot save recording, reason: %w", err),
+			os.Remove(r.file.Name()))
+	}
+	return nil
+}
+
+// Replayer is a [Source] replaying the raw iterator output previously
+// captured to a file by a [Recorder]. Each iteration started by Open replays
+// the recording from its beginning.
+type Replayer struct {
+	path string
+}
+
+// NewReplayer returns a new Replayer replaying the recording file at path.
+func NewReplayer(path string) *Replayer {
+	return &Replayer{path: path}
+}
+
+// Open starts a new replay of the recording.
+func (r *Replayer) Open() (io.ReadCloser, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open recording, reason: %w", err)
+	}
+	return f, nil
+}
+
+// BytesSource is a [Source] replaying raw iterator output from memory, such as
+// for testing.
+type BytesSource []byte
+
+// Open starts a new replay of the raw iterator output.
+func (b BytesSource) Open() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfiter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// failingSource is a Source that cannot be opened.
+type failingSource struct{}
+
+func (failingSource) Open() (io.ReadCloser, error) { return nil, errors.New("D'OH!") }
+
+var _ = Describe("sources", func() {
+
+	data := BytesSource{
+		1, 0, 0, 0, 2, 0, 3, 0,
+		4, 0, 0, 0, 5, 0, 6, 0,
+	}
+
+	It("iterates records from sources", func() {
+		var recs []record
+		for rec, err := range RecordsFrom[record](data) {
+			Expect(err).NotTo(HaveOccurred())
+			recs = append(recs, rec)
+		}
+		Expect(recs).To(Equal([]record{{1, 2, 3}, {4, 5, 6}}))
+
+		var as []uint32
+		for rec, err := range RecordsVolatileFrom[record](data) {
+			Expect(err).NotTo(HaveOccurred())
+			as = append(as, rec.A)
+		}
+		Expect(as).To(Equal([]uint32{1, 4}))
+	})
+
+	It("reports source errors", func() {
+		for _, err := range RecordsFrom[record](failingSource{}) {
+			Expect(err).To(MatchError(ContainSubstring("cannot run iterator, reason: D'OH!")))
+		}
+		for _, err := range RecordsVolatileFrom[record](failingSource{}) {
+			Expect(err).To(MatchError(ContainSubstring("cannot run iterator, reason: D'OH!")))
+		}
+	})
+
+	It("records and replays", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "recording")
+		rec := NewRecorder(data, path)
+		for range 2 {
+			count := 0
+			for _, err := range RecordsFrom[record](rec) {
+				Expect(err).NotTo(HaveOccurred())
+				count++
+			}
+			Expect(count).To(Equal(2))
+		}
+		Expect(Successful(os.ReadFile(path))).To(Equal([]byte(data)))
+
+		var recs []record
+		for rec, err := range RecordsFrom[record](NewReplayer(path)) {
+			Expect(err).NotTo(HaveOccurred())
+			recs = append(recs, rec)
+		}
+		Expect(recs).To(Equal([]record{{1, 2, 3}, {4, 5, 6}}))
+	})
+
+	It("reports recording and replaying errors", func() {
+		Expect(NewRecorder(data, "/nada/recording").Open()).Error().To(
+			MatchError(ContainSubstring("cannot create recording")))
+		path := filepath.Join(GinkgoT().TempDir(), "recording")
+		Expect(NewRecorder(failingSource{}, path).Open()).Error().To(MatchError("D'OH!"))
+		Expect(path).NotTo(BeAnExistingFile())
+		Expect(NewReplayer("/nada/recording").Open()).Error().To(
+			MatchError(ContainSubstring("cannot open recording")))
+	})
+
+	It("keeps the previous recording unless complete", func() {
+		dir := GinkgoT().TempDir()
+		path := filepath.Join(dir, "recording")
+		Expect(os.WriteFile(path, []byte("previous"), 0o644)).To(Succeed())
+
+		Expect(NewRecorder(failingSource{}, path).Open()).Error().To(MatchError("D'OH!"))
+		Expect(Successful(os.ReadFile(path))).To(Equal([]byte("previous")))
+
+		rc := Successful(NewRecorder(data, path).Open())
+		Expect(rc.Read(make([]byte, 4))).To(Equal(4))
+		Expect(rc.Close()).To(Succeed())
+		Expect(Successful(os.ReadFile(path))).To(Equal([]byte("previous")))
+
+		Expect(Successful(os.ReadDir(dir))).To(HaveLen(1))
+	})
+
+})
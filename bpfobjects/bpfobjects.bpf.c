@@ -0,0 +1,224 @@
+//go:build ignore
+
+#include "iter.h"
+#include "file.h"
+#include "bpfobj.h"
+#include "tid_current_pidns.h"
+#include "bpf_core_read.h"
+
+char __license[] SEC("license") = "Dual MIT/GPL";
+
+// Maximum length of the name of the kernel function a program attaches to,
+// including the terminating zero byte.
+#define ATTACH_FUNC_LEN 64
+
+// prog_info defines the binary representation of loaded eBPF programs.
+struct prog_info {
+    __u64 load_time;   // CLOCK_BOOTTIME nanoseconds
+    __u32 id;
+    __u32 type;        // enum bpf_prog_type
+    __u32 attach_type; // enum bpf_attach_type
+    __u32 xlated_len;  // size of translated instructions in bytes
+    __u32 jited_len;   // size of jited instructions in bytes
+    __u32 pages;       // number of pages allocated for the program
+    __u8  tag[BPF_TAG_SIZE];
+    char  name[BPF_OBJ_NAME_LEN];
+    char  attach_func[ATTACH_FUNC_LEN]; // attach kernel function, if any
+};
+
+// map_info defines the binary representation of eBPF maps.
+struct map_info {
+    __u32 id;
+    __u32 type;        // enum bpf_map_type
+    __u32 key_size;
+    __u32 value_size;
+    __u32 max_entries;
+    __u32 flags;
+    __u32 pages;       // number of pages charged for the map, or 0 if unknown
+    char  name[BPF_OBJ_NAME_LEN];
+};
+
+// link_info defines the binary representation of eBPF links.
+struct link_info {
+    __u32 id;
+    __u32 type;        // enum bpf_link_type
+    __u32 prog_id;     // ID of the linked program, or 0
+    __u32 attach_type; // expected attach type of the linked program
+};
+
+// Kinds of eBPF objects referenced by file descriptors.
+#define BPF_OBJ_PROG 1
+#define BPF_OBJ_MAP  2
+#define BPF_OBJ_LINK 3
+
+// fd_info defines the binary representation of file descriptors referencing
+// eBPF programs, maps, and links.
+struct fd_info {
+    int   pid;       // owning process' PID in the initial PID namespace
+    int   local_pid; // owning process' PID in the caller's PID namespace, or 0
+    __u32 fd;
+    __u32 kind;      // BPF_OBJ_xxx
+    __u32 id;        // program, map, or link ID
+};
+
+const struct prog_info _meh_prog __attribute__((unused)); // force emitting struct prog_info
+const struct map_info _meh_map __attribute__((unused)); // force emitting struct map_info
+const struct link_info _meh_link __attribute__((unused)); // force emitting struct link_info
+const struct fd_info _meh_fd __attribute__((unused)); // force emitting struct fd_info
+
+SEC("iter/bpf_prog")
+int dump_progs(struct bpf_iter__bpf_prog *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct bpf_prog *prog = ctx->prog;
+    if (prog == NULL) {
+        return 0;
+    }
+
+    struct prog_info info;
+    __builtin_memset(&info, 0, sizeof(info));
+    info.id = BPF_CORE_READ(prog, aux, id);
+    info.type = BPF_CORE_READ(prog, type);
+    info.attach_type = BPF_CORE_READ(prog, expected_attach_type);
+    // https://elixir.bootlin.com/linux/v6.12/source/include/linux/filter.h#L1003
+    info.xlated_len = BPF_CORE_READ(prog, len) * 8;
+    info.jited_len = BPF_CORE_READ(prog, jited_len);
+    info.pages = BPF_CORE_READ(prog, pages);
+    info.load_time = BPF_CORE_READ(prog, aux, load_time);
+    BPF_CORE_READ_INTO(&info.tag, prog, tag);
+    BPF_CORE_READ_STR_INTO(&info.name, prog, aux, name);
+    const char *attach_func = BPF_CORE_READ(prog, aux, attach_func_name);
+    if (attach_func != NULL) {
+        bpf_probe_read_kernel_str(info.attach_func, sizeof(info.attach_func), attach_func);
+    }
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
+
+SEC("iter/bpf_map")
+int dump_maps(struct bpf_iter__bpf_map *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct bpf_map *map = ctx->map;
+    if (map == NULL) {
+        return 0;
+    }
+
+    struct map_info info;
+    __builtin_memset(&info, 0, sizeof(info));
+    info.id = BPF_CORE_READ(map, id);
+    info.type = BPF_CORE_READ(map, map_type);
+    info.key_size = BPF_CORE_READ(map, key_size);
+    info.value_size = BPF_CORE_READ(map, value_size);
+    info.max_entries = BPF_CORE_READ(map, max_entries);
+    info.flags = BPF_CORE_READ(map, map_flags);
+    struct bpf_map___pre511 *m511 = (void *) map;
+    if (bpf_core_field_exists(m511->memory)) {
+        info.pages = BPF_CORE_READ(m511, memory.pages);
+    }
+    BPF_CORE_READ_STR_INTO(&info.name, map, name);
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
+
+SEC("iter/bpf_link")
+int dump_links(struct bpf_iter__bpf_link *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct bpf_link *link = ctx->link;
+    if (link == NULL) {
+        return 0;
+    }
+
+    struct link_info info;
+    __builtin_memset(&info, 0, sizeof(info));
+    info.id = BPF_CORE_READ(link, id);
+    info.type = BPF_CORE_READ(link, type);
+    struct bpf_prog *prog = BPF_CORE_READ(link, prog);
+    if (prog != NULL) {
+        info.prog_id = BPF_CORE_READ(prog, aux, id);
+        info.attach_type = BPF_CORE_READ(prog, expected_attach_type);
+    }
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
+
+/*
+ * bpf_obj_kind returns the kind of eBPF object referenced by the specified
+ * file, or 0 if the file doesn't reference an eBPF program, map, or link.
+ *
+ * eBPF objects are backed by anonymous inodes, so we identify them by the
+ * names of their dentries; see also:
+ * https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/syscall.c#L2421
+ */
+__u32 bpf_obj_kind(struct file *file)
+{
+    char name[16];
+    if (bpf_probe_read_kernel_str(name, sizeof(name),
+                                  BPF_CORE_READ(file, f_path.dentry, d_name.name)) < 0) {
+        return 0;
+    }
+    if (name[0] != 'b' || name[1] != 'p' || name[2] != 'f') {
+        return 0;
+    }
+    // "bpf-prog", "bpf-map", and "bpf_link" (sic!)
+    if (name[3] == '-' && name[4] == 'p' && name[5] == 'r' && name[6] == 'o'
+        && name[7] == 'g' && name[8] == '\0') {
+        return BPF_OBJ_PROG;
+    }
+    if (name[3] == '-' && name[4] == 'm' && name[5] == 'a' && name[6] == 'p'
+        && name[7] == '\0') {
+        return BPF_OBJ_MAP;
+    }
+    if (name[3] == '_' && name[4] == 'l' && name[5] == 'i' && name[6] == 'n'
+        && name[7] == 'k' && name[8] == '\0') {
+        return BPF_OBJ_LINK;
+    }
+    return 0;
+}
+
+SEC("iter/task_file")
+int dump_bpf_fds(struct bpf_iter__task_file *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct task_struct *task = ctx->task;
+    struct file *file = ctx->file;
+    if (task == NULL || file == NULL) {
+        return 0;
+    }
+
+    struct fd_info info;
+    info.kind = bpf_obj_kind(file);
+    void *obj = BPF_CORE_READ(file, private_data);
+    if (obj == NULL) {
+        return 0;
+    }
+    switch (info.kind) {
+    case BPF_OBJ_PROG:
+        info.id = BPF_CORE_READ((struct bpf_prog *) obj, aux, id);
+        break;
+    case BPF_OBJ_MAP:
+        info.id = BPF_CORE_READ((struct bpf_map *) obj, id);
+        break;
+    case BPF_OBJ_LINK:
+        info.id = BPF_CORE_READ((struct bpf_link *) obj, id);
+        break;
+    default:
+        return 0;
+    }
+    info.pid = task->tgid;
+    struct task_struct *grp_leader = bpf_task_acquire(task->group_leader);
+    if (grp_leader != NULL) {
+        info.local_pid = tid_current_pidns(grp_leader);
+        bpf_task_release(grp_leader);
+    } else {
+        info.local_pid = 0;
+    }
+    info.fd = ctx->fd;
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
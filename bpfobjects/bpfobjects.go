@@ -0,0 +1,310 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:generate bpf2go -go-package bpfobjects bpfobjects bpfobjects.bpf.c -- -I../_headers/cilium-ebpf -I../_headers/libbpf -I../_headers/beesy
+
+package bpfobjects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/bpfiter"
+	"github.com/thediveo/beesy/internal/cstr"
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// Kinds of eBPF objects referenced by file descriptors, see BPF_OBJ_xxx in
+// bpfobjects.bpf.c.
+const (
+	kindProgram = 1
+	kindMap     = 2
+	kindLink    = 3
+)
+
+// objectKey identifies an eBPF program, map, or link.
+type objectKey struct {
+	kind uint32
+	id   uint32
+}
+
+// Iterator iterates over the eBPF programs, maps, and links currently loaded.
+type Iterator struct {
+	ebpfObjects bpfobjectsObjects
+	progIter    *link.Iter
+	mapIter     *link.Iter
+	linkIter    *link.Iter
+	fdIter      *link.Iter
+}
+
+// NewIterator returns a new Iterator. Use [Iterator.Inventory], or
+// [Iterator.Programs], [Iterator.Maps], and [Iterator.Links] to iterate over
+// the eBPF objects, and [Iterator.Close] to release the Iterator's resources
+// when done.
+func NewIterator() (*Iterator, error) {
+	it := &Iterator{}
+	spec, err := loadBpfobjects()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load eBPF object iterator eBPF objects, reason: %w", err)
+	}
+	// the info records mirror parts of the kernel's bpf_prog, bpf_map, and
+	// bpf_link, and thus get extended whenever we pick up further details.
+	if err := errors.Join(
+		bpfiter.CheckLayout[bpfobjectsProgInfo](spec.Types, "prog_info"),
+		bpfiter.CheckLayout[bpfobjectsMapInfo](spec.Types, "map_info"),
+		bpfiter.CheckLayout[bpfobjectsLinkInfo](spec.Types, "link_info"),
+		bpfiter.CheckLayout[bpfobjectsFdInfo](spec.Types, "fd_info"),
+	); err != nil {
+		return nil, fmt.Errorf("mismatching eBPF object iterator record layout, reason: %w", err)
+	}
+	if err := spec.LoadAndAssign(&it.ebpfObjects, nil); err != nil {
+		return nil, fmt.Errorf("cannot load eBPF object iterator eBPF objects, reason: %w", err)
+	}
+	for _, attach := range []struct {
+		it   **link.Iter
+		prog *ebpf.Program
+		name string
+	}{
+		{&it.progIter, it.ebpfObjects.DumpProgs, "bpf_prog"},
+		{&it.mapIter, it.ebpfObjects.DumpMaps, "bpf_map"},
+		{&it.linkIter, it.ebpfObjects.DumpLinks, "bpf_link"},
+		{&it.fdIter, it.ebpfObjects.DumpBpfFds, "eBPF fd"},
+	} {
+		var err error
+		if *attach.it, err = link.AttachIter(link.IterOptions{Program: attach.prog}); err != nil {
+			it.Close()
+			return nil, fmt.Errorf("cannot attach %s iterator, reason: %w", attach.name, err)
+		}
+	}
+	return it, nil
+}
+
+// Close releases all resources associated with this Iterator.
+func (it *Iterator) Close() {
+	for _, l := range []*link.Iter{it.progIter, it.mapIter, it.linkIter, it.fdIter} {
+		if l != nil {
+			l.Close()
+		}
+	}
+	it.ebpfObjects.Close()
+}
+
+// Inventory returns the eBPF programs, maps, and links currently loaded,
+// together with the processes holding them.
+func (it *Iterator) Inventory() (Inventory, error) {
+	return it.InventoryContext(context.Background())
+}
+
+// InventoryContext returns the eBPF programs, maps, and links currently
+// loaded, together with the processes holding them, see [Iterator.Inventory].
+// When the specified context is done before the inventory is complete,
+// InventoryContext stops iterating and returns an error wrapping ctx.Err().
+func (it *Iterator) InventoryContext(ctx context.Context) (Inventory, error) {
+	owners, err := it.owners(ctx)
+	if err != nil {
+		return Inventory{}, err
+	}
+	var inv Inventory
+	for prog, err := range it.programs(ctx, owners) {
+		if err != nil {
+			return Inventory{}, err
+		}
+		inv.Programs = append(inv.Programs, prog)
+	}
+	for m, err := range it.maps(ctx, owners) {
+		if err != nil {
+			return Inventory{}, err
+		}
+		inv.Maps = append(inv.Maps, m)
+	}
+	for l, err := range it.links(ctx, owners) {
+		if err != nil {
+			return Inventory{}, err
+		}
+		inv.Links = append(inv.Links, l)
+	}
+	return inv, nil
+}
+
+// Programs returns an iterator over the loaded eBPF programs, together with
+// the processes holding them. In case of an iterator failure, the iterator will
+// return a zero Program together with an error and then end the sequence.
+func (it *Iterator) Programs() iter.Seq2[Program, error] {
+	return withOwners(it, it.programs)
+}
+
+// Maps returns an iterator over the eBPF maps, together with the processes
+// holding them. In case of an iterator failure, the iterator will return a zero
+// Map together with an error and then end the sequence.
+func (it *Iterator) Maps() iter.Seq2[Map, error] {
+	return withOwners(it, it.maps)
+}
+
+// Links returns an iterator over the eBPF links, together with the processes
+// holding them. In case of an iterator failure, the iterator will return a zero
+// Link together with an error and then end the sequence.
+func (it *Iterator) Links() iter.Seq2[Link, error] {
+	return withOwners(it, it.links)
+}
+
+// withOwners returns an iterator that first determines the owners of the eBPF
+// objects and then iterates over the objects using these owners.
+func withOwners[T any](it *Iterator, objs func(context.Context, map[objectKey][]Owner) iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		ctx := context.Background()
+		owners, err := it.owners(ctx)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		for obj, err := range objs(ctx, owners) {
+			if !yield(obj, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// owners returns the processes holding eBPF objects, indexed by object kind
+// and ID, until the context is done.
+func (it *Iterator) owners(ctx context.Context) (map[objectKey][]Owner, error) {
+	owners := map[objectKey][]Owner{}
+	for info, err := range iteriter.AllVolatileContext[bpfobjectsFdInfo](ctx, it.fdIter) {
+		if err != nil {
+			return nil, fmt.Errorf("cannot iterate eBPF file descriptors, reason: %w", err)
+		}
+		key := objectKey{kind: info.Kind, id: info.Id}
+		owners[key] = append(owners[key], Owner{
+			PID:      info.Pid,
+			LocalPID: info.LocalPid,
+			FD:       info.Fd,
+		})
+	}
+	return owners, nil
+}
+
+// programs returns an iterator over the loaded eBPF programs, attributed to
+// the specified owners, until the context is done.
+func (it *Iterator) programs(ctx context.Context, owners map[objectKey][]Owner) iter.Seq2[Program, error] {
+	return func(yield func(Program, error) bool) {
+		for info, err := range iteriter.AllVolatileContext[bpfobjectsProgInfo](ctx, it.progIter) {
+			if err != nil {
+				yield(Program{}, fmt.Errorf("cannot iterate eBPF programs, reason: %w", err))
+				return
+			}
+			prog := newProgram(info)
+			prog.Owners = slices.Clone(owners[objectKey{kind: kindProgram, id: info.Id}])
+			if !yield(prog, nil) {
+				return
+			}
+		}
+	}
+}
+
+// maps returns an iterator over the eBPF maps, attributed to the specified
+// owners, until the context is done.
+func (it *Iterator) maps(ctx context.Context, owners map[objectKey][]Owner) iter.Seq2[Map, error] {
+	return func(yield func(Map, error) bool) {
+		for info, err := range iteriter.AllVolatileContext[bpfobjectsMapInfo](ctx, it.mapIter) {
+			if err != nil {
+				yield(Map{}, fmt.Errorf("cannot iterate eBPF maps, reason: %w", err))
+				return
+			}
+			m := newMap(info)
+			m.Owners = slices.Clone(owners[objectKey{kind: kindMap, id: info.Id}])
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// links returns an iterator over the eBPF links, attributed to the specified
+// owners, until the context is done.
+func (it *Iterator) links(ctx context.Context, owners map[objectKey][]Owner) iter.Seq2[Link, error] {
+	return func(yield func(Link, error) bool) {
+		for info, err := range iteriter.AllVolatileContext[bpfobjectsLinkInfo](ctx, it.linkIter) {
+			if err != nil {
+				yield(Link{}, fmt.Errorf("cannot iterate eBPF links, reason: %w", err))
+				return
+			}
+			l := newLink(info)
+			l.Owners = slices.Clone(owners[objectKey{kind: kindLink, id: info.Id}])
+			if !yield(l, nil) {
+				return
+			}
+		}
+	}
+}
+
+// newProgram returns a new Program from the binary program information.
+func newProgram(info *bpfobjectsProgInfo) Program {
+	return Program{
+		ID:         ebpf.ProgramID(info.Id),
+		Type:       ebpf.ProgramType(info.Type),
+		Name:       cstr.String(info.Name[:]),
+		Tag:        tag(info.Tag),
+		AttachType: ebpf.AttachType(info.AttachType),
+		AttachTo:   cstr.String(info.AttachFunc[:]),
+		LoadTime:   time.Duration(info.LoadTime),
+		XlatedLen:  info.XlatedLen,
+		JitedLen:   info.JitedLen,
+		Memlock:    uint64(info.Pages) * uint64(os.Getpagesize()),
+	}
+}
+
+// newMap returns a new Map from the binary map information.
+func newMap(info *bpfobjectsMapInfo) Map {
+	return Map{
+		ID:         ebpf.MapID(info.Id),
+		Type:       ebpf.MapType(info.Type),
+		Name:       cstr.String(info.Name[:]),
+		KeySize:    info.KeySize,
+		ValueSize:  info.ValueSize,
+		MaxEntries: info.MaxEntries,
+		Flags:      info.Flags,
+		Memlock:    mapMemlock(info),
+	}
+}
+
+// mapMemlock returns the size of the memory allocated for a map in bytes, as
+// charged by the kernel before Linux 5.11. Otherwise, it returns the memory
+// footprint approximation of Linux 5.11 up to 6.3, see also:
+// https://elixir.bootlin.com/linux/v6.3/source/kernel/bpf/syscall.c#L775
+func mapMemlock(info *bpfobjectsMapInfo) uint64 {
+	pagesize := uint64(os.Getpagesize())
+	if info.Pages != 0 {
+		return uint64(info.Pages) * pagesize
+	}
+	size := (uint64(info.KeySize) + uint64(info.ValueSize) + 7) &^ 7
+	return (uint64(info.MaxEntries)*size + pagesize - 1) &^ (pagesize - 1)
+}
+
+// newLink returns a new Link from the binary link information.
+func newLink(info *bpfobjectsLinkInfo) Link {
+	return Link{
+		ID:         link.ID(info.Id),
+		Type:       link.Type(info.Type),
+		ProgramID:  ebpf.ProgramID(info.ProgId),
+		AttachType: ebpf.AttachType(info.AttachType),
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfobjects
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// cname returns the passed string as a C char array.
+func cname[A ~[16]int8 | ~[64]int8](s string) (name A) {
+	for idx := range len(s) {
+		name[idx] = int8(s[idx])
+	}
+	return name
+}
+
+var _ = Describe("eBPF object iterator", func() {
+
+	It("converts program information", func() {
+		prog := newProgram(&bpfobjectsProgInfo{
+			LoadTime:   42 * uint64(time.Second),
+			Id:         666,
+			Type:       uint32(ebpf.Tracing),
+			AttachType: uint32(ebpf.AttachTraceIter),
+			XlatedLen:  128,
+			JitedLen:   100,
+			Pages:      2,
+			Tag:        [8]uint8{1, 2, 3, 4, 5, 6, 7, 8},
+			Name:       cname[[16]int8]("dump_progs"),
+			AttachFunc: cname[[64]int8]("bpf_iter_bpf_prog"),
+		})
+		Expect(prog).To(Equal(Program{
+			ID:         666,
+			Type:       ebpf.Tracing,
+			Name:       "dump_progs",
+			Tag:        "0102030405060708",
+			AttachType: ebpf.AttachTraceIter,
+			AttachTo:   "bpf_iter_bpf_prog",
+			LoadTime:   42 * time.Second,
+			XlatedLen:  128,
+			JitedLen:   100,
+			Memlock:    2 * uint64(os.Getpagesize()),
+		}))
+	})
+
+	It("converts map information", func() {
+		pagesize := uint64(os.Getpagesize())
+		Expect(newMap(&bpfobjectsMapInfo{
+			Id:         42,
+			Type:       uint32(ebpf.Hash),
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 1024,
+			Name:       cname[[16]int8]("a_map_with_a_lo"),
+		})).To(Equal(Map{
+			ID:         42,
+			Type:       ebpf.Hash,
+			Name:       "a_map_with_a_lo",
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 1024,
+			Memlock:    (16*1024 + pagesize - 1) / pagesize * pagesize,
+		}))
+		Expect(newMap(&bpfobjectsMapInfo{
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 1024,
+			Pages:      42,
+		}).Memlock).To(Equal(42 * pagesize))
+	})
+
+	It("converts link information", func() {
+		Expect(newLink(&bpfobjectsLinkInfo{
+			Id:         1,
+			Type:       uint32(link.IterType),
+			ProgId:     666,
+			AttachType: uint32(ebpf.AttachTraceIter),
+		})).To(Equal(Link{
+			ID:         1,
+			Type:       link.IterType,
+			ProgramID:  666,
+			AttachType: ebpf.AttachTraceIter,
+		}))
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("takes an inventory including our own eBPF objects", func() {
+			m := Successful(ebpf.NewMap(&ebpf.MapSpec{
+				Name:       "beesy_test",
+				Type:       ebpf.Array,
+				KeySize:    4,
+				ValueSize:  8,
+				MaxEntries: 42,
+			}))
+			defer m.Close()
+			mapInfo := Successful(m.Info())
+			mapID, ok := mapInfo.ID()
+			Expect(ok).To(BeTrue())
+
+			it := Successful(NewIterator())
+			defer it.Close()
+
+			inv := Successful(it.Inventory())
+			own := inv.OwnedBy(int32(os.Getpid()))
+			Expect(own.Maps).To(ContainElement(And(
+				HaveField("ID", mapID),
+				HaveField("Name", "beesy_test"),
+				HaveField("Type", ebpf.Array),
+				HaveField("MaxEntries", uint32(42)),
+				HaveField("Owners", ContainElement(HaveField("FD", uint32(m.FD())))),
+			)))
+			Expect(own.Programs).To(ContainElement(And(
+				HaveField("Name", "dump_progs"),
+				HaveField("Type", ebpf.Tracing),
+				HaveField("AttachTo", "bpf_iter_bpf_prog"),
+				HaveField("Tag", HaveLen(16)),
+			)))
+			Expect(len(own.Links)).To(BeNumerically(">=", 4))
+			for _, l := range own.Links {
+				Expect(l.Type).To(Equal(link.IterType))
+				Expect(own.Programs).To(ContainElement(HaveField("ID", l.ProgramID)))
+			}
+		})
+
+		It("stops taking the inventory when the context is done", func() {
+			it := Successful(NewIterator())
+			defer it.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(it.InventoryContext(ctx)).Error().To(MatchError(context.Canceled))
+		})
+
+		It("iterates programs, maps, and links separately", func() {
+			it := Successful(NewIterator())
+			defer it.Close()
+
+			progs := 0
+			for prog, err := range it.Programs() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(prog.ID).NotTo(BeZero())
+				progs++
+			}
+			Expect(progs).To(BeNumerically(">=", 4))
+			for m, err := range it.Maps() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.ID).NotTo(BeZero())
+			}
+			links := 0
+			for l, err := range it.Links() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(l.ID).NotTo(BeZero())
+				links++
+			}
+			Expect(links).To(BeNumerically(">=", 4))
+		})
+
+	})
+
+})
@@ -0,0 +1,12 @@
+/*
+Package bpfobjects provides a bpftool-like inventory of the eBPF programs,
+maps, and links currently loaded into the kernel, using the kernel's
+“bpf_prog”, “bpf_map”, and “bpf_link” eBPF iterators. Programs, maps, and links
+are attributed to the processes holding file descriptors referencing them,
+based on iterating over the file descriptors of all processes (visible to the
+caller).
+
+Please note that the inventory includes the programs and links of the
+bpfobjects iterator itself.
+*/
+package bpfobjects
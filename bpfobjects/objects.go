@@ -0,0 +1,109 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfobjects
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/internal/owner"
+)
+
+// Program describes a loaded eBPF program, together with the processes
+// holding file descriptors referencing it.
+type Program struct {
+	ID         ebpf.ProgramID
+	Type       ebpf.ProgramType
+	Name       string
+	Tag        string          // program tag in hex, as shown by bpftool.
+	AttachType ebpf.AttachType // expected attach type.
+	AttachTo   string          // kernel function attached to, if any.
+	LoadTime   time.Duration   // CLOCK_BOOTTIME when the program was loaded.
+	XlatedLen  uint32          // size of the translated instructions in bytes.
+	JitedLen   uint32          // size of the jited instructions in bytes.
+	Memlock    uint64          // size of the memory allocated for the program in bytes.
+	Owners     []Owner         // processes holding this program.
+}
+
+// Map describes an eBPF map, together with the processes holding file
+// descriptors referencing it.
+type Map struct {
+	ID         ebpf.MapID
+	Type       ebpf.MapType
+	Name       string
+	KeySize    uint32
+	ValueSize  uint32
+	MaxEntries uint32
+	Flags      uint32
+	// Memlock is the size of the memory allocated for the map in bytes. Since
+	// Linux 5.11, the kernel doesn't track the memory of maps itself anymore,
+	// so Memlock then is the same approximation from the key and value sizes
+	// and the maximum number of entries that “memlock” in fdinfo shows for
+	// Linux 5.11 up to 6.3.
+	Memlock uint64
+	Owners  []Owner // processes holding this map.
+}
+
+// Link describes an eBPF link, together with the processes holding file
+// descriptors referencing it.
+type Link struct {
+	ID         link.ID
+	Type       link.Type
+	ProgramID  ebpf.ProgramID  // ID of the linked program, or zero.
+	AttachType ebpf.AttachType // expected attach type of the linked program.
+	Owners     []Owner         // processes holding this link.
+}
+
+// Owner describes a process holding an eBPF program, map, or link through one
+// of its file descriptors.
+type Owner = owner.Owner
+
+// Inventory of the eBPF programs, maps, and links currently loaded.
+type Inventory struct {
+	Programs []Program
+	Maps     []Map
+	Links    []Link
+}
+
+// OwnedBy returns a new Inventory with only those programs, maps, and links
+// held by the process with the specified PID (in the initial PID namespace).
+func (inv Inventory) OwnedBy(pid int32) Inventory {
+	return Inventory{
+		Programs: ownedBy(inv.Programs, func(p Program) []Owner { return p.Owners }, pid),
+		Maps:     ownedBy(inv.Maps, func(m Map) []Owner { return m.Owners }, pid),
+		Links:    ownedBy(inv.Links, func(l Link) []Owner { return l.Owners }, pid),
+	}
+}
+
+// ownedBy returns the objects held by the process with the specified PID.
+func ownedBy[T any](objs []T, owners func(T) []Owner, pid int32) []T {
+	var owned []T
+	for _, obj := range objs {
+		for _, owner := range owners(obj) {
+			if owner.PID == pid {
+				owned = append(owned, obj)
+				break
+			}
+		}
+	}
+	return owned
+}
+
+// tag returns the hex representation of a program tag.
+func tag(t [8]uint8) string {
+	return hex.EncodeToString(t[:])
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package bpfobjects
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("eBPF objects", func() {
+
+	It("returns program tags in hex", func() {
+		Expect(tag([8]uint8{0xde, 0xad, 0xbe, 0xef, 0x00, 0x01, 0x02, 0x03})).
+			To(Equal("deadbeef00010203"))
+	})
+
+	It("filters an inventory by owning process", func() {
+		inv := Inventory{
+			Programs: []Program{
+				{ID: 1, Owners: []Owner{{PID: 42, FD: 3}}},
+				{ID: 2},
+			},
+			Maps: []Map{
+				{ID: 10, Owners: []Owner{{PID: 666, FD: 3}, {PID: 42, FD: 4}}},
+				{ID: 11, Owners: []Owner{{PID: 666, FD: 5}}},
+			},
+			Links: []Link{
+				{ID: 100, Owners: []Owner{{PID: 42, FD: 5}, {PID: 42, FD: 6}}},
+			},
+		}
+		owned := inv.OwnedBy(42)
+		Expect(owned.Programs).To(ConsistOf(HaveField("ID", BeEquivalentTo(1))))
+		Expect(owned.Maps).To(ConsistOf(HaveField("ID", BeEquivalentTo(10))))
+		Expect(owned.Links).To(ConsistOf(HaveField("ID", BeEquivalentTo(100))))
+
+		Expect(inv.OwnedBy(1)).To(BeZero())
+	})
+
+})
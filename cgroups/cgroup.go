@@ -0,0 +1,53 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cgroups
+
+import "strconv"
+
+// Order in which to walk the cgroup hierarchy.
+type Order uint8
+
+// Supported walk orders.
+const (
+	PreOrder  Order = iota // parents before their children.
+	PostOrder              // children before their parents.
+)
+
+// String returns the name of the walk order.
+func (o Order) String() string {
+	switch o {
+	case PreOrder:
+		return "pre-order"
+	case PostOrder:
+		return "post-order"
+	}
+	return "Order(" + strconv.FormatUint(uint64(o), 10) + ")"
+}
+
+// Cgroup describes a single cgroup in the cgroup v2 hierarchy.
+type Cgroup struct {
+	ID       uint64 // cgroup ID, that is, the inode number of the cgroup directory.
+	ParentID uint64 // ID of the parent cgroup, or zero for the root cgroup.
+	Path     string // path of the cgroup directory in the cgroup2 filesystem.
+	Level    int    // nesting level, with the root cgroup at level 0.
+	Procs    int    // number of processes, as listed in “cgroup.procs”.
+	Threads  int    // number of threads, as listed in “cgroup.threads”.
+}
+
+// Populated returns true if at least one task is attached to this cgroup
+// itself, not taking any descendant cgroups into account.
+func (c Cgroup) Populated() bool {
+	return c.Threads > 0
+}
@@ -0,0 +1,35 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cgroups
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cgroups", func() {
+
+	It("names walk orders", func() {
+		Expect(PreOrder.String()).To(Equal("pre-order"))
+		Expect(PostOrder.String()).To(Equal("post-order"))
+		Expect(Order(42).String()).To(Equal("Order(42)"))
+	})
+
+	It("tells populated cgroups", func() {
+		Expect(Cgroup{}.Populated()).To(BeFalse())
+		Expect(Cgroup{Procs: 1, Threads: 2}.Populated()).To(BeTrue())
+	})
+
+})
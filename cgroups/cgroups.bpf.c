@@ -0,0 +1,94 @@
+//go:build ignore
+
+#include "iter.h"
+#include "cgroup.h"
+#include "bpf_core_read.h"
+
+char __license[] SEC("license") = "Dual MIT/GPL";
+
+// Maximum length of a cgroup name, see NAME_MAX.
+#define CGROUP_NAME_LEN 256
+
+// cgroup_info defines the binary representation of a cgroup. A cgroup_info
+// record is followed by name_len bytes of the cgroup name, without a zero
+// terminator.
+struct cgroup_info {
+    __u64 id;        // cgroup ID
+    __u64 parent_id; // ID of the parent cgroup, or 0 for the root cgroup
+    int   level;     // nesting level, with the root cgroup at level 0
+    __u32 procs;     // number of processes, as in cgroup.procs
+    __u32 threads;   // number of threads, as in cgroup.threads
+    __u32 name_len;
+};
+
+const struct cgroup_info _meh __attribute__((unused)); // force emitting struct cgroup_info
+
+// cgroup_name is the scratch space for cgroup names, as these are too large to
+// comfortably fit onto the eBPF stack.
+struct cgroup_name {
+    char name[CGROUP_NAME_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct cgroup_name);
+} name_scratch SEC(".maps");
+
+/*
+ * count_css_tasks returns the number of tasks of the specified css, as
+ * iterated using the specified css task iterator flags.
+ */
+__u32 count_css_tasks(struct cgroup_subsys_state *css, unsigned int flags)
+{
+    struct bpf_iter_css_task it;
+    struct task_struct *task;
+    __u32 n = 0;
+    bpf_iter_css_task_new(&it, css, flags);
+    while ((task = bpf_iter_css_task_next(&it)) != NULL) {
+        n++;
+    }
+    bpf_iter_css_task_destroy(&it);
+    return n;
+}
+
+SEC("iter/cgroup")
+int dump_cgroups(struct bpf_iter__cgroup *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct cgroup *cgrp = ctx->cgroup;
+    if (cgrp == NULL) {
+        return 0;
+    }
+    __u32 zero = 0;
+    struct cgroup_name *name = bpf_map_lookup_elem(&name_scratch, &zero);
+    if (name == NULL) {
+        return 0;
+    }
+
+    struct cgroup_info info;
+    __builtin_memset(&info, 0, sizeof(info));
+    info.id = BPF_CORE_READ(cgrp, kn, id);
+    struct cgroup_subsys_state *parent = BPF_CORE_READ(cgrp, self.parent);
+    if (parent != NULL) {
+        info.parent_id = BPF_CORE_READ(parent, cgroup, kn, id);
+    }
+    info.level = BPF_CORE_READ(cgrp, level);
+    // https://elixir.bootlin.com/linux/v6.12/source/kernel/cgroup/cgroup.c#L5195
+    info.procs = count_css_tasks(&cgrp->self, CSS_TASK_ITER_PROCS | CSS_TASK_ITER_THREADED);
+    info.threads = count_css_tasks(&cgrp->self, 0);
+    long l = bpf_probe_read_kernel_str(name->name, sizeof(name->name),
+                                       BPF_CORE_READ(cgrp, kn, name));
+    if (l > 1) {
+        info.name_len = l - 1;
+    }
+
+    bpf_seq_write(m, &info, sizeof(info));
+    __u32 len = info.name_len;
+    if (len > sizeof(name->name)) { // pacify the verifier
+        len = sizeof(name->name);
+    }
+    bpf_seq_write(m, name->name, len);
+    return 0;
+}
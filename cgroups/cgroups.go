@@ -0,0 +1,184 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:generate bpf2go -go-package cgroups cgroups cgroups.bpf.c -- -I../_headers/cilium-ebpf -I../_headers/libbpf -I../_headers/beesy
+
+package cgroups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"path/filepath"
+
+	"github.com/thediveo/beesy/bpfiter"
+	"github.com/thediveo/beesy/internal/iteriter"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultRoot is the usual mount point of the cgroup2 filesystem.
+const DefaultRoot = "/sys/fs/cgroup"
+
+// Walker walks the cgroup v2 hierarchy. Please note that a Walker requires
+// Linux 6.7 or later.
+type Walker struct {
+	ebpfObjects cgroupsObjects
+}
+
+// NewWalker returns a new Walker. Use [Walker.All] to walk the cgroup
+// hierarchy and [Walker.Close] to release the Walker's resources when done.
+func NewWalker() (*Walker, error) {
+	w := &Walker{}
+	spec, err := loadCgroups()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load cgroup walker eBPF objects, reason: %w", err)
+	}
+	if err := bpfiter.CheckLayout[cgroupsCgroupInfo](spec.Types, "cgroup_info"); err != nil {
+		return nil, fmt.Errorf("mismatching cgroup walker record layout, reason: %w", err)
+	}
+	if err := spec.LoadAndAssign(&w.ebpfObjects, nil); err != nil {
+		return nil, fmt.Errorf("cannot load cgroup walker eBPF objects, reason: %w", err)
+	}
+	return w, nil
+}
+
+// Close releases all resources associated with this Walker.
+func (w *Walker) Close() {
+	w.ebpfObjects.Close()
+}
+
+// All returns an iterator over the cgroup at the specified path in the cgroup2
+// filesystem, such as [DefaultRoot], and all its descendants, in the specified
+// order. In case of an iterator failure, the iterator will return a zero
+// Cgroup together with an error and then end the sequence.
+//
+// All walks the complete (sub) hierarchy before yielding the first cgroup, as
+// the cgroup paths can only be determined with all cgroups known in case of
+// post-order walks.
+func (w *Walker) All(root string, order Order) iter.Seq2[Cgroup, error] {
+	return w.AllContext(context.Background(), root, order)
+}
+
+// AllContext returns an iterator over the cgroup at the specified path in the
+// cgroup2 filesystem and all its descendants in the specified order, see
+// [Walker.All]. When the specified context is done, the walk stops, returning
+// a zero Cgroup together with an error wrapping ctx.Err() and then ending the
+// sequence.
+func (w *Walker) AllContext(ctx context.Context, root string, order Order) iter.Seq2[Cgroup, error] {
+	return func(yield func(Cgroup, error) bool) {
+		cgroups, err := w.walk(ctx, root, order)
+		if err != nil {
+			yield(Cgroup{}, err)
+			return
+		}
+		for _, cgroup := range cgroups {
+			if !yield(cgroup, nil) {
+				return
+			}
+		}
+	}
+}
+
+// walk returns the cgroup at the specified path and all its descendants in the
+// specified order, until the context is done.
+func (w *Walker) walk(ctx context.Context, root string, order Order) ([]Cgroup, error) {
+	var iterOrder iteriter.CgroupIterOrder
+	switch order {
+	case PreOrder:
+		iterOrder = iteriter.CgroupIterDescendantsPre
+	case PostOrder:
+		iterOrder = iteriter.CgroupIterDescendantsPost
+	default:
+		return nil, fmt.Errorf("unsupported walk order %s", order)
+	}
+	fd, err := unix.Open(root, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open cgroup %q, reason: %w", root, err)
+	}
+	defer unix.Close(fd)
+	cgroupIter, err := iteriter.AttachCgroupIter(w.ebpfObjects.DumpCgroups, fd, iterOrder)
+	if err != nil {
+		return nil, fmt.Errorf("cannot attach cgroup iterator, reason: %w", err)
+	}
+	defer cgroupIter.Close()
+	f, err := cgroupIter.Open()
+	if err != nil {
+		return nil, fmt.Errorf("cannot walk cgroups, reason: %w", err)
+	}
+	defer f.Close()
+	return readCgroups(ctx, iteriter.NewReader(f), filepath.Clean(root))
+}
+
+// readCgroups reads all cgroup records from r until the context is done,
+// determining the cgroup paths based on the specified path of the walk's root
+// cgroup.
+func readCgroups(ctx context.Context, r io.Reader, root string) ([]Cgroup, error) {
+	var cgroups []Cgroup
+	var names []string
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			return nil, fmt.Errorf("cannot walk cgroups, reason: %w", ctx.Err())
+		default:
+		}
+		var info cgroupsCgroupInfo
+		if err := iteriter.ReadRecord(r, &info); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("cannot walk cgroups, reason: %w", err)
+		}
+		name := make([]byte, info.NameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("incomplete cgroup name, reason: %w", err)
+		}
+		cgroups = append(cgroups, Cgroup{
+			ID:       info.Id,
+			ParentID: info.ParentId,
+			Level:    int(info.Level),
+			Procs:    int(info.Procs),
+			Threads:  int(info.Threads),
+		})
+		names = append(names, string(name))
+	}
+	// the walk's root cgroup is the only one with its parent not being part of
+	// the walk.
+	indices := make(map[uint64]int, len(cgroups))
+	for idx, cgroup := range cgroups {
+		indices[cgroup.ID] = idx
+	}
+	var path func(idx int) string
+	path = func(idx int) string {
+		cgroup := &cgroups[idx]
+		if cgroup.Path == "" {
+			parentIdx, ok := indices[cgroup.ParentID]
+			if !ok || parentIdx == idx {
+				cgroup.Path = root
+			} else {
+				cgroup.Path = filepath.Join(path(parentIdx), names[idx])
+			}
+		}
+		return cgroup.Path
+	}
+	for idx := range cgroups {
+		path(idx)
+	}
+	return cgroups, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cgroups
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// cgroupRecord returns the binary representation of a cgroup, as emitted by
+// the eBPF cgroup iterator program.
+func cgroupRecord(id, parentID uint64, level int, name string) []byte {
+	info := cgroupsCgroupInfo{
+		Id:       id,
+		ParentId: parentID,
+		Level:    int32(level),
+		Procs:    1,
+		Threads:  2,
+		NameLen:  uint32(len(name)),
+	}
+	rec := bytes.Clone(unsafe.Slice((*byte)(unsafe.Pointer(&info)), unsafe.Sizeof(info)))
+	return append(rec, name...)
+}
+
+var _ = Describe("cgroup walker", func() {
+
+	DescribeTable("determines cgroup paths",
+		func(records [][]byte) {
+			cgroups := Successful(readCgroups(context.Background(), bytes.NewReader(bytes.Join(records, nil)), "/sys/fs/cgroup/foo.slice"))
+			Expect(cgroups).To(HaveLen(len(records)))
+			paths := map[uint64]string{}
+			for _, cgroup := range cgroups {
+				Expect(cgroup.Procs).To(Equal(1))
+				Expect(cgroup.Threads).To(Equal(2))
+				paths[cgroup.ID] = cgroup.Path
+			}
+			Expect(paths).To(Equal(map[uint64]string{
+				10: "/sys/fs/cgroup/foo.slice",
+				11: "/sys/fs/cgroup/foo.slice/bar",
+				12: "/sys/fs/cgroup/foo.slice/bar/baz",
+				13: "/sys/fs/cgroup/foo.slice/qux",
+			}))
+		},
+		Entry("pre-order", [][]byte{
+			cgroupRecord(10, 1, 1, "foo.slice"),
+			cgroupRecord(11, 10, 2, "bar"),
+			cgroupRecord(12, 11, 3, "baz"),
+			cgroupRecord(13, 10, 2, "qux"),
+		}),
+		Entry("post-order", [][]byte{
+			cgroupRecord(12, 11, 3, "baz"),
+			cgroupRecord(11, 10, 2, "bar"),
+			cgroupRecord(13, 10, 2, "qux"),
+			cgroupRecord(10, 1, 1, "foo.slice"),
+		}),
+	)
+
+	It("names the root cgroup by its path", func() {
+		cgroups := Successful(readCgroups(context.Background(), bytes.NewReader(bytes.Join([][]byte{
+			cgroupRecord(1, 0, 0, ""),
+			cgroupRecord(2, 1, 1, "init.scope"),
+		}, nil)), "/"))
+		Expect(cgroups).To(HaveExactElements(
+			HaveField("Path", "/"),
+			HaveField("Path", "/init.scope"),
+		))
+	})
+
+	It("reports incomplete cgroup records", func() {
+		rec := cgroupRecord(10, 1, 1, "foo.slice")
+		Expect(readCgroups(context.Background(), bytes.NewReader(rec[:4]), "/")).Error().To(
+			MatchError(io.ErrUnexpectedEOF))
+	})
+
+	It("reports incomplete cgroup names", func() {
+		rec := cgroupRecord(10, 1, 1, "foo.slice")
+		Expect(readCgroups(context.Background(), bytes.NewReader(rec[:len(rec)-1]), "/")).Error().To(
+			MatchError(io.ErrUnexpectedEOF))
+	})
+
+	It("stops reading cgroups when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(readCgroups(ctx, bytes.NewReader(cgroupRecord(1, 0, 0, "")), "/")).Error().To(
+			MatchError(context.Canceled))
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("walks the cgroup hierarchy", func() {
+			var ownCgroup string
+			for _, line := range strings.Split(string(Successful(os.ReadFile("/proc/self/cgroup"))), "\n") {
+				if path, ok := strings.CutPrefix(line, "0::"); ok {
+					ownCgroup = filepath.Join(DefaultRoot, path)
+				}
+			}
+			if ownCgroup == "" {
+				Skip("needs unified cgroup hierarchy")
+			}
+			var stat unix.Stat_t
+			Expect(unix.Stat(ownCgroup, &stat)).To(Succeed())
+
+			w := Successful(NewWalker())
+			defer w.Close()
+
+			var preorder []Cgroup
+			for cgroup, err := range w.All(DefaultRoot, PreOrder) {
+				Expect(err).NotTo(HaveOccurred())
+				preorder = append(preorder, cgroup)
+			}
+			Expect(preorder).NotTo(BeEmpty())
+			Expect(preorder[0].Level).To(BeZero())
+			Expect(preorder[0].Path).To(Equal(DefaultRoot))
+			Expect(preorder).To(ContainElement(And(
+				HaveField("ID", stat.Ino),
+				HaveField("Path", ownCgroup),
+				HaveField("Procs", BeNumerically(">=", 1)),
+				HaveField("Threads", BeNumerically(">=", 1)),
+			)))
+
+			var postorder []Cgroup
+			for cgroup, err := range w.All(DefaultRoot, PostOrder) {
+				Expect(err).NotTo(HaveOccurred())
+				postorder = append(postorder, cgroup)
+			}
+			Expect(postorder[len(postorder)-1].Level).To(BeZero())
+			Expect(len(postorder)).To(BeNumerically("~", len(preorder), 10))
+		})
+
+		It("reports walk errors", func() {
+			w := Successful(NewWalker())
+			defer w.Close()
+			for _, err := range w.All("./nada", PreOrder) {
+				Expect(err).To(HaveOccurred())
+			}
+			for _, err := range w.All(DefaultRoot, Order(42)) {
+				Expect(err).To(MatchError(ContainSubstring("unsupported walk order")))
+			}
+		})
+
+		It("stops walking when the context is done", func() {
+			w := Successful(NewWalker())
+			defer w.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			var errs []error
+			for _, err := range w.AllContext(ctx, DefaultRoot, PreOrder) {
+				errs = append(errs, err)
+			}
+			Expect(errs).To(ConsistOf(MatchError(context.Canceled)))
+		})
+
+	})
+
+})
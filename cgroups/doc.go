@@ -0,0 +1,10 @@
+/*
+Package cgroups walks the cgroup v2 hierarchy using the kernel's eBPF “cgroup”
+iterator, reporting each cgroup's ID, path, nesting level, as well as the
+number of processes and threads attached to it.
+
+Cgroup IDs are the same as [github.com/thediveo/beesy.Task.CgroupID], so cgroup
+walks can be combined with beesy task snapshots, for instance, to tell apart
+empty and populated cgroups.
+*/
+package cgroups
@@ -1,4 +1,21 @@
 /*
-Package beesy ...
+Package beesy provides iterating over the tasks (processes and their threads) of
+a Linux system in a single pass using eBPF task iterators.
+
+Optionally, beesy additionally retrieves the command lines and environments of
+processes using a sleepable task iterator; see [WithCmdline] and [WithEnviron].
+For diagnosing hung tasks, beesy can capture the kernel stacks of tasks in
+specific states, see [WithKernelStacks]; the kernel stacks can then be
+symbolized using the [github.com/thediveo/beesy/ksym] package.
+
+To reduce the amount of data transferred and decoded on systems with lots of
+tasks, beesy can filter tasks in-kernel, see [WithCgroupID], [WithPIDNamespace],
+[WithUID], [WithNamePrefix], [WithKthreadsOnly], and [WithUserTasksOnly].
+For per-container views, [NewCgroupTaskIterator] iterates only over the tasks
+of a particular cgroup, and optionally its descendants, instead of scanning all
+tasks.
+When only task counts are needed, such as tasks per state or threads per
+process, a [TaskAggregator] counts tasks in-kernel without transferring any
+individual task records at all.
 */
 package beesy
@@ -0,0 +1,9 @@
+/*
+Package health checks a beesy task snapshot for unhealthy tasks, such as tasks
+blocked in uninterruptible sleep (“D state”), zombies that their parents don't
+reap, and orphaned processes that have been re-parented to a child subreaper.
+
+[Scan] takes a fresh task snapshot and checks it, while [Check] checks an
+already existing task snapshot.
+*/
+package health
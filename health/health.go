@@ -0,0 +1,139 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/tasks"
+	"golang.org/x/sys/unix"
+)
+
+// Report lists the unhealthy tasks found in a task snapshot.
+type Report struct {
+	Blocked []Blocked // tasks in uninterruptible sleep.
+	Zombies []Zombie  // zombie processes.
+	Orphans []Orphan  // orphaned processes re-parented to a child subreaper.
+}
+
+// Blocked describes a task in uninterruptible sleep (“D state”) and for how
+// long it has been blocked.
+type Blocked struct {
+	beesy.Task
+	For time.Duration // approximate duration since the task blocked, see [beesy.Task.LastRan].
+}
+
+// Zombie describes a zombie process together with its parent process that
+// doesn't reap it.
+type Zombie struct {
+	beesy.Task
+	Parent *beesy.Task // parent process not reaping its zombie child, or nil.
+}
+
+// Orphan describes an orphaned process together with the child subreaper it
+// has been re-parented to.
+type Orphan struct {
+	beesy.Task
+	Subreaper *beesy.Task // child subreaper process that adopted the orphan.
+}
+
+// IsHealthy returns true if the report doesn't list any unhealthy tasks.
+func (r *Report) IsHealthy() bool {
+	return len(r.Blocked) == 0 && len(r.Zombies) == 0 && len(r.Orphans) == 0
+}
+
+// Scan takes a task snapshot using the specified task iterator and then checks
+// it, returning the health report.
+func Scan(ti *beesy.TaskIterator) (Report, error) {
+	var snapshot []beesy.Task
+	for task, err := range ti.All() {
+		if err != nil {
+			return Report{}, fmt.Errorf("cannot take task snapshot, reason: %w", err)
+		}
+		snapshot = append(snapshot, task)
+	}
+	now, err := monotonicNow()
+	if err != nil {
+		return Report{}, err
+	}
+	return Check(snapshot, now), nil
+}
+
+// monotonicNow returns the current CLOCK_MONOTONIC time.
+func monotonicNow() (time.Duration, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, fmt.Errorf("cannot read monotonic clock, reason: %w", err)
+	}
+	return time.Duration(ts.Nano()), nil
+}
+
+// Check checks the specified task snapshot that was taken at the CLOCK_MONOTONIC
+// time “now”, returning the health report.
+//
+// Please note that Check considers a process to be an orphan that has been
+// re-parented to a child subreaper if its parent is either a child subreaper or
+// PID 1, and the process neither is a session leader nor in its parent's
+// session. As the kernel doesn't track a process' original parent, this
+// heuristic matches the usual “daemonizing” double-fork, without flagging the
+// children a (sub)reaper itself starts in new sessions or its own session.
+func Check(snapshot []beesy.Task, now time.Duration) Report {
+	processes := map[int32]*beesy.Task{}
+	for idx := range snapshot {
+		if task := &snapshot[idx]; task.PID == task.TID {
+			processes[task.PID] = task
+		}
+	}
+
+	var r Report
+	for idx := range snapshot {
+		task := &snapshot[idx]
+		switch task.State {
+		case tasks.DiskSleep:
+			r.Blocked = append(r.Blocked, Blocked{
+				Task: *task,
+				For:  max(now-task.LastRan, 0),
+			})
+		case tasks.Zombie:
+			// a thread group leader exiting before its other threads stays
+			// a zombie until all threads have exited, so it isn't a zombie
+			// process (yet).
+			if task.PID != task.TID || task.GroupAlive {
+				continue
+			}
+			r.Zombies = append(r.Zombies, Zombie{
+				Task:   *task,
+				Parent: processes[task.PPID],
+			})
+		}
+		if task.PID != task.TID || task.Kthread {
+			continue
+		}
+		parent, ok := processes[task.PPID]
+		if !ok || !(parent.ChildSubreaper || parent.PID == 1) {
+			continue
+		}
+		if task.SID == task.PID || task.SID == parent.SID {
+			continue
+		}
+		r.Orphans = append(r.Orphans, Orphan{
+			Task:      *task,
+			Subreaper: parent,
+		})
+	}
+	return r
+}
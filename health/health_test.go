@@ -0,0 +1,104 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package health_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/health"
+	"github.com/thediveo/beesy/tasks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("task health", func() {
+
+	It("reports a healthy snapshot", func() {
+		r := health.Check([]beesy.Task{
+			{PID: 1, TID: 1, SID: 1, State: tasks.Sleeping},
+			{PID: 2, TID: 2, State: tasks.Sleeping, Kthread: true},
+			{PID: 42, TID: 42, PPID: 1, SID: 42, State: tasks.Running},
+		}, 0)
+		Expect(r.IsHealthy()).To(BeTrue())
+	})
+
+	It("reports blocked tasks", func() {
+		r := health.Check([]beesy.Task{
+			{PID: 1, TID: 1, SID: 1, State: tasks.Sleeping},
+			{PID: 42, TID: 42, PPID: 1, SID: 42, State: tasks.Sleeping},
+			{PID: 42, TID: 43, PPID: 1, SID: 42, State: tasks.DiskSleep, LastRan: 10 * time.Second},
+			{PID: 666, TID: 666, PPID: 1, SID: 666, State: tasks.DiskSleep, LastRan: 30 * time.Second},
+		}, 60*time.Second)
+		Expect(r.IsHealthy()).To(BeFalse())
+		Expect(r.Blocked).To(HaveExactElements(
+			And(HaveField("TID", int32(43)), HaveField("For", 50*time.Second)),
+			And(HaveField("TID", int32(666)), HaveField("For", 30*time.Second)),
+		))
+		Expect(r.Zombies).To(BeEmpty())
+		Expect(r.Orphans).To(BeEmpty())
+	})
+
+	It("reports zombies with their parents", func() {
+		r := health.Check([]beesy.Task{
+			{PID: 1, TID: 1, SID: 1, State: tasks.Sleeping},
+			{PID: 42, TID: 42, PPID: 1, SID: 42, State: tasks.Sleeping},
+			{PID: 42, TID: 43, PPID: 1, SID: 42, State: tasks.Zombie},
+			{PID: 666, TID: 666, PPID: 42, SID: 42, State: tasks.Zombie},
+			{PID: 667, TID: 667, PPID: 7, SID: 42, State: tasks.Zombie},
+			{PID: 668, TID: 668, PPID: 42, SID: 42, State: tasks.Zombie, GroupAlive: true},
+			{PID: 668, TID: 669, PPID: 42, SID: 42, State: tasks.Running, GroupAlive: true},
+		}, 0)
+		Expect(r.Zombies).To(HaveExactElements(
+			And(HaveField("TID", int32(666)), HaveField("Parent.PID", int32(42))),
+			And(HaveField("TID", int32(667)), HaveField("Parent", BeNil())),
+		))
+	})
+
+	It("reports orphans", func() {
+		r := health.Check([]beesy.Task{
+			{PID: 1, TID: 1, SID: 1, State: tasks.Sleeping},
+			{PID: 2, TID: 2, State: tasks.Sleeping, Kthread: true},
+			{PID: 3, TID: 3, PPID: 2, State: tasks.Sleeping, Kthread: true},
+			{PID: 10, TID: 10, PPID: 1, SID: 10, ChildSubreaper: true},
+			{PID: 11, TID: 11, PPID: 10, SID: 10},
+			{PID: 12, TID: 12, PPID: 10, SID: 12},
+			{PID: 13, TID: 13, PPID: 10, SID: 666},
+			{PID: 13, TID: 14, PPID: 10, SID: 666},
+			{PID: 20, TID: 20, PPID: 1, SID: 777},
+			{PID: 30, TID: 30, PPID: 20, SID: 888},
+		}, 0)
+		Expect(r.Orphans).To(HaveExactElements(
+			And(HaveField("TID", int32(13)), HaveField("Subreaper.PID", int32(10))),
+			And(HaveField("TID", int32(20)), HaveField("Subreaper.PID", int32(1))),
+		))
+	})
+
+	It("scans", func() {
+		if os.Getuid() != 0 {
+			Skip("needs root")
+		}
+		ti := Successful(beesy.NewTaskIterator())
+		defer ti.Close()
+		r := Successful(health.Scan(ti))
+		for _, blocked := range r.Blocked {
+			Expect(blocked.For).To(BeNumerically(">=", 0))
+		}
+	})
+
+})
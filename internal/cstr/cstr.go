@@ -0,0 +1,36 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cstr
+
+import (
+	"bytes"
+	"strings"
+	"unsafe"
+)
+
+// String returns the zero-terminated string in the passed C char array.
+func String(chars []int8) string {
+	if len(chars) == 0 {
+		return ""
+	}
+	b := unsafe.Slice((*byte)(unsafe.Pointer(&chars[0])), len(chars))
+	// note that the char array isn't necessarily zero padded, so we cannot use
+	// the usual TrimRight and Co., but instead stop dead at the first zero
+	// byte.
+	if idx := bytes.IndexByte(b, 0); idx >= 0 {
+		return strings.Clone(string(b[:idx]))
+	}
+	return strings.Clone(string(b))
+}
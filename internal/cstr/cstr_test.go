@@ -0,0 +1,31 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cstr
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("C strings", func() {
+
+	It("returns zero-terminated strings", func() {
+		Expect(String(nil)).To(BeEmpty())
+		Expect(String([]int8{0, 'f', 'o', 'o'})).To(BeEmpty())
+		Expect(String([]int8{'f', 'o', 'o', 0, 'x'})).To(Equal("foo"))
+		Expect(String([]int8{'f', 'o', 'o'})).To(Equal("foo"))
+	})
+
+})
@@ -0,0 +1,5 @@
+/*
+Package cstr converts the zero-terminated C char arrays found in the records
+emitted by eBPF programs into Go strings.
+*/
+package cstr
@@ -0,0 +1,106 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package iteriter
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+)
+
+// CgroupIterOrder specifies the order in which a cgroup iterator walks the
+// cgroup hierarchy, see also:
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L106
+//
+// This is the single definition of the kernel's BPF_CGROUP_ITER_xxx orders;
+// public APIs either alias it, such as bpfiter.CgroupOrder, or map their own
+// walk orders onto it, such as cgroups.Order.
+type CgroupIterOrder uint32
+
+// Cgroup iterator walk orders.
+const (
+	CgroupIterSelfOnly        CgroupIterOrder = 1 // only the specified cgroup.
+	CgroupIterDescendantsPre  CgroupIterOrder = 2 // pre-order walk of the descendants, including self.
+	CgroupIterDescendantsPost CgroupIterOrder = 3 // post-order walk of the descendants, including self.
+	CgroupIterAncestorsUp     CgroupIterOrder = 4 // walk of the ancestors upwards, including self.
+)
+
+// BPF_LINK_CREATE command, see also:
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L929
+const bpfLinkCreate = 28
+
+// bpfIterLinkInfoCgroup is the cgroup variant of the bpf_iter_link_info union,
+// see also:
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L113
+type bpfIterLinkInfoCgroup struct {
+	order    uint32
+	cgroupFD uint32
+	cgroupID uint64
+}
+
+// bpfLinkCreateIterAttr is the iterator variant of the BPF_LINK_CREATE
+// command's bpf_attr, see also:
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1660
+type bpfLinkCreateIterAttr struct {
+	progFD      uint32
+	targetFD    uint32
+	attachType  uint32
+	flags       uint32
+	iterInfo    unsafe.Pointer
+	_           [8 - unsafe.Sizeof(uintptr(0))]byte // pad pointers to 64 bits.
+	iterInfoLen uint32
+	_           [4]byte
+}
+
+// AttachCgroupIter attaches the specified “iter/cgroup” program to the cgroup
+// referenced by the open file descriptor cgroupfd, walking the cgroup
+// hierarchy in the specified order. The caller can close cgroupfd after
+// AttachCgroupIter returns.
+//
+// AttachCgroupIter is necessary as [link.AttachIter] doesn't support passing
+// cgroup iterator parameters.
+func AttachCgroupIter(prog *ebpf.Program, cgroupfd int, order CgroupIterOrder) (*link.Iter, error) {
+	if prog.FD() < 0 {
+		return nil, fmt.Errorf("invalid program: %w", unix.EBADF)
+	}
+	info := &bpfIterLinkInfoCgroup{
+		order:    uint32(order),
+		cgroupFD: uint32(cgroupfd),
+	}
+	attr := bpfLinkCreateIterAttr{
+		progFD:      uint32(prog.FD()),
+		attachType:  uint32(ebpf.AttachTraceIter),
+		iterInfo:    unsafe.Pointer(info),
+		iterInfoLen: uint32(unsafe.Sizeof(*info)),
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_BPF,
+		bpfLinkCreate, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	if errno != 0 {
+		return nil, fmt.Errorf("cannot link cgroup iterator, reason: %w", errno)
+	}
+	l, err := link.NewFromFD(int(fd))
+	if err != nil {
+		return nil, fmt.Errorf("cannot link cgroup iterator, reason: %w", err)
+	}
+	it, ok := l.(*link.Iter)
+	if !ok {
+		l.Close()
+		return nil, fmt.Errorf("cannot link cgroup iterator, reason: unexpected link type %T", l)
+	}
+	return it, nil
+}
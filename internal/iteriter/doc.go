@@ -1,5 +1,5 @@
 /*
 Package iteriter provides an iterator to iterate over the data emitted by an
-eBPF iterator.
+eBPF iterator, as well as attaching cgroup iterators to specific cgroups.
 */
 package iteriter
@@ -15,11 +15,13 @@
 package iteriter
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"iter"
 	"unsafe"
-
-	"github.com/cilium/ebpf/link"
 )
 
 const (
@@ -28,66 +30,118 @@ const (
 	BPF_TASK_ITER_PROC_THREADS = 2
 )
 
-// All returns an iterator over the elements of the eBPF iterator passed as
-// “it”. In case of an iterator failure, the iterator will return a zero element
+// Source is a source of raw eBPF iterator output, such as a *link.Iter. Each
+// call to Open starts a new iteration.
+type Source interface {
+	Open() (io.ReadCloser, error)
+}
+
+// ReadBufferSize is the size of the buffer used for reading the data emitted
+// by an eBPF iterator. It is a multiple of the iterator seq_file's initial
+// buffer size of 8 pages, so that a single read syscall usually retrieves
+// many records at once instead of only a single record, see also:
+// https://elixir.bootlin.com/linux/v6.12/source/kernel/bpf/bpf_iter.c#L101
+const ReadBufferSize = 64 * 1024
+
+// NewReader returns a buffered reader for reading the data emitted by an
+// eBPF iterator from r using reads of [ReadBufferSize] bytes.
+func NewReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReaderSize(r, ReadBufferSize)
+}
+
+// All returns an iterator over the elements of the eBPF iterator source
+// “src”. In case of an iterator failure, the iterator will return a zero element
 // together with an error and then end the sequence. The iterator will never
 // emit io.EOF as this would be pretty useless for an iterator.
 //
 // All returns the iterator results as values and not as references; please see
 // [AllVolatile] for an optimized version that passed values by reference and
 // with the values only valid within the caller's iteration body.
-func All[T any](it *link.Iter) iter.Seq2[T, error] {
+func All[T any](src Source) iter.Seq2[T, error] {
+	return AllContext[T](context.Background(), src)
+}
+
+// AllContext returns an iterator over the elements of the eBPF iterator source
+// “src”, see [All]. When the specified context is done, AllContext stops
+// reading and closes the source's reader, returning a zero element
+// together with ctx.Err() and then ending the sequence.
+func AllContext[T any](ctx context.Context, src Source) iter.Seq2[T, error] {
 	return func(yield func(T, error) bool) {
-		f, err := it.Open()
+		f, err := src.Open()
 		if err != nil {
 			var v T
 			yield(v, err)
 			return
 		}
 		defer f.Close()
-		for {
-			v, err := new[T](f)
-			if err == io.EOF {
-				// new emits io.EOF only after the final value has been read
-				// without any error indication.
-				return
-			}
-			// Push either a v with a nil error, or alternatively a zero v with
-			// a non-nil error...
-			if !yield(v, err) {
-				return
-			}
-			if err != nil {
-				return
-			}
-		}
+		all(ctx, NewReader(f), yield)
 	}
 }
 
-// new returns a T value read from f if successful, otherwise an error. Please
-// note that new never returns a non-zero value together with io.EOF. Instead,
-// it returns io.EOF as a final error after the last value.
-func new[T any](f io.Reader) (v T, err error) {
-	n, err := f.Read(unsafe.Slice((*byte)(unsafe.Pointer(&v)), unsafe.Sizeof(v)))
-	// as per the io.Reader contract, callers should consider the returned
-	// amount of bytes read first before looking at any error additionally
-	// returned. However, we consider an incomplete read to be at EOF, otherwise
-	// returning the particular reading error encountered.
-	if uintptr(n) != unsafe.Sizeof(v) {
-		var zero T
+// all yields the elements read from r, until the context is done.
+func all[T any](ctx context.Context, r io.Reader, yield func(T, error) bool) {
+	done := ctx.Done()
+	for {
+		var v T
+		select {
+		case <-done:
+			yield(v, ctx.Err())
+			return
+		default:
+		}
+		err := read(r, &v)
+		if err == io.EOF {
+			// read emits io.EOF only after the final value has been read
+			// without any error indication; a truncated final value is an
+			// error instead.
+			return
+		}
+		// Push either a v with a nil error, or alternatively a zero v with a
+		// non-nil error...
+		if !yield(v, err) {
+			return
+		}
 		if err != nil {
-			return zero, err
+			return
 		}
-		return zero, io.EOF
 	}
-	// We got here because we could read the whole value; at this point, we
-	// pointedly ignore any io.EOF as we will deliver it only on the next
-	// attempt (if any) to read the next iterator value.
-	return v, nil
 }
 
-// AllVolatile returns an iterator over the elements of the eBPF iterator passed
-// as “it”. In case of an iterator failure, the iterator will return a zero
+// ErrTruncatedRecord signals that an iterator's output ended in the middle of
+// a record. Errors signalling truncated records additionally match
+// io.ErrUnexpectedEOF.
+var ErrTruncatedRecord = errors.New("truncated record")
+
+// read reads a T value from r into v if successful, otherwise it returns an
+// error and zeroes v. Please note that read never returns a value together with
+// io.EOF. Instead, it returns io.EOF as a final error after the last value.
+func read[T any](r io.Reader, v *T) error {
+	err := ReadRecord(r, v)
+	if err != nil {
+		var zero T
+		*v = zero
+	}
+	return err
+}
+
+// ReadRecord reads the next fixed-size record from r into v, returning io.EOF
+// only if there are no more records. In case r ends in the middle of the
+// record, ReadRecord returns an error matching both [ErrTruncatedRecord] and
+// io.ErrUnexpectedEOF.
+func ReadRecord[T any](r io.Reader, v *T) error {
+	size := int(unsafe.Sizeof(*v))
+	n, err := io.ReadFull(r, unsafe.Slice((*byte)(unsafe.Pointer(v)), size))
+	switch {
+	case err == nil, err == io.EOF:
+		return err
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w, got only %d out of %d bytes: %w", ErrTruncatedRecord, n, size, err)
+	}
+	return fmt.Errorf("cannot read record, reason: %w", err)
+}
+
+// AllVolatile returns an iterator over the elements of the eBPF iterator source
+// “src”. In case of an iterator failure, the iterator will return a zero
 // element together with an error and then end the sequence. The iterator will
 // never emit io.EOF as this would be pretty useless for an iterator.
 //
@@ -96,32 +150,50 @@ func new[T any](f io.Reader) (v T, err error) {
 // iteration body and the reference and value referenced become invalid after
 // returning from the iteration body. If an iteration body needs to keep yielded
 // values for longer, they must create (shallow) copies themselves.
-func AllVolatile[T any](it *link.Iter) iter.Seq2[*T, error] {
+func AllVolatile[T any](src Source) iter.Seq2[*T, error] {
+	return AllVolatileContext[T](context.Background(), src)
+}
+
+// AllVolatileContext returns an iterator over references to the elements of
+// the eBPF iterator source “src”, see [AllVolatile]. When the specified
+// context is done, AllVolatileContext stops reading and closes the source's
+// reader, returning a reference to a zero element together with ctx.Err()
+// and then ending the sequence.
+func AllVolatileContext[T any](ctx context.Context, src Source) iter.Seq2[*T, error] {
 	return func(yield func(*T, error) bool) {
 		var zero T
-		f, err := it.Open()
+		f, err := src.Open()
 		if err != nil {
 			yield(&zero, err)
 			return
 		}
 		defer f.Close()
-		var v T
-		for {
-			n, err := f.Read(unsafe.Slice((*byte)(unsafe.Pointer(&v)), unsafe.Sizeof(v)))
-			// as per the io.Reader contract, callers should consider the returned
-			// amount of bytes read first before looking at any error additionally
-			// returned. However, we consider an incomplete read to be at EOF, otherwise
-			// returning the particular reading error encountered.
-			if uintptr(n) != unsafe.Sizeof(v) {
-				if err != nil {
-					yield(&zero, err)
-					return
-				}
-				return
-			}
-			if !yield(&v, err) {
-				return
-			}
+		allVolatile(ctx, NewReader(f), yield)
+	}
+}
+
+// allVolatile yields references to the elements read from r, until the
+// context is done.
+func allVolatile[T any](ctx context.Context, r io.Reader, yield func(*T, error) bool) {
+	done := ctx.Done()
+	var v T
+	for {
+		select {
+		case <-done:
+			var zero T
+			yield(&zero, ctx.Err())
+			return
+		default:
+		}
+		err := read(r, &v)
+		if err == io.EOF {
+			return
+		}
+		if !yield(&v, err) {
+			return
+		}
+		if err != nil {
+			return
 		}
 	}
 }
@@ -0,0 +1,166 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package iteriter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing/iotest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// countingReader counts the number of reads.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+var _ = Describe("iterating over eBPF iterator data", func() {
+
+	const numRecords = 10000
+
+	data := func() []byte {
+		b := make([]byte, 0, numRecords*8)
+		for i := range numRecords {
+			b = append(b, byte(i), byte(i>>8), 0, 0, 0, 0, 0, 0)
+		}
+		return b
+	}
+
+	It("decodes many records per read", func() {
+		cr := &countingReader{r: bytes.NewReader(data())}
+		count := 0
+		all(context.Background(), NewReader(cr), func(v uint64, err error) bool {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(uint64(count)))
+			count++
+			return true
+		})
+		Expect(count).To(Equal(numRecords))
+		Expect(cr.reads).To(BeNumerically("<=", numRecords*8/ReadBufferSize+2))
+	})
+
+	It("decodes volatile records", func() {
+		count := 0
+		allVolatile(context.Background(), NewReader(bytes.NewReader(data())), func(v *uint64, err error) bool {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*v).To(Equal(uint64(count)))
+			count++
+			return count < 42
+		})
+		Expect(count).To(Equal(42))
+	})
+
+	It("reports a final incomplete record", func() {
+		b := data()
+		count := 0
+		var errs []error
+		all(context.Background(), NewReader(bytes.NewReader(b[:len(b)-1])), func(v uint64, err error) bool {
+			if err != nil {
+				Expect(v).To(BeZero())
+				errs = append(errs, err)
+				return true
+			}
+			count++
+			return true
+		})
+		Expect(count).To(Equal(numRecords - 1))
+		Expect(errs).To(ConsistOf(And(
+			MatchError(ErrTruncatedRecord),
+			MatchError(io.ErrUnexpectedEOF))))
+	})
+
+	It("reports read errors", func() {
+		r := io.MultiReader(bytes.NewReader(data()[:16]), iotest.ErrReader(errors.New("D'OH!")))
+		var errs []error
+		all(context.Background(), NewReader(r), func(v uint64, err error) bool {
+			if err != nil {
+				Expect(v).To(BeZero())
+				errs = append(errs, err)
+			}
+			return true
+		})
+		Expect(errs).To(ConsistOf(MatchError(ContainSubstring("D'OH!"))))
+
+		errs = nil
+		allVolatile(context.Background(), NewReader(iotest.ErrReader(errors.New("D'OH!"))), func(v *uint64, err error) bool {
+			errs = append(errs, err)
+			return true
+		})
+		Expect(errs).To(ConsistOf(MatchError(ContainSubstring("D'OH!"))))
+	})
+
+	It("stops when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		count := 0
+		var errs []error
+		all(ctx, NewReader(bytes.NewReader(data())), func(v uint64, err error) bool {
+			if err != nil {
+				errs = append(errs, err)
+				return true
+			}
+			count++
+			if count == 42 {
+				cancel()
+			}
+			return true
+		})
+		Expect(count).To(Equal(42))
+		Expect(errs).To(ConsistOf(MatchError(context.Canceled)))
+
+		errs = nil
+		allVolatile(ctx, NewReader(bytes.NewReader(data())), func(v *uint64, err error) bool {
+			Expect(*v).To(BeZero())
+			errs = append(errs, err)
+			return true
+		})
+		Expect(errs).To(ConsistOf(MatchError(context.Canceled)))
+	})
+
+	It("iterates sources", func() {
+		count := 0
+		for v, err := range All[uint64](bytesSource(data())) {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(uint64(count)))
+			count++
+		}
+		Expect(count).To(Equal(numRecords))
+
+		count = 0
+		for v, err := range AllVolatile[uint64](bytesSource(data())) {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*v).To(Equal(uint64(count)))
+			count++
+		}
+		Expect(count).To(Equal(numRecords))
+	})
+
+})
+
+// bytesSource is a Source replaying raw iterator output from memory.
+type bytesSource []byte
+
+func (b bytesSource) Open() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
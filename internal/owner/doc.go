@@ -0,0 +1,5 @@
+/*
+Package owner defines the processes owning kernel objects, such as sockets
+and eBPF objects, through their file descriptors.
+*/
+package owner
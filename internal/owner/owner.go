@@ -0,0 +1,24 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package owner
+
+// Owner describes a process owning a kernel object through one of its file
+// descriptors. A kernel object might be owned by multiple processes, and a
+// process might own the same object through multiple file descriptors.
+type Owner struct {
+	PID      int32  // PID in the initial PID namespace.
+	LocalPID int32  // PID in the caller's PID namespace, or zero.
+	FD       uint32 // file descriptor number.
+}
@@ -0,0 +1,13 @@
+/*
+Package ksym provides resolving kernel addresses to kernel symbols, such as for
+symbolizing kernel stack traces.
+
+A symbol [Table] of the kernel's current symbols is created using [Load], which
+uses the kernel's eBPF “ksym” iterator instead of parsing the text output of
+“/proc/kallsyms”, while honoring “kptr_restrict” the same way. [Iterator]
+exposes the eBPF “ksym” iterator directly as a sequence of [Symbol]s.
+
+Alternatively, a symbol [Table] is created from a “/proc/kallsyms” or a
+kallsyms-formatted file using [LoadKallsyms] or [ParseKallsyms].
+*/
+package ksym
@@ -0,0 +1,142 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:generate bpf2go -go-package ksym ksym ksym.bpf.c -- -I../_headers/cilium-ebpf -I../_headers/libbpf -I../_headers/beesy
+
+package ksym
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/bpfiter"
+	"github.com/thediveo/beesy/internal/iteriter"
+)
+
+// Iterator iterates over the kernel symbols using the kernel's eBPF “ksym”
+// iterator. In contrast to reading “/proc/kallsyms”, the symbols are
+// transferred in binary form.
+type Iterator struct {
+	ebpfObjects ksymObjects
+	ksymIter    *link.Iter
+}
+
+// NewIterator returns a new Iterator. Use [Iterator.All] to iterate over the
+// kernel symbols and [Iterator.Close] to release the Iterator's resources when
+// done.
+func NewIterator() (*Iterator, error) {
+	it := &Iterator{}
+	spec, err := loadKsym()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load kernel symbol iterator eBPF objects, reason: %w", err)
+	}
+	if err := bpfiter.CheckLayout[ksymKsymInfo](spec.Types, "ksym_info"); err != nil {
+		return nil, fmt.Errorf("mismatching kernel symbol iterator record layout, reason: %w", err)
+	}
+	if err := spec.LoadAndAssign(&it.ebpfObjects, nil); err != nil {
+		return nil, fmt.Errorf("cannot load kernel symbol iterator eBPF objects, reason: %w", err)
+	}
+	it.ksymIter, err = link.AttachIter(link.IterOptions{
+		Program: it.ebpfObjects.DumpKsyms,
+	})
+	if err != nil {
+		it.Close()
+		return nil, fmt.Errorf("cannot attach kernel symbol iterator, reason: %w", err)
+	}
+	return it, nil
+}
+
+// Close releases all resources associated with this Iterator.
+func (it *Iterator) Close() {
+	if it.ksymIter != nil {
+		it.ksymIter.Close()
+	}
+	it.ebpfObjects.Close()
+}
+
+// All returns an iterator over all kernel symbols, in the same order as
+// “/proc/kallsyms” lists them. Same as “/proc/kallsyms”, the symbol addresses
+// are zero when “kptr_restrict” hides them from the caller. In case of an
+// iterator failure, the iterator will return a zero Symbol together with an
+// error and then end the sequence.
+func (it *Iterator) All() iter.Seq2[Symbol, error] {
+	return func(yield func(Symbol, error) bool) {
+		f, err := it.ksymIter.Open()
+		if err != nil {
+			yield(Symbol{}, fmt.Errorf("cannot iterate kernel symbols, reason: %w", err))
+			return
+		}
+		defer f.Close()
+		r := iteriter.NewReader(f)
+		for {
+			sym, err := newSymbol(r)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Symbol{}, fmt.Errorf("cannot iterate kernel symbols, reason: %w", err))
+				return
+			}
+			if !yield(sym, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Load returns a new symbol table of the kernel's current symbols, retrieved
+// using the kernel's eBPF “ksym” iterator. If “kptr_restrict” hides all symbol
+// addresses from the caller, Load returns [ErrRestricted].
+func Load() (*Table, error) {
+	it, err := NewIterator()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var syms []Symbol
+	for sym, err := range it.All() {
+		if err != nil {
+			return nil, err
+		}
+		syms = append(syms, sym)
+	}
+	return NewTable(slices.Values(syms))
+}
+
+// newSymbol returns the next Symbol read from r. newSymbol returns io.EOF
+// only after the final symbol has been read, and an error matching
+// io.ErrUnexpectedEOF for an incomplete symbol record.
+func newSymbol(r io.Reader) (Symbol, error) {
+	var info ksymKsymInfo
+	if err := iteriter.ReadRecord(r, &info); err != nil {
+		return Symbol{}, err
+	}
+	names := make([]byte, int(info.NameLen)+int(info.ModuleLen))
+	if _, err := io.ReadFull(r, names); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Symbol{}, fmt.Errorf("incomplete symbol names, reason: %w", err)
+	}
+	return Symbol{
+		Address: info.Addr,
+		Type:    byte(info.Type),
+		Name:    string(names[:info.NameLen]),
+		Module:  string(names[info.NameLen:]),
+	}, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ksym
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"time"
+	"unsafe"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// symbolRecord returns the binary representation of a kernel symbol, as
+// emitted by the eBPF ksym iterator program.
+func symbolRecord(addr uint64, typ byte, name, module string) []byte {
+	info := ksymKsymInfo{
+		Addr:      addr,
+		NameLen:   uint16(len(name)),
+		ModuleLen: uint16(len(module)),
+		Type:      int8(typ),
+	}
+	rec := bytes.Clone(unsafe.Slice((*byte)(unsafe.Pointer(&info)), unsafe.Sizeof(info)))
+	return append(append(rec, name...), module...)
+}
+
+var _ = Describe("kernel symbol iterator", func() {
+
+	It("decodes symbol records", func() {
+		var buff bytes.Buffer
+		buff.Write(symbolRecord(0xffffffff81a2d1f0, 'T', "schedule", ""))
+		buff.Write(symbolRecord(0xffffffffc0a01000, 't', "nfs_wait_bit_killable", "nfs"))
+		Expect(newSymbol(&buff)).To(Equal(Symbol{
+			Address: 0xffffffff81a2d1f0,
+			Type:    'T',
+			Name:    "schedule",
+		}))
+		Expect(newSymbol(&buff)).To(Equal(Symbol{
+			Address: 0xffffffffc0a01000,
+			Type:    't',
+			Name:    "nfs_wait_bit_killable",
+			Module:  "nfs",
+		}))
+		Expect(newSymbol(&buff)).Error().To(MatchError(io.EOF))
+	})
+
+	It("reports an incomplete symbol record", func() {
+		rec := symbolRecord(0xffffffff81a2d1f0, 'T', "schedule", "")
+		Expect(newSymbol(bytes.NewReader(rec[:4]))).Error().To(MatchError(io.ErrUnexpectedEOF))
+	})
+
+	It("reports incomplete symbol names", func() {
+		rec := symbolRecord(0xffffffff81a2d1f0, 'T', "schedule", "")
+		Expect(newSymbol(bytes.NewReader(rec[:len(rec)-1]))).Error().To(MatchError(io.ErrUnexpectedEOF))
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("loads the same symbols as from kallsyms", func() {
+			kallsyms := Successful(LoadKallsyms(KallsymsPath))
+			t := Successful(Load())
+			// the ksym iterator program itself adds some symbols, so we cannot
+			// expect exactly the same number of symbols.
+			Expect(t.Len()).To(BeNumerically("~", kallsyms.Len(), 100))
+
+			for _, name := range []string{"schedule", "do_sys_open"} {
+				var expected Symbol
+				for sym := range kallsyms.All() {
+					if sym.Name == name {
+						expected = sym
+						break
+					}
+				}
+				Expect(expected.Address).NotTo(BeZero(), "missing %s", name)
+				Expect(slices.Collect(t.All())).To(ContainElement(expected))
+			}
+			Expect(t.Modules()).To(Equal(kallsyms.Modules()))
+		})
+
+	})
+
+})
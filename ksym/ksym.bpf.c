@@ -0,0 +1,100 @@
+//go:build ignore
+
+#include "iter.h"
+#include "kallsyms.h"
+#include "bpf_core_read.h"
+
+char __license[] SEC("license") = "Dual MIT/GPL";
+
+// ksym_info defines the binary representation of a kernel symbol. A ksym_info
+// record is followed by name_len bytes of the symbol name and then by
+// module_len bytes of the module name, both without zero terminators.
+struct ksym_info {
+    __u64 addr;       // symbol address, or 0 if restricted
+    __u16 name_len;
+    __u16 module_len; // zero for symbols of the kernel itself
+    char  type;       // symbol type, as in nm(1)
+};
+
+const struct ksym_info _meh __attribute__((unused)); // force emitting struct ksym_info
+
+// ksym_names is the scratch space for the symbol and module names, as these
+// are too large to fit onto the eBPF stack.
+struct ksym_names {
+    char name[KSYM_NAME_LEN];
+    char module[MODULE_NAME_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct ksym_names);
+} ksym_scratch SEC(".maps");
+
+/*
+ * str_len returns the length of a string copied by bpf_probe_read_kernel_str,
+ * excluding the zero terminator, given the helper's return value.
+ */
+__u16 str_len(long l, __u16 max)
+{
+    if (l <= 1) {
+        return 0;
+    }
+    if (l - 1 > max) {
+        return max;
+    }
+    return l - 1;
+}
+
+SEC("iter/ksym")
+int dump_ksyms(struct bpf_iter__ksym *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct kallsym_iter *ksym = ctx->ksym;
+    if (ksym == NULL) {
+        return 0;
+    }
+    __u32 zero = 0;
+    struct ksym_names *names = bpf_map_lookup_elem(&ksym_scratch, &zero);
+    if (names == NULL) {
+        return 0;
+    }
+
+    struct ksym_info info;
+    __builtin_memset(&info, 0, sizeof(info));
+    // honor kptr_restrict the same way /proc/kallsyms does.
+    if (BPF_CORE_READ(ksym, show_value)) {
+        info.addr = BPF_CORE_READ(ksym, value);
+    }
+    info.name_len = str_len(
+        BPF_CORE_READ_STR_INTO(&names->name, ksym, name),
+        sizeof(names->name) - 1);
+    info.module_len = str_len(
+        BPF_CORE_READ_STR_INTO(&names->module, ksym, module_name),
+        sizeof(names->module) - 1);
+    // https://elixir.bootlin.com/linux/v6.12/source/kernel/kallsyms.c#L754
+    info.type = BPF_CORE_READ(ksym, type);
+    if (info.module_len > 0) {
+        if (BPF_CORE_READ(ksym, exported)) {
+            if (info.type >= 'a' && info.type <= 'z') {
+                info.type -= 'a' - 'A';
+            }
+        } else if (info.type >= 'A' && info.type <= 'Z') {
+            info.type += 'a' - 'A';
+        }
+    }
+
+    bpf_seq_write(m, &info, sizeof(info));
+    __u32 len = info.name_len;
+    if (len > sizeof(names->name)) { // pacify the verifier
+        len = sizeof(names->name);
+    }
+    bpf_seq_write(m, names->name, len);
+    len = info.module_len;
+    if (len > sizeof(names->module)) { // pacify the verifier
+        len = sizeof(names->module);
+    }
+    bpf_seq_write(m, names->module, len);
+    return 0;
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ksym
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// KallsymsPath is the path to the kernel's symbol table in procfs.
+const KallsymsPath = "/proc/kallsyms"
+
+// ErrRestricted signals that all symbol addresses are hidden from the caller,
+// usually due to the “[kptr_restrict]” setting in combination with the caller
+// missing CAP_SYSLOG.
+//
+// [kptr_restrict]: https://docs.kernel.org/admin-guide/sysctl/kernel.html#kptr-restrict
+var ErrRestricted = errors.New("kernel symbol addresses are restricted")
+
+// Symbol describes a single kernel symbol.
+type Symbol struct {
+	Address uint64 // address of the symbol.
+	Type    byte   // symbol type, as in nm(1), such as “T” or “t”.
+	Name    string // symbol name.
+	Module  string // name of kernel module, or empty for the kernel itself.
+}
+
+// Table is a read-only table of kernel symbols, ordered by their addresses,
+// supporting address-to-symbol resolution.
+type Table struct {
+	syms []Symbol
+}
+
+// NewTable returns a new symbol table for the specified symbols. If all
+// symbols have a zero address, NewTable returns [ErrRestricted] instead.
+func NewTable(syms iter.Seq[Symbol]) (*Table, error) {
+	t := &Table{syms: slices.Collect(syms)}
+	if !slices.ContainsFunc(t.syms, func(sym Symbol) bool { return sym.Address != 0 }) {
+		return nil, ErrRestricted
+	}
+	// keep the original order of symbols with the same address, so that the
+	// first symbol in the original order wins when resolving addresses.
+	slices.SortStableFunc(t.syms, func(a, b Symbol) int {
+		return cmp.Compare(a.Address, b.Address)
+	})
+	return t, nil
+}
+
+// LoadKallsyms returns a new symbol table loaded from the kallsyms-formatted
+// file at the specified path. Use [KallsymsPath] for the kernel's current
+// symbol table.
+func LoadKallsyms(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load kernel symbols, reason: %w", err)
+	}
+	defer f.Close()
+	return ParseKallsyms(f)
+}
+
+// ParseKallsyms returns a new symbol table from the kallsyms-formatted data
+// read from r.
+func ParseKallsyms(r io.Reader) (*Table, error) {
+	var syms []Symbol
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		sym, err := parseKallsymsLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kernel symbol in line %d, reason: %w", lineno, err)
+		}
+		syms = append(syms, sym)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read kernel symbols, reason: %w", err)
+	}
+	return NewTable(slices.Values(syms))
+}
+
+// parseKallsymsLine parses a single line in kallsyms format, such as
+// “ffffffffc0a01000 t nfs_wait_bit_killable\t[nfs]”.
+func parseKallsymsLine(line string) (Symbol, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || len(fields) > 4 || len(fields[1]) != 1 {
+		return Symbol{}, fmt.Errorf("malformed line %q", line)
+	}
+	addr, err := strconv.ParseUint(fields[0], 16, 64)
+	if err != nil {
+		return Symbol{}, fmt.Errorf("malformed address %q", fields[0])
+	}
+	sym := Symbol{
+		Address: addr,
+		Type:    fields[1][0],
+		Name:    fields[2],
+	}
+	if len(fields) == 4 {
+		mod, ok := strings.CutPrefix(fields[3], "[")
+		if !ok {
+			return Symbol{}, fmt.Errorf("malformed module %q", fields[3])
+		}
+		sym.Module, ok = strings.CutSuffix(mod, "]")
+		if !ok {
+			return Symbol{}, fmt.Errorf("malformed module %q", fields[3])
+		}
+	}
+	return sym, nil
+}
+
+// Len returns the number of symbols in this table.
+func (t *Table) Len() int {
+	return len(t.syms)
+}
+
+// All returns an iterator over all symbols in this table, in ascending order
+// of their addresses.
+func (t *Table) All() iter.Seq[Symbol] {
+	return slices.Values(t.syms)
+}
+
+// Modules returns the names of the kernel modules with symbols in this table,
+// in lexicographical order.
+func (t *Table) Modules() []string {
+	var mods []string
+	for _, sym := range t.syms {
+		if sym.Module != "" {
+			mods = append(mods, sym.Module)
+		}
+	}
+	slices.Sort(mods)
+	return slices.Compact(mods)
+}
+
+// Lookup returns the symbol containing the specified address, together with
+// the offset of the address into the symbol and the symbol's size. If the size
+// is unknown, it is reported as zero. If no symbol contains the address, ok is
+// false. As the symbol with the highest address has no known end, it only
+// contains its own address.
+func (t *Table) Lookup(addr uint64) (sym Symbol, offset uint64, size uint64, ok bool) {
+	idx, found := slices.BinarySearchFunc(t.syms, addr, func(sym Symbol, addr uint64) int {
+		return cmp.Compare(sym.Address, addr)
+	})
+	if !found {
+		// idx is the position where addr would be inserted, so the symbol
+		// containing addr (if any) is immediately before.
+		if idx == 0 {
+			return Symbol{}, 0, 0, false
+		}
+		idx--
+		for idx > 0 && t.syms[idx-1].Address == t.syms[idx].Address {
+			idx--
+		}
+	}
+	sym = t.syms[idx]
+	// find the next symbol with a higher address in order to determine the
+	// size of the symbol.
+	for next := idx + 1; next < len(t.syms); next++ {
+		if t.syms[next].Address > sym.Address {
+			size = t.syms[next].Address - sym.Address
+			break
+		}
+	}
+	if size == 0 && addr != sym.Address {
+		return Symbol{}, 0, 0, false
+	}
+	return sym, addr - sym.Address, size, true
+}
+
+// Format returns the specified address in the same format as used in kernel
+// stack traces, such as “schedule+0x27/0xb0”, or “nfs_wait_bit_killable+0x1c/0x80
+// [nfs]” for module symbols. Addresses that cannot be resolved are returned in
+// hexadecimal format instead.
+func (t *Table) Format(addr uint64) string {
+	sym, offset, size, ok := t.Lookup(addr)
+	if !ok {
+		return fmt.Sprintf("0x%x", addr)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s+0x%x/0x%x", sym.Name, offset, size)
+	if sym.Module != "" {
+		fmt.Fprintf(&b, " [%s]", sym.Module)
+	}
+	return b.String()
+}
+
+// Symbolize returns the symbolized form of the specified addresses, such as
+// the instruction pointers of a kernel stack trace. See also [Table.Format].
+func (t *Table) Symbolize(addrs []uint64) []string {
+	frames := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		frames = append(frames, t.Format(addr))
+	}
+	return frames
+}
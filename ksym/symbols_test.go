@@ -0,0 +1,137 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ksym
+
+import (
+	"cmp"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("kernel symbols", func() {
+
+	DescribeTable("rejects malformed kallsyms lines",
+		func(line string) {
+			Expect(parseKallsymsLine(line)).Error().To(HaveOccurred())
+		},
+		Entry("too few fields", "ffffffff81000000 T"),
+		Entry("too many fields", "ffffffff81000000 T foo [bar] baz"),
+		Entry("invalid address", "fffffffg81000000 T foo"),
+		Entry("invalid type", "ffffffff81000000 TT foo"),
+		Entry("invalid module", "ffffffff81000000 t foo bar"),
+		Entry("unterminated module", "ffffffff81000000 t foo [bar"),
+	)
+
+	It("parses kallsyms lines", func() {
+		Expect(parseKallsymsLine("ffffffffc0a01000 t nfs_wait_bit_killable\t[nfs]")).To(Equal(Symbol{
+			Address: 0xffffffffc0a01000,
+			Type:    't',
+			Name:    "nfs_wait_bit_killable",
+			Module:  "nfs",
+		}))
+	})
+
+	It("reports restricted addresses", func() {
+		Expect(ParseKallsyms(strings.NewReader(
+			"0000000000000000 T _stext\n0000000000000000 T schedule\n"))).Error().To(MatchError(ErrRestricted))
+	})
+
+	It("reports broken files", func() {
+		Expect(LoadKallsyms("./testdata/nada")).Error().To(HaveOccurred())
+		Expect(ParseKallsyms(strings.NewReader("foobar\n"))).Error().To(
+			MatchError(ContainSubstring("line 1")))
+	})
+
+	When("resolving addresses", func() {
+
+		var t *Table
+
+		BeforeEach(func() {
+			t = Successful(LoadKallsyms("./testdata/kallsyms"))
+		})
+
+		It("iterates in address order", func() {
+			Expect(t.Len()).To(Equal(12))
+			Expect(slices.IsSortedFunc(slices.Collect(t.All()), func(a, b Symbol) int {
+				return cmp.Compare(a.Address, b.Address)
+			})).To(BeTrue())
+		})
+
+		It("lists modules", func() {
+			Expect(t.Modules()).To(HaveExactElements("nfs"))
+		})
+
+		It("looks up symbols", func() {
+			sym, offset, size, ok := t.Lookup(0xffffffff81a2d1f0 + 0x27)
+			Expect(ok).To(BeTrue())
+			Expect(sym.Name).To(Equal("schedule"))
+			Expect(offset).To(Equal(uint64(0x27)))
+			Expect(size).To(Equal(uint64(0xb0)))
+
+			sym, offset, _, ok = t.Lookup(0xffffffff81000000)
+			Expect(ok).To(BeTrue())
+			Expect(sym.Name).To(Equal("_stext"))
+			Expect(offset).To(BeZero())
+
+			sym, _, _, ok = t.Lookup(0xffffffff81000010)
+			Expect(ok).To(BeTrue())
+			Expect(sym.Name).To(Equal("_stext"))
+
+			sym, offset, size, ok = t.Lookup(0xffffffffc0a02000)
+			Expect(ok).To(BeTrue())
+			Expect(sym.Name).To(Equal("nfs_fs_type"))
+			Expect(offset).To(BeZero())
+			Expect(size).To(BeZero())
+
+			_, _, _, ok = t.Lookup(0xffffffffc0a02010)
+			Expect(ok).To(BeFalse())
+			_, _, _, ok = t.Lookup(math.MaxUint64)
+			Expect(ok).To(BeFalse())
+
+			_, _, _, ok = t.Lookup(0x42)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("symbolizes", func() {
+			Expect(t.Symbolize([]uint64{
+				0xffffffff81a2d1f0 + 0x27,
+				0xffffffffc0a01000 + 0x1c,
+				0x42,
+				0xffffffffc0a02010,
+			})).To(HaveExactElements(
+				"schedule+0x27/0xb0",
+				"nfs_wait_bit_killable+0x1c/0x80 [nfs]",
+				"0x42",
+				"0xffffffffc0a02010",
+			))
+		})
+
+	})
+
+	It("loads the kernel's symbol table", func() {
+		if os.Getuid() != 0 {
+			Skip("needs root")
+		}
+		t := Successful(LoadKallsyms(KallsymsPath))
+		Expect(t.Len()).NotTo(BeZero())
+	})
+
+})
@@ -0,0 +1,212 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package beesy
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/thediveo/beesy/tasks"
+)
+
+// MaxUsermemLen is the maximum length in bytes of the command line and
+// environment data each that can be retrieved per process.
+//
+// All output of the task iterator for a single task must fit into the
+// iterator's seq_file buffer of 8 pages, that is, 32 KiB on systems with 4 KiB
+// pages. Otherwise, the kernel fails the whole iteration with E2BIG. The
+// maximum lengths thus leave enough room for the task status and kernel stack
+// of a task besides its command line and environment. See also
+// USERMEM_MAX_LEN in usermem.h.
+const MaxUsermemLen = 8 * 1024
+
+// Option configures a [TaskIterator] when creating it using
+// [NewTaskIterator].
+type Option func(*options)
+
+type options struct {
+	maxCmdlineLen   uint32
+	maxEnvironLen   uint32
+	kstackStateMask uint32
+	filter          filter
+}
+
+// filter specifies the task filters applied in-kernel, see FILTER_xxx and
+// filter_xxx in taskiter.bpf.c.
+type filter struct {
+	cgroupID   uint64
+	pidns      uint64
+	uid        uint32
+	kind       uint32
+	namePrefix string
+}
+
+// Filter values, see FILTER_xxx in taskiter.bpf.c.
+const (
+	filterAnyUID    = ^uint32(0)
+	filterKthreads  = 1
+	filterUserTasks = 2
+)
+
+// maxNamePrefixLen is the maximum length of a task name prefix to filter by,
+// see TASKFULLNAMELEN in task.h.
+const maxNamePrefixLen = 63
+
+// newOptions returns the options for the specified Option functions.
+func newOptions(opts ...Option) options {
+	o := options{
+		filter: filter{uid: filterAnyUID},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCmdline requests the command lines of processes to be retrieved, up to
+// maxlen bytes per process (including the zero bytes separating the
+// individual arguments). A maxlen of zero disables retrieving the command
+// lines. A maxlen beyond [MaxUsermemLen] gets capped to MaxUsermemLen.
+//
+// Please note that retrieving command lines requires a kernel supporting
+// sleepable task iterators as well as the “bpf_copy_from_user_task” helper,
+// that is, Linux 5.18 or later.
+func WithCmdline(maxlen uint32) Option {
+	return func(o *options) {
+		o.maxCmdlineLen = min(maxlen, MaxUsermemLen)
+	}
+}
+
+// WithEnviron requests the environment variables of processes to be retrieved,
+// up to maxlen bytes per process (including the zero bytes separating the
+// individual variables). A maxlen of zero disables retrieving the environment
+// variables. A maxlen beyond [MaxUsermemLen] gets capped to MaxUsermemLen.
+//
+// Please note that retrieving the environments requires a kernel supporting
+// sleepable task iterators as well as the “bpf_copy_from_user_task” helper,
+// that is, Linux 5.18 or later.
+func WithEnviron(maxlen uint32) Option {
+	return func(o *options) {
+		o.maxEnvironLen = min(maxlen, MaxUsermemLen)
+	}
+}
+
+// WithKernelStacks requests the kernel stacks of those tasks that are in any of
+// the specified states. If no states are specified, then the kernel stacks of
+// all tasks are requested. For instance, to diagnose hung tasks, pass
+// [tasks.DiskSleep] in order to retrieve the kernel stacks only of tasks in
+// uninterruptible sleep.
+func WithKernelStacks(states ...tasks.State) Option {
+	return func(o *options) {
+		o.kstackStateMask = tasks.StateMask(states...)
+	}
+}
+
+// WithCgroupID filters for tasks that are members of the cgroup with the
+// specified ID in the unified (v2) cgroup hierarchy, excluding tasks in
+// sub-cgroups. The cgroup ID is the inode number of the cgroup's directory in
+// the cgroup2 filesystem.
+//
+// Filtering happens in-kernel, so non-matching tasks are never transferred to
+// user space.
+func WithCgroupID(id uint64) Option {
+	return func(o *options) {
+		o.filter.cgroupID = id
+	}
+}
+
+// WithPIDNamespace filters for tasks in the PID namespace with the specified
+// inode number, excluding tasks in child PID namespaces.
+//
+// Filtering happens in-kernel, so non-matching tasks are never transferred to
+// user space.
+func WithPIDNamespace(ino uint64) Option {
+	return func(o *options) {
+		o.filter.pidns = ino
+	}
+}
+
+// WithUID filters for tasks with the specified real UID.
+//
+// Filtering happens in-kernel, so non-matching tasks are never transferred to
+// user space.
+func WithUID(uid uint32) Option {
+	return func(o *options) {
+		o.filter.uid = uid
+	}
+}
+
+// WithNamePrefix filters for tasks with names starting with the specified
+// prefix. In case of kthreads, the prefix applies to their full names. The
+// prefix must not be longer than 63 bytes.
+//
+// Filtering happens in-kernel, so non-matching tasks are never transferred to
+// user space.
+func WithNamePrefix(prefix string) Option {
+	return func(o *options) {
+		o.filter.namePrefix = prefix
+	}
+}
+
+// WithKthreadsOnly filters for kernel threads only. It cannot be combined
+// with [WithUserTasksOnly]; the last one specified wins.
+//
+// Filtering happens in-kernel, so non-matching tasks are never transferred to
+// user space.
+func WithKthreadsOnly() Option {
+	return func(o *options) {
+		o.filter.kind = filterKthreads
+	}
+}
+
+// WithUserTasksOnly filters for user tasks only, skipping all kernel threads.
+// It cannot be combined with [WithKthreadsOnly]; the last one specified wins.
+//
+// Filtering happens in-kernel, so non-matching tasks are never transferred to
+// user space.
+func WithUserTasksOnly() Option {
+	return func(o *options) {
+		o.filter.kind = filterUserTasks
+	}
+}
+
+// variables returns the names and values of the eBPF filter variables, or an
+// error if the filter is invalid.
+func (f *filter) variables() (map[string]any, error) {
+	if len(f.namePrefix) > maxNamePrefixLen {
+		return nil, fmt.Errorf("task name prefix %q too long, must be at most %d bytes",
+			f.namePrefix, maxNamePrefixLen)
+	}
+	// namespace inode numbers are only 32 bits in-kernel.
+	if f.pidns > math.MaxUint32 {
+		return nil, fmt.Errorf("invalid PID namespace inode number %d", f.pidns)
+	}
+	var prefix [maxNamePrefixLen + 1]byte
+	copy(prefix[:], f.namePrefix)
+	return map[string]any{
+		"filter_cgroup_id":       f.cgroupID,
+		"filter_pidns":           uint32(f.pidns),
+		"filter_uid":             f.uid,
+		"filter_kind":            f.kind,
+		"filter_name_prefix_len": uint32(len(f.namePrefix)),
+		"filter_name_prefix":     prefix,
+	}, nil
+}
+
+// usermem returns true if the options require the sleepable task iterator
+// variant that reads from the user memory of processes.
+func (o *options) usermem() bool {
+	return o.maxCmdlineLen > 0 || o.maxEnvironLen > 0
+}
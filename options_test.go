@@ -0,0 +1,86 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package beesy
+
+import (
+	"strings"
+
+	"github.com/thediveo/beesy/tasks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("options", func() {
+
+	It("defaults to no filtering and no user memory", func() {
+		o := newOptions()
+		Expect(o.usermem()).To(BeFalse())
+		Expect(o.filter.variables()).To(And(
+			HaveKeyWithValue("filter_cgroup_id", uint64(0)),
+			HaveKeyWithValue("filter_pidns", uint32(0)),
+			HaveKeyWithValue("filter_uid", filterAnyUID),
+			HaveKeyWithValue("filter_kind", uint32(0)),
+			HaveKeyWithValue("filter_name_prefix_len", uint32(0)),
+		))
+	})
+
+	It("caps user memory lengths", func() {
+		o := newOptions(WithCmdline(2*MaxUsermemLen), WithEnviron(42))
+		Expect(o.usermem()).To(BeTrue())
+		Expect(o.maxCmdlineLen).To(Equal(uint32(MaxUsermemLen)))
+		Expect(o.maxEnvironLen).To(Equal(uint32(42)))
+	})
+
+	It("sets kernel stack states", func() {
+		Expect(newOptions(WithKernelStacks(tasks.DiskSleep)).kstackStateMask).To(
+			Equal(tasks.StateMask(tasks.DiskSleep)))
+	})
+
+	It("configures filters", func() {
+		o := newOptions(
+			WithCgroupID(42),
+			WithPIDNamespace(4026531836),
+			WithUID(0),
+			WithKthreadsOnly(),
+			WithUserTasksOnly(),
+			WithNamePrefix("kworker/"))
+		vars := Successful(o.filter.variables())
+		Expect(vars).To(And(
+			HaveKeyWithValue("filter_cgroup_id", uint64(42)),
+			HaveKeyWithValue("filter_pidns", uint32(4026531836)),
+			HaveKeyWithValue("filter_uid", uint32(0)),
+			HaveKeyWithValue("filter_kind", uint32(filterUserTasks)),
+			HaveKeyWithValue("filter_name_prefix_len", uint32(8)),
+		))
+		prefix := vars["filter_name_prefix"].([maxNamePrefixLen + 1]byte)
+		Expect(string(prefix[:8])).To(Equal("kworker/"))
+		Expect(prefix[8:]).To(HaveEach(byte(0)))
+	})
+
+	It("rejects invalid PID namespace inode numbers", func() {
+		o := newOptions(WithPIDNamespace(1 << 32))
+		Expect(o.filter.variables()).Error().To(MatchError(ContainSubstring("invalid PID namespace")))
+	})
+
+	It("rejects too long name prefixes", func() {
+		o := newOptions(WithNamePrefix(strings.Repeat("x", maxNamePrefixLen+1)))
+		Expect(o.filter.variables()).Error().To(MatchError(ContainSubstring("too long")))
+		Expect(NewTaskIterator(WithNamePrefix(strings.Repeat("x", maxNamePrefixLen+1)))).Error().To(
+			MatchError(ContainSubstring("invalid task filter")))
+	})
+
+})
@@ -17,10 +17,12 @@
 package pidhorizon
 
 import (
+	"context"
 	"fmt"
 	"maps"
 
 	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/bpfiter"
 	"github.com/thediveo/beesy/constraints"
 	"github.com/thediveo/beesy/internal/iteriter"
 )
@@ -45,10 +47,16 @@ func Reverse[P constraints.PID](m Mapping[P]) Mapping[P] {
 // [NewPIDHorizon.NewMapping]
 func NewPIDHorizon[P constraints.PID]() (*PIDHorizon[P], error) {
 	ph := &PIDHorizon[P]{}
-	if err := loadTaskTidIterObjects(&ph.ebpfObjects, nil); err != nil {
+	spec, err := loadTaskTidIter()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load Task TID iterator eBPF objects, reason: %w", err)
+	}
+	if err := bpfiter.CheckLayout[taskTidIterInfo](spec.Types, "info"); err != nil {
+		return nil, fmt.Errorf("mismatching Task TID iterator record layout, reason: %w", err)
+	}
+	if err := spec.LoadAndAssign(&ph.ebpfObjects, nil); err != nil {
 		return nil, fmt.Errorf("cannot load Task TID iterator eBPF objects, reason: %w", err)
 	}
-	var err error
 	if ph.taskTIDIter, err = link.AttachIter(link.IterOptions{
 		Program: ph.ebpfObjects.DumpTaskTid,
 	}); err != nil {
@@ -75,14 +83,47 @@ func (ph *PIDHorizon[P]) Close() {
 }
 
 // NewMapping returns a new PID/TID mapping from this process's PID namespace to
-// the root PID namespace for all processes/tasks visible to this process.
+// the root PID namespace for all processes/tasks visible to this process. In
+// case of an iterator failure, NewMapping returns the incomplete mapping; use
+// [PIDHorizon.NewMappingContext] in order to learn about such failures.
 func (ph *PIDHorizon[P]) NewMapping() Mapping[P] {
+	m, _ := ph.NewMappingContext(context.Background())
+	return m
+}
+
+// NewMappingContext returns a new PID/TID mapping from this process's PID
+// namespace to the root PID namespace for all processes/tasks visible to this
+// process, see [PIDHorizon.NewMapping]. In case the specified context is done
+// before the mapping is complete, NewMappingContext returns the incomplete
+// mapping together with ctx.Err(). In case of an iterator failure, it returns
+// the incomplete mapping together with the error.
+func (ph *PIDHorizon[P]) NewMappingContext(ctx context.Context) (Mapping[P], error) {
+	return MappingFromSource[P](ctx, ph.taskTIDIter)
+}
+
+// Source returns the source of the raw Task TID iterator output of this
+// PIDHorizon.
+func (ph *PIDHorizon[P]) Source() bpfiter.Source {
+	return ph.taskTIDIter
+}
+
+// MappingFromSource returns a new PID/TID mapping decoded from the raw Task
+// TID iterator output of the specified source, such as replaying a recording
+// made with a [bpfiter.Recorder] from [PIDHorizon.Source] using a
+// [bpfiter.Replayer]. In case the specified context is done before the
+// mapping is complete, MappingFromSource returns the incomplete mapping
+// together with ctx.Err(). Similarly, in case of a read or decoding failure,
+// MappingFromSource returns the incomplete mapping together with the error.
+func MappingFromSource[P constraints.PID](ctx context.Context, src bpfiter.Source) (Mapping[P], error) {
 	m := Mapping[P]{}
-	for taskinfo, err := range iteriter.AllVolatile[taskTidIterInfo](ph.taskTIDIter) {
+	for taskinfo, err := range iteriter.AllVolatileContext[taskTidIterInfo](ctx, src) {
 		if err != nil {
-			break
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return m, ctxErr
+			}
+			return m, fmt.Errorf("cannot decode Task TID iterator output, reason: %w", err)
 		}
 		m[P(taskinfo.Tid)] = P(taskinfo.RootTid)
 	}
-	return m
+	return m, nil
 }
@@ -15,8 +15,12 @@
 package pidhorizon
 
 import (
+	"context"
 	"os"
 	"time"
+	"unsafe"
+
+	"github.com/thediveo/beesy/bpfiter"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -27,6 +31,40 @@ import (
 
 var _ = Describe("PID horizons", func() {
 
+	It("decodes mappings from sources", func() {
+		infos := []taskTidIterInfo{
+			{RootTid: 42, Tid: 1},
+			{RootTid: 666, Tid: 7},
+		}
+		src := bpfiter.BytesSource(unsafe.Slice((*byte)(unsafe.Pointer(&infos[0])),
+			len(infos)*int(unsafe.Sizeof(infos[0]))))
+		Expect(MappingFromSource[int](context.Background(), src)).To(Equal(Mapping[int]{
+			1: 42,
+			7: 666,
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		m, err := MappingFromSource[int](ctx, src)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(m).To(BeEmpty())
+	})
+
+	It("reports incomplete mappings from sources", func() {
+		infos := []taskTidIterInfo{
+			{RootTid: 42, Tid: 1},
+			{RootTid: 666, Tid: 7},
+		}
+		b := unsafe.Slice((*byte)(unsafe.Pointer(&infos[0])), len(infos)*int(unsafe.Sizeof(infos[0])))
+		m, err := MappingFromSource[int](context.Background(), bpfiter.BytesSource(b[:len(b)-1]))
+		Expect(err).To(MatchError(bpfiter.ErrTruncatedRecord))
+		Expect(m).To(Equal(Mapping[int]{1: 42}))
+
+		m, err = MappingFromSource[int](context.Background(), bpfiter.BytesSource(nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m).To(BeEmpty())
+	})
+
 	It("reverses a PID mapping", func() {
 		localToRoot := map[uint32]uint32{
 			1:   42,
@@ -68,6 +106,16 @@ var _ = Describe("PID horizons", func() {
 			Expect(beyond).To(HaveKeyWithValue(int(1), Not(BeZero())), "missing PID 1 (either real PID 1 or local PID 1)")
 		})
 
+		It("stops discovering when the context is done", func() {
+			ph := Successful(NewPIDHorizon[int]())
+			defer ph.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			beyond, err := ph.NewMappingContext(ctx)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(beyond).To(BeEmpty())
+		})
+
 	})
 
 })
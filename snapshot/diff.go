@@ -0,0 +1,132 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/thediveo/beesy"
+)
+
+// Changes lists the differences between two task snapshots, with all lists
+// sorted by TID (and then start time).
+type Changes struct {
+	// Appeared lists the tasks only present in the new snapshot.
+	Appeared []beesy.Task
+	// Exited lists the tasks only present in the old snapshot.
+	Exited []beesy.Task
+	// Reused lists the TIDs reused by new tasks; these tasks are also listed
+	// in Appeared and Exited.
+	Reused []Reuse
+	// Reparented lists the tasks present in both snapshots, but with
+	// different parent processes.
+	Reparented []Reparent
+	// Renamed lists the tasks present in both snapshots, but with different
+	// names.
+	Renamed []Rename
+}
+
+// Reuse describes a TID of an exited task that has been reused by a new
+// task, that is, a TID with different start times in the old and new
+// snapshots.
+type Reuse struct {
+	Old beesy.Task // exited task.
+	New beesy.Task // new task reusing the TID.
+}
+
+// Reparent describes a task that has been re-parented, such as after its
+// parent process exited.
+type Reparent struct {
+	beesy.Task       // task in the new snapshot.
+	OldPPID    int32 // PID of the previous parent process.
+}
+
+// Rename describes a task that has changed its name, such as after an exec
+// or a kthread changing its full name.
+type Rename struct {
+	beesy.Task        // task in the new snapshot.
+	OldName    string // previous task name.
+}
+
+// IsEmpty returns true if there are no changes.
+func (c *Changes) IsEmpty() bool {
+	return len(c.Appeared) == 0 && len(c.Exited) == 0 &&
+		len(c.Reparented) == 0 && len(c.Renamed) == 0
+}
+
+// taskID identifies a task stably, as TIDs might get reused.
+type taskID struct {
+	tid       int32
+	startTime time.Duration
+}
+
+// Diff returns the task changes between the old and new snapshots. See
+// [DiffTasks] for details.
+func Diff(old, new *Snapshot) Changes {
+	return DiffTasks(old.Tasks, new.Tasks)
+}
+
+// DiffTasks returns the changes between the old and new task snapshots. Tasks
+// are identified by their TIDs together with their start times, so that a
+// reused TID is reported as an exited and an appeared task, as well as a
+// [Reuse].
+//
+// Please note that both task snapshots must have been taken on the same node
+// and during the same boot, as task start times are CLOCK_MONOTONIC times.
+func DiffTasks(old, new []beesy.Task) Changes {
+	oldTasks := make(map[taskID]*beesy.Task, len(old))
+	oldTIDs := make(map[int32]*beesy.Task, len(old))
+	for idx := range old {
+		t := &old[idx]
+		oldTasks[taskID{t.TID, t.StartTime}] = t
+		oldTIDs[t.TID] = t
+	}
+	newTasks := make(map[taskID]struct{}, len(new))
+	var c Changes
+	for _, t := range new {
+		id := taskID{t.TID, t.StartTime}
+		newTasks[id] = struct{}{}
+		prev, ok := oldTasks[id]
+		if !ok {
+			c.Appeared = append(c.Appeared, t)
+			if reused, ok := oldTIDs[t.TID]; ok {
+				c.Reused = append(c.Reused, Reuse{Old: *reused, New: t})
+			}
+			continue
+		}
+		if prev.PPID != t.PPID {
+			c.Reparented = append(c.Reparented, Reparent{Task: t, OldPPID: prev.PPID})
+		}
+		if prev.Name != t.Name {
+			c.Renamed = append(c.Renamed, Rename{Task: t, OldName: prev.Name})
+		}
+	}
+	for _, t := range old {
+		if _, ok := newTasks[taskID{t.TID, t.StartTime}]; !ok {
+			c.Exited = append(c.Exited, t)
+		}
+	}
+	byTask := func(a, b beesy.Task) int {
+		return cmp.Or(cmp.Compare(a.TID, b.TID), cmp.Compare(a.StartTime, b.StartTime))
+	}
+	slices.SortFunc(c.Appeared, byTask)
+	slices.SortFunc(c.Exited, byTask)
+	slices.SortFunc(c.Reused, func(a, b Reuse) int { return byTask(a.New, b.New) })
+	slices.SortFunc(c.Reparented, func(a, b Reparent) int { return byTask(a.Task, b.Task) })
+	slices.SortFunc(c.Renamed, func(a, b Rename) int { return byTask(a.Task, b.Task) })
+	return c
+}
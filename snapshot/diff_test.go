@@ -0,0 +1,90 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"time"
+
+	"github.com/thediveo/beesy"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// tsk returns a test task.
+func tsk(tid int32, start int, ppid int32, name string) beesy.Task {
+	return beesy.Task{
+		PID:       tid,
+		TID:       tid,
+		PPID:      ppid,
+		Name:      name,
+		StartTime: time.Duration(start) * time.Second,
+	}
+}
+
+var _ = Describe("snapshot diffs", func() {
+
+	It("reports no changes", func() {
+		tasks := []beesy.Task{tsk(1, 0, 0, "init"), tsk(42, 1, 1, "foo")}
+		c := Diff(&Snapshot{Tasks: tasks}, &Snapshot{Tasks: tasks})
+		Expect(c.IsEmpty()).To(BeTrue())
+		Expect(c).To(BeZero())
+	})
+
+	It("reports changes", func() {
+		old := []beesy.Task{
+			tsk(1, 0, 0, "init"),
+			tsk(2, 0, 0, "kworker/0:1-events"),
+			tsk(42, 1, 1, "foo"),
+			tsk(43, 2, 42, "bar"),
+			tsk(100, 3, 1, "old"),
+			tsk(44, 4, 43, "baz"),
+		}
+		new := []beesy.Task{
+			tsk(200, 7, 1, "new"),
+			tsk(1, 0, 0, "init"),
+			tsk(2, 0, 0, "kworker/0:1-mm_percpu_wq"),
+			tsk(43, 2, 1, "bar"),
+			tsk(100, 6, 1, "reuser"),
+			tsk(44, 4, 1, "qux"),
+			tsk(150, 8, 1, "newer"),
+		}
+		c := DiffTasks(old, new)
+		Expect(c.IsEmpty()).To(BeFalse())
+		Expect(c.Appeared).To(HaveExactElements(
+			HaveField("TID", int32(100)),
+			HaveField("TID", int32(150)),
+			HaveField("TID", int32(200)),
+		))
+		Expect(c.Exited).To(HaveExactElements(
+			HaveField("TID", int32(42)),
+			HaveField("TID", int32(100)),
+		))
+		Expect(c.Reused).To(HaveExactElements(And(
+			HaveField("Old.Name", "old"),
+			HaveField("New.Name", "reuser"),
+		)))
+		Expect(c.Reparented).To(HaveExactElements(
+			And(HaveField("TID", int32(43)), HaveField("PPID", int32(1)), HaveField("OldPPID", int32(42))),
+			And(HaveField("TID", int32(44)), HaveField("PPID", int32(1)), HaveField("OldPPID", int32(43))),
+		))
+		Expect(c.Renamed).To(HaveExactElements(
+			And(HaveField("TID", int32(2)), HaveField("Name", "kworker/0:1-mm_percpu_wq"),
+				HaveField("OldName", "kworker/0:1-events")),
+			And(HaveField("TID", int32(44)), HaveField("Name", "qux"), HaveField("OldName", "baz")),
+		))
+	})
+
+})
@@ -0,0 +1,27 @@
+/*
+Package snapshot takes complete beesy snapshots of a node's process state and
+serializes them to single, compressed, portable snapshot files, such as for
+support bundles.
+
+A [Snapshot] consists of the tasks, the PID mapping from the PID namespace of
+the process taking the snapshot to the initial PID namespace, as well as
+optional enrichments, such as cgroups, sockets, and eBPF objects. Snapshots
+additionally carry [Metadata] about the node and the process taking the
+snapshot, such as the kernel release, boot ID, timestamps, and the namespaces
+of the snapshotting process.
+
+	snap, err := snapshot.Take(ctx,
+		snapshot.WithTaskOptions(beesy.WithCmdline(4096)),
+		snapshot.WithCgroups(cgroups.DefaultRoot))
+	if err != nil {
+		return err
+	}
+	err = snap.Save("node.beesy.gz")
+
+Snapshot files are gzip-compressed JSON documents and load back into the same
+Go types using [Load] or [Read], so that tasks from a loaded snapshot can be
+analyzed offline the same way as tasks from a live task iterator, for instance
+using [github.com/thediveo/beesy/health.Check] together with
+[Metadata.Monotonic].
+*/
+package snapshot
@@ -0,0 +1,146 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/bpfobjects"
+	"github.com/thediveo/beesy/cgroups"
+	"github.com/thediveo/beesy/pidhorizon"
+	"github.com/thediveo/beesy/sockets"
+)
+
+// Snapshot file format identification.
+const (
+	Format  = "beesy-snapshot" // snapshot file format name.
+	Version = 1                // current (and maximum supported) version.
+)
+
+// file is the (uncompressed) JSON representation of a snapshot file.
+type file struct {
+	Format     string
+	Version    int
+	Metadata   Metadata
+	Tasks      []task
+	PIDMapping pidhorizon.Mapping[int32]
+	Cgroups    []cgroups.Cgroup      `json:",omitempty"`
+	Sockets    []sockets.Socket      `json:",omitempty"`
+	BPFObjects *bpfobjects.Inventory `json:",omitempty"`
+}
+
+// task is the JSON representation of a task, including its optional data not
+// accessible through (exported) fields.
+type task struct {
+	beesy.Task
+	KernelStack []uint64 `json:",omitempty"`
+	Cmdline     []string `json:",omitempty"`
+	Environ     []string `json:",omitempty"`
+}
+
+// Save writes this snapshot to a snapshot file at the specified path,
+// overwriting any existing file.
+func (s *Snapshot) Save(path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot save snapshot, reason: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("cannot save snapshot, reason: %w", cerr)
+		}
+	}()
+	return s.Write(f)
+}
+
+// Write writes this snapshot in snapshot file format to w.
+func (s *Snapshot) Write(w io.Writer) error {
+	f := file{
+		Format:     Format,
+		Version:    Version,
+		Metadata:   s.Metadata,
+		Tasks:      make([]task, 0, len(s.Tasks)),
+		PIDMapping: s.PIDMapping,
+		Cgroups:    s.Cgroups,
+		Sockets:    s.Sockets,
+		BPFObjects: s.BPFObjects,
+	}
+	for _, t := range s.Tasks {
+		f.Tasks = append(f.Tasks, task{
+			Task:        t,
+			KernelStack: t.KernelStack(),
+			Cmdline:     t.Cmdline(),
+			Environ:     t.Environ(),
+		})
+	}
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(&f); err != nil {
+		return fmt.Errorf("cannot write snapshot, reason: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("cannot write snapshot, reason: %w", err)
+	}
+	return nil
+}
+
+// ErrInvalidSnapshot signals an invalid or unsupported snapshot file.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// Load returns the snapshot read from the snapshot file at the specified path.
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load snapshot, reason: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read returns the snapshot read in snapshot file format from r.
+func Read(r io.Reader) (*Snapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w, reason: %w", ErrInvalidSnapshot, err)
+	}
+	defer zr.Close()
+	var f file
+	if err := json.NewDecoder(zr).Decode(&f); err != nil {
+		return nil, fmt.Errorf("%w, reason: %w", ErrInvalidSnapshot, err)
+	}
+	if f.Format != Format {
+		return nil, fmt.Errorf("%w, reason: unknown format %q", ErrInvalidSnapshot, f.Format)
+	}
+	if f.Version < 1 || f.Version > Version {
+		return nil, fmt.Errorf("%w, reason: unsupported version %d", ErrInvalidSnapshot, f.Version)
+	}
+	s := &Snapshot{
+		Metadata:   f.Metadata,
+		Tasks:      make([]beesy.Task, 0, len(f.Tasks)),
+		PIDMapping: f.PIDMapping,
+		Cgroups:    f.Cgroups,
+		Sockets:    f.Sockets,
+		BPFObjects: f.BPFObjects,
+	}
+	for _, t := range f.Tasks {
+		s.Tasks = append(s.Tasks, t.WithOptionalData(t.KernelStack, t.Cmdline, t.Environ))
+	}
+	return s, nil
+}
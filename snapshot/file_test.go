@@ -0,0 +1,123 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/netip"
+	"path/filepath"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/bpfobjects"
+	"github.com/thediveo/beesy/cgroups"
+	"github.com/thediveo/beesy/sockets"
+	"github.com/thediveo/beesy/tasks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// testSnapshot returns a snapshot with all optional parts.
+func testSnapshot() *Snapshot {
+	return &Snapshot{
+		Metadata: Metadata{
+			Timestamp:     time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC),
+			Monotonic:     42 * time.Hour,
+			Hostname:      "node-1",
+			KernelRelease: "6.12.0-1-amd64",
+			BootID:        "4c3bb4ba-0b53-4a57-9e2f-6b2a8a4f0e6e",
+			PID:           1234,
+			Namespaces:    beesy.Namespaces{PID: 4026531836, Net: 4026531840},
+		},
+		Tasks: []beesy.Task{
+			{PID: 1, TID: 1, Name: "init", State: tasks.Sleeping, StartTime: time.Second},
+			beesy.Task{PID: 42, TID: 43, PPID: 1, Name: "foo", State: tasks.Running, TTY: 0x8801}.
+				WithOptionalData([]uint64{0xffffffff81a2d1f0}, []string{"/bin/foo", "--bar"}, []string{"FOO=bar"}),
+			{PID: 2, TID: 2, Name: "kthreadd", Kthread: true, CPU: -1, Node: -1},
+		},
+		PIDMapping: map[int32]int32{1: 1, 42: 42},
+		Cgroups: []cgroups.Cgroup{
+			{ID: 1, Path: "/sys/fs/cgroup", Procs: 1, Threads: 1},
+			{ID: 2, ParentID: 1, Path: "/sys/fs/cgroup/init.scope", Level: 1},
+		},
+		Sockets: []sockets.Socket{
+			{
+				Protocol: sockets.TCP,
+				Local:    netip.MustParseAddrPort("127.0.0.1:8080"),
+				Owners:   []sockets.Owner{{PID: 42, LocalPID: 42, FD: 3}},
+			},
+			{Protocol: sockets.Unix, Path: "@foo", Peer: 666},
+		},
+		BPFObjects: &bpfobjects.Inventory{
+			Programs: []bpfobjects.Program{{ID: 1, Name: "dump_tasks"}},
+		},
+	}
+}
+
+var _ = Describe("snapshot files", func() {
+
+	It("round-trips snapshots", func() {
+		snap := testSnapshot()
+		var buff bytes.Buffer
+		Expect(snap.Write(&buff)).To(Succeed())
+		loaded := Successful(Read(&buff))
+		Expect(loaded).To(Equal(snap))
+		Expect(loaded.Tasks[1].Cmdline()).To(Equal([]string{"/bin/foo", "--bar"}))
+		Expect(loaded.Tasks[1].Environ()).To(Equal([]string{"FOO=bar"}))
+		Expect(loaded.Tasks[1].KernelStack()).To(Equal([]uint64{0xffffffff81a2d1f0}))
+	})
+
+	It("round-trips minimal snapshots", func() {
+		snap := &Snapshot{Tasks: []beesy.Task{}, PIDMapping: map[int32]int32{}}
+		var buff bytes.Buffer
+		Expect(snap.Write(&buff)).To(Succeed())
+		Expect(Read(&buff)).To(Equal(snap))
+	})
+
+	It("saves and loads snapshot files", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "snapshot.beesy.gz")
+		snap := testSnapshot()
+		Expect(snap.Save(path)).To(Succeed())
+		Expect(Load(path)).To(Equal(snap))
+
+		Expect(snap.Save("/nada/snapshot.beesy.gz")).To(MatchError(ContainSubstring("cannot save snapshot")))
+		Expect(Load("/nada/snapshot.beesy.gz")).Error().To(MatchError(ContainSubstring("cannot load snapshot")))
+	})
+
+	DescribeTable("rejects invalid snapshots",
+		func(contents string, compressed bool, expected string) {
+			var buff bytes.Buffer
+			if compressed {
+				zw := gzip.NewWriter(&buff)
+				_, _ = zw.Write([]byte(contents))
+				Expect(zw.Close()).To(Succeed())
+			} else {
+				buff.WriteString(contents)
+			}
+			Expect(Read(&buff)).Error().To(And(
+				MatchError(ErrInvalidSnapshot),
+				MatchError(ContainSubstring(expected))))
+		},
+		Entry("not compressed", `{"Format":"beesy-snapshot"}`, false, "gzip"),
+		Entry("not JSON", `D'OH!`, true, "invalid character"),
+		Entry("unknown format", `{"Format":"foo","Version":1}`, true, `unknown format "foo"`),
+		Entry("unsupported version", `{"Format":"beesy-snapshot","Version":2}`, true, "unsupported version 2"),
+		Entry("missing version", `{"Format":"beesy-snapshot"}`, true, "unsupported version 0"),
+	)
+
+})
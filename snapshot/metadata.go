@@ -0,0 +1,109 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"golang.org/x/sys/unix"
+)
+
+// Metadata describes the node and the process a snapshot was taken on and
+// by.
+type Metadata struct {
+	// Timestamp is the wall clock time when the snapshot was taken.
+	Timestamp time.Time
+	// Monotonic is the CLOCK_MONOTONIC time when the snapshot was taken, to
+	// relate the task start and last ran times to.
+	Monotonic time.Duration
+	// Hostname of the node, as seen by the snapshotting process.
+	Hostname string
+	// KernelRelease of the node, such as “6.12.0-1-amd64”.
+	KernelRelease string
+	// BootID is the random ID of the node's current boot, telling apart
+	// snapshots of different boots.
+	BootID string
+	// PID of the snapshotting process in its own PID namespace.
+	PID int32
+	// Namespaces of the snapshotting process.
+	Namespaces beesy.Namespaces
+}
+
+// bootIDPath is the path of the random boot ID.
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// newMetadata returns the metadata of the node and calling process, with the
+// specified timestamp.
+func newMetadata(now time.Time) (Metadata, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return Metadata{}, fmt.Errorf("cannot read monotonic clock, reason: %w", err)
+	}
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return Metadata{}, fmt.Errorf("cannot determine kernel release, reason: %w", err)
+	}
+	bootID, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("cannot determine boot ID, reason: %w", err)
+	}
+	namespaces, err := ownNamespaces()
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{
+		Timestamp:     now,
+		Monotonic:     time.Duration(ts.Nano()),
+		Hostname:      unix.ByteSliceToString(uts.Nodename[:]),
+		KernelRelease: unix.ByteSliceToString(uts.Release[:]),
+		BootID:        strings.TrimSpace(string(bootID)),
+		PID:           int32(os.Getpid()),
+		Namespaces:    namespaces,
+	}, nil
+}
+
+// ownNamespaces returns the namespaces of the calling process. Namespace
+// types unsupported by the kernel have zero inode numbers.
+func ownNamespaces() (beesy.Namespaces, error) {
+	var namespaces beesy.Namespaces
+	for _, ns := range []struct {
+		name string
+		ino  *uint64
+	}{
+		{"cgroup", &namespaces.Cgroup},
+		{"ipc", &namespaces.IPC},
+		{"mnt", &namespaces.Mnt},
+		{"net", &namespaces.Net},
+		{"pid", &namespaces.PID},
+		{"time", &namespaces.Time},
+		{"user", &namespaces.User},
+		{"uts", &namespaces.UTS},
+	} {
+		var stat unix.Stat_t
+		if err := unix.Stat("/proc/self/ns/"+ns.name, &stat); err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				continue
+			}
+			return beesy.Namespaces{}, fmt.Errorf("cannot determine %s namespace, reason: %w", ns.name, err)
+		}
+		*ns.ino = stat.Ino
+	}
+	return namespaces, nil
+}
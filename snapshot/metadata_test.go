@@ -0,0 +1,47 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("metadata", func() {
+
+	It("describes the node and process", func() {
+		now := time.Now()
+		md := Successful(newMetadata(now))
+		Expect(md.Timestamp).To(Equal(now))
+		Expect(md.Monotonic).NotTo(BeZero())
+		Expect(md.KernelRelease).NotTo(BeEmpty())
+		Expect(md.BootID).To(MatchRegexp(`^[0-9a-f-]{36}$`))
+		Expect(md.Hostname).To(Equal(Successful(os.Hostname())))
+		Expect(md.PID).To(Equal(int32(os.Getpid())))
+
+		var stat unix.Stat_t
+		Expect(unix.Stat("/proc/self/ns/pid", &stat)).To(Succeed())
+		Expect(md.Namespaces.PID).To(Equal(stat.Ino))
+		Expect(md.Namespaces.Mnt).NotTo(BeZero())
+		Expect(md.Namespaces.User).NotTo(BeZero())
+	})
+
+})
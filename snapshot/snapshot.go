@@ -0,0 +1,209 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/bpfobjects"
+	"github.com/thediveo/beesy/cgroups"
+	"github.com/thediveo/beesy/pidhorizon"
+	"github.com/thediveo/beesy/sockets"
+)
+
+// Snapshot is a complete snapshot of a node's process state.
+type Snapshot struct {
+	Metadata Metadata
+	// Tasks visible to the process taking the snapshot.
+	Tasks []beesy.Task
+	// PIDMapping maps the PIDs/TIDs in the PID namespace of the process taking
+	// the snapshot to the initial PID namespace.
+	PIDMapping pidhorizon.Mapping[int32]
+	// Cgroups in pre-order, if requested using [WithCgroups].
+	Cgroups []cgroups.Cgroup
+	// Sockets, if requested using [WithSockets].
+	Sockets []sockets.Socket
+	// BPFObjects are the loaded eBPF objects, if requested using
+	// [WithBPFObjects].
+	BPFObjects *bpfobjects.Inventory
+}
+
+// Option configures taking a [Snapshot] using [Take].
+type Option func(*options)
+
+type options struct {
+	taskOpts   []beesy.Option
+	cgroupRoot string
+	sockets    bool
+	protocols  []sockets.Protocol
+	bpfObjects bool
+}
+
+// WithTaskOptions configures the task iterator used to take the task
+// snapshot, such as requesting command lines or kernel stacks.
+func WithTaskOptions(opts ...beesy.Option) Option {
+	return func(o *options) {
+		o.taskOpts = append(o.taskOpts, opts...)
+	}
+}
+
+// WithCgroups adds the cgroup at the specified path in the cgroup2
+// filesystem, such as [cgroups.DefaultRoot], and all its descendants to the
+// snapshot.
+func WithCgroups(root string) Option {
+	return func(o *options) {
+		o.cgroupRoot = root
+	}
+}
+
+// WithSockets adds the sockets of the specified protocols to the snapshot,
+// defaulting to all supported protocols.
+func WithSockets(protocols ...sockets.Protocol) Option {
+	return func(o *options) {
+		o.sockets = true
+		o.protocols = protocols
+	}
+}
+
+// WithBPFObjects adds the inventory of loaded eBPF programs, maps, and links
+// to the snapshot.
+func WithBPFObjects() Option {
+	return func(o *options) {
+		o.bpfObjects = true
+	}
+}
+
+// Take takes a new snapshot, configured using the specified options. Taking
+// snapshots requires the same privileges as the individual beesy iterators
+// involved. When the specified context is done before the snapshot is
+// complete, Take stops the iterator currently running and returns an error
+// wrapping ctx.Err().
+func Take(ctx context.Context, opts ...Option) (*Snapshot, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	metadata, err := newMetadata(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Metadata: metadata}
+	if snap.Tasks, err = takeTasks(ctx, o.taskOpts); err != nil {
+		return nil, err
+	}
+	if snap.PIDMapping, err = takePIDMapping(ctx); err != nil {
+		return nil, err
+	}
+	if o.cgroupRoot != "" {
+		if snap.Cgroups, err = takeCgroups(ctx, o.cgroupRoot); err != nil {
+			return nil, err
+		}
+	}
+	if o.sockets {
+		if snap.Sockets, err = takeSockets(ctx, o.protocols); err != nil {
+			return nil, err
+		}
+	}
+	if o.bpfObjects {
+		if snap.BPFObjects, err = takeBPFObjects(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// takeTasks returns the tasks visible to the caller.
+func takeTasks(ctx context.Context, opts []beesy.Option) ([]beesy.Task, error) {
+	ti, err := beesy.NewTaskIterator(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot tasks, reason: %w", err)
+	}
+	defer ti.Close()
+	var tasks []beesy.Task
+	for task, err := range ti.AllContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("cannot snapshot tasks, reason: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// takePIDMapping returns the PID/TID mapping from the caller's PID namespace
+// to the initial PID namespace.
+func takePIDMapping(ctx context.Context) (pidhorizon.Mapping[int32], error) {
+	ph, err := pidhorizon.NewPIDHorizon[int32]()
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot PID mapping, reason: %w", err)
+	}
+	defer ph.Close()
+	m, err := ph.NewMappingContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot PID mapping, reason: %w", err)
+	}
+	return m, nil
+}
+
+// takeCgroups returns the cgroup at the specified path and all its
+// descendants in pre-order.
+func takeCgroups(ctx context.Context, root string) ([]cgroups.Cgroup, error) {
+	w, err := cgroups.NewWalker()
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot cgroups, reason: %w", err)
+	}
+	defer w.Close()
+	var cgrps []cgroups.Cgroup
+	for cgroup, err := range w.AllContext(ctx, root, cgroups.PreOrder) {
+		if err != nil {
+			return nil, fmt.Errorf("cannot snapshot cgroups, reason: %w", err)
+		}
+		cgrps = append(cgrps, cgroup)
+	}
+	return cgrps, nil
+}
+
+// takeSockets returns the sockets of the specified protocols.
+func takeSockets(ctx context.Context, protocols []sockets.Protocol) ([]sockets.Socket, error) {
+	si, err := sockets.NewSocketIterator()
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot sockets, reason: %w", err)
+	}
+	defer si.Close()
+	var socks []sockets.Socket
+	for sock, err := range si.AllContext(ctx, protocols...) {
+		if err != nil {
+			return nil, fmt.Errorf("cannot snapshot sockets, reason: %w", err)
+		}
+		socks = append(socks, sock)
+	}
+	return socks, nil
+}
+
+// takeBPFObjects returns the inventory of loaded eBPF objects.
+func takeBPFObjects(ctx context.Context) (*bpfobjects.Inventory, error) {
+	it, err := bpfobjects.NewIterator()
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot eBPF objects, reason: %w", err)
+	}
+	defer it.Close()
+	inv, err := it.InventoryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot eBPF objects, reason: %w", err)
+	}
+	return &inv, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package snapshot
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/cgroups"
+	"github.com/thediveo/beesy/tasks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("snapshots", func() {
+
+	BeforeEach(func() {
+		if os.Getuid() != 0 {
+			Skip("needs root")
+		}
+
+		goodgos := Goroutines()
+		goodfds := Filedescriptors()
+		DeferCleanup(func() {
+			Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+				ShouldNot(HaveLeaked(goodgos))
+			Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+		})
+	})
+
+	It("takes snapshots", func() {
+		snap := Successful(Take(context.Background(),
+			WithTaskOptions(beesy.WithKernelStacks(tasks.Sleeping)),
+			WithSockets(),
+			WithBPFObjects()))
+		Expect(snap.Metadata.PID).To(Equal(int32(os.Getpid())))
+		Expect(snap.Tasks).To(ContainElement(HaveField("Name", "kthreadd")))
+		Expect(snap.PIDMapping).To(HaveKey(int32(os.Getpid())))
+		Expect(snap.Cgroups).To(BeEmpty())
+		Expect(snap.Sockets).NotTo(BeNil())
+		Expect(snap.BPFObjects).NotTo(BeNil())
+	})
+
+	It("takes cgroup snapshots", func() {
+		snap := Successful(Take(context.Background(), WithCgroups(cgroups.DefaultRoot)))
+		Expect(snap.Cgroups).NotTo(BeEmpty())
+	})
+
+	It("stops when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(Take(ctx)).Error().To(MatchError(context.Canceled))
+	})
+
+})
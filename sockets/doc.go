@@ -0,0 +1,7 @@
+/*
+Package sockets provides iterating over the TCP, UDP, and unix domain sockets
+of the caller's network namespace in a single pass using the kernel's eBPF
+socket iterators. Sockets are attributed to the processes owning them, based on
+iterating over the file descriptors of all processes (visible to the caller).
+*/
+package sockets
@@ -0,0 +1,170 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sockets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+
+	"github.com/thediveo/beesy"
+	"golang.org/x/sys/unix"
+)
+
+// NetNSSockets lists the sockets of a single network namespace, together with
+// the processes attached to this network namespace.
+type NetNSSockets struct {
+	NetNS     uint64   // inode number of the network namespace.
+	Processes []int32  // PIDs of the processes attached to the network namespace.
+	Sockets   []Socket // sockets of the network namespace.
+}
+
+// errNetNSGone signals that a network namespace couldn't be entered via any of
+// its processes anymore.
+var errNetNSGone = errors.New("network namespace gone")
+
+// AllNetNS returns an iterator over the sockets of the specified protocols in
+// all network namespaces found in the specified task snapshot, ordered by
+// network namespace inode numbers. If no protocols are specified, AllNetNS
+// iterates over the sockets of all supported protocols.
+//
+// As the kernel's socket iterators only see the sockets of the network
+// namespace they were opened in, AllNetNS temporarily switches a locked OS
+// thread into each network namespace in turn, using the “/proc/$PID/ns/net”
+// of the processes attached to a network namespace. The PIDs of the task
+// snapshot thus need to be valid in the caller's PID namespace, which is the
+// case when the caller is in the initial PID namespace. Network namespaces
+// that cannot be entered anymore, because all their processes have
+// terminated in the meantime, are silently skipped. Switching network
+// namespaces requires CAP_SYS_ADMIN.
+func (si *SocketIterator) AllNetNS(snapshot []beesy.Task, protocols ...Protocol) iter.Seq2[NetNSSockets, error] {
+	if len(protocols) == 0 {
+		protocols = []Protocol{TCP, UDP, Unix}
+	}
+	return func(yield func(NetNSSockets, error) bool) {
+		netnses := netnsProcesses(snapshot)
+		owners, err := si.Owners()
+		if err != nil {
+			yield(NetNSSockets{}, err)
+			return
+		}
+		for _, netns := range slices.Sorted(maps.Keys(netnses)) {
+			nss := NetNSSockets{
+				NetNS:     netns,
+				Processes: netnses[netns],
+			}
+			err := inNetNS(netns, nss.Processes, func() error {
+				for _, proto := range protocols {
+					for sock, err := range si.sockets(context.Background(), proto) {
+						if err != nil {
+							return fmt.Errorf("cannot iterate %s sockets, reason: %w", proto, err)
+						}
+						sock.attribute(owners)
+						nss.Sockets = append(nss.Sockets, sock)
+					}
+				}
+				return nil
+			})
+			if errors.Is(err, errNetNSGone) {
+				continue
+			}
+			if err != nil {
+				yield(NetNSSockets{}, fmt.Errorf("cannot iterate sockets in net:[%d], reason: %w", netns, err))
+				return
+			}
+			if !yield(nss, nil) {
+				return
+			}
+		}
+	}
+}
+
+// netnsProcesses returns the PIDs of the processes attached to the network
+// namespaces found in the specified task snapshot, indexed by network
+// namespace inode numbers.
+func netnsProcesses(snapshot []beesy.Task) map[uint64][]int32 {
+	netnses := map[uint64][]int32{}
+	for _, task := range snapshot {
+		if task.PID != task.TID || task.Namespaces.Net == 0 {
+			continue
+		}
+		netnses[task.Namespaces.Net] = append(netnses[task.Namespaces.Net], task.PID)
+	}
+	return netnses
+}
+
+// inNetNS runs fn on a locked OS thread that has been switched into the network
+// namespace with the specified inode number, using the first process from
+// pids that is still attached to this network namespace. If none of the
+// processes can be used anymore, inNetNS returns errNetNSGone.
+func inNetNS(netns uint64, pids []int32, fn func() error) error {
+	netnsf, err := openNetNS(netns, pids)
+	if err != nil {
+		return err
+	}
+	defer netnsf.Close()
+
+	result := make(chan error)
+	go func() {
+		// We only ever unlock the OS thread after having successfully
+		// switched back into our original network namespace; otherwise, the
+		// tainted OS thread gets thrown away when this goroutine terminates
+		// while still being locked to it.
+		runtime.LockOSThread()
+		origf, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			result <- fmt.Errorf("cannot determine current network namespace, reason: %w", err)
+			return
+		}
+		defer origf.Close()
+		if err := unix.Setns(int(netnsf.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			result <- fmt.Errorf("cannot enter network namespace, reason: %w", err)
+			return
+		}
+		err = fn()
+		if unix.Setns(int(origf.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		result <- err
+	}()
+	return <-result
+}
+
+// openNetNS opens the network namespace with the specified inode number via
+// the first process from pids that is still attached to it.
+func openNetNS(netns uint64, pids []int32) (*os.File, error) {
+	for _, pid := range pids {
+		f, err := os.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+		if err != nil {
+			continue
+		}
+		// guard against the process having moved to a different network
+		// namespace, or its PID having been reused in the meantime.
+		var stat unix.Stat_t
+		if err := unix.Fstat(int(f.Fd()), &stat); err != nil || stat.Ino != netns {
+			f.Close()
+			continue
+		}
+		return f, nil
+	}
+	return nil, errNetNSGone
+}
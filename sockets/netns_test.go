@@ -0,0 +1,105 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sockets
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("network namespace sockets", func() {
+
+	It("maps network namespaces to their processes", func() {
+		Expect(netnsProcesses([]beesy.Task{
+			{PID: 1, TID: 1, Namespaces: beesy.Namespaces{Net: 42}},
+			{PID: 1, TID: 2, Namespaces: beesy.Namespaces{Net: 42}},
+			{PID: 3, TID: 3, Namespaces: beesy.Namespaces{Net: 666}},
+			{PID: 4, TID: 4, Namespaces: beesy.Namespaces{Net: 42}},
+			{PID: 5, TID: 5},
+		})).To(Equal(map[uint64][]int32{
+			42:  {1, 4},
+			666: {3},
+		}))
+	})
+
+	It("doesn't open a network namespace via the wrong processes", func() {
+		Expect(openNetNS(0, []int32{int32(os.Getpid())})).Error().To(MatchError(errNetNSGone))
+		Expect(openNetNS(0, []int32{-1})).Error().To(MatchError(errNetNSGone))
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(10 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("finds its own listening socket in its own network namespace", func() {
+			tcpl := Successful(net.Listen("tcp", "127.0.0.1:0"))
+			defer tcpl.Close()
+			tcpaddr := tcpl.Addr().(*net.TCPAddr).AddrPort()
+
+			var stat unix.Stat_t
+			Expect(unix.Stat("/proc/self/ns/net", &stat)).To(Succeed())
+			ownNetNS := stat.Ino
+
+			ti := Successful(beesy.NewTaskIterator())
+			defer ti.Close()
+			var snapshot []beesy.Task
+			for task, err := range ti.All() {
+				Expect(err).NotTo(HaveOccurred())
+				snapshot = append(snapshot, task)
+			}
+
+			si := Successful(NewSocketIterator())
+			defer si.Close()
+
+			found := false
+			for nss, err := range si.AllNetNS(snapshot, TCP) {
+				Expect(err).NotTo(HaveOccurred())
+				if nss.NetNS != ownNetNS {
+					continue
+				}
+				Expect(nss.Processes).To(ContainElement(int32(os.Getpid())))
+				Expect(nss.Sockets).To(ContainElement(And(
+					HaveField("Local", tcpaddr),
+					HaveField("Owners", ContainElement(HaveField("PID", int32(os.Getpid())))))))
+				found = true
+			}
+			Expect(found).To(BeTrue(), "missing own network namespace")
+		})
+
+	})
+
+})
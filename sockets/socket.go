@@ -0,0 +1,120 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sockets
+
+import (
+	"net/netip"
+	"strconv"
+
+	"github.com/thediveo/beesy/internal/owner"
+)
+
+// Protocol of a socket.
+type Protocol uint8
+
+// Supported socket protocols.
+const (
+	TCP Protocol = iota + 1
+	UDP
+	Unix
+)
+
+// String returns the protocol name in lower case, as used by ss(8).
+func (p Protocol) String() string {
+	switch p {
+	case TCP:
+		return "tcp"
+	case UDP:
+		return "udp"
+	case Unix:
+		return "unix"
+	}
+	return "Protocol(" + strconv.FormatUint(uint64(p), 10) + ")"
+}
+
+// State of a socket. The kernel uses the TCP states also for UDP and unix
+// domain sockets.
+type State uint8
+
+// Socket states, see also:
+// https://elixir.bootlin.com/linux/v6.12/source/include/net/tcp_states.h#L12
+const (
+	Established State = iota + 1
+	SynSent
+	SynRecv
+	FinWait1
+	FinWait2
+	TimeWait
+	Close
+	CloseWait
+	LastAck
+	Listen
+	Closing
+	NewSynRecv
+)
+
+// https://git.kernel.org/pub/scm/network/iproute2/iproute2.git/tree/misc/ss.c#n1381
+var stateNames = [...]string{
+	Established: "ESTAB",
+	SynSent:     "SYN-SENT",
+	SynRecv:     "SYN-RECV",
+	FinWait1:    "FIN-WAIT-1",
+	FinWait2:    "FIN-WAIT-2",
+	TimeWait:    "TIME-WAIT",
+	Close:       "UNCONN",
+	CloseWait:   "CLOSE-WAIT",
+	LastAck:     "LAST-ACK",
+	Listen:      "LISTEN",
+	Closing:     "CLOSING",
+	NewSynRecv:  "NEW-SYN-RECV",
+}
+
+// String returns the state name in the same form as ss(8) does, such as
+// “ESTAB” or “LISTEN”.
+func (s State) String() string {
+	if s == 0 || int(s) >= len(stateNames) {
+		return "State(" + strconv.FormatUint(uint64(s), 10) + ")"
+	}
+	return stateNames[s]
+}
+
+// Socket describes a single TCP, UDP, or unix domain socket, together with
+// the processes owning it.
+type Socket struct {
+	Protocol Protocol
+	Family   uint16 // AF_INET, AF_INET6, or AF_UNIX.
+	Type     uint16 // SOCK_STREAM, SOCK_DGRAM, or SOCK_SEQPACKET.
+	State    State
+	Local    netip.AddrPort // local address and port of TCP and UDP sockets.
+	Remote   netip.AddrPort // remote address and port of TCP and UDP sockets.
+	Path     string         // unix domain socket path; abstract names start with “@”.
+	Inode    uint64         // socket inode number, or zero.
+	RxQueue  uint32         // receive queue size, or current backlog of listening TCP sockets.
+	TxQueue  uint32         // send queue size, or maximum backlog of listening TCP sockets.
+	UID      uint32         // socket owner UID.
+	Owners   []Owner        // processes owning this socket.
+
+	// Peer is the inode number of the peer socket of a connected unix domain
+	// socket, or zero.
+	Peer uint64
+	// PeerOwners are the processes owning the peer socket of a connected
+	// unix domain socket.
+	PeerOwners []Owner
+}
+
+// Owner describes a process owning a socket through one of its file
+// descriptors. A socket might be owned by multiple processes, and a process
+// might own the same socket through multiple file descriptors.
+type Owner = owner.Owner
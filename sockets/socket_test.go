@@ -0,0 +1,39 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sockets
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("socket descriptions", func() {
+
+	It("stringifies protocols", func() {
+		Expect(TCP.String()).To(Equal("tcp"))
+		Expect(UDP.String()).To(Equal("udp"))
+		Expect(Unix.String()).To(Equal("unix"))
+		Expect(Protocol(42).String()).To(Equal("Protocol(42)"))
+	})
+
+	It("stringifies states", func() {
+		Expect(Established.String()).To(Equal("ESTAB"))
+		Expect(Listen.String()).To(Equal("LISTEN"))
+		Expect(Close.String()).To(Equal("UNCONN"))
+		Expect(State(0).String()).To(Equal("State(0)"))
+		Expect(State(42).String()).To(Equal("State(42)"))
+	})
+
+})
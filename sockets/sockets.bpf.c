@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: GPL-2.0
+
+// Note: This file is licenced differently from the rest of the project
+// Copyright 2025 Harald Albrecht
+
+//go:build ignore
+
+#include "sock.h"
+#include "tid_current_pidns.h"
+
+char __license[] SEC("license") = "GPL";
+
+// inet_sock_info defines the binary representation of TCP and UDP sockets.
+struct inet_sock_info {
+    __u64 inode;      // socket inode number, or 0
+    __u8  src[16];    // local address; IPv4 addresses use only the first 4 bytes
+    __u8  dst[16];    // remote address; IPv4 addresses use only the first 4 bytes
+    __u32 rx_queue;   // receive queue size, or current listen backlog
+    __u32 tx_queue;   // send queue size, or maximum listen backlog
+    __u32 uid;        // socket owner UID
+    __u16 family;     // AF_INET or AF_INET6
+    __u16 src_port;
+    __u16 dst_port;
+    __u8  state;
+};
+
+// unix_sock_info defines the binary representation of unix domain sockets.
+struct unix_sock_info {
+    __u64 inode;      // socket inode number, or 0
+    __u64 peer_inode; // inode number of peer socket, or 0
+    __u32 rx_queue;
+    __u32 tx_queue;
+    __u32 uid;        // socket owner UID
+    __u16 type;       // SOCK_STREAM, SOCK_DGRAM, SOCK_SEQPACKET
+    __u8  state;
+    __u8  path_len;   // length of path, zero if unbound
+    char  path[UNIX_PATH_MAX]; // abstract names start with a zero byte
+};
+
+// socket_fd_info defines the binary representation of socket file
+// descriptors owned by processes.
+struct socket_fd_info {
+    __u64 inode;      // socket inode number
+    int   pid;        // owning process' PID in the initial PID namespace
+    int   local_pid;  // owning process' PID in the caller's PID namespace, or 0
+    __u32 fd;
+};
+
+const struct inet_sock_info _meh_inet __attribute__((unused)); // force emitting struct inet_sock_info
+const struct unix_sock_info _meh_unix __attribute__((unused)); // force emitting struct unix_sock_info
+const struct socket_fd_info _meh_fd __attribute__((unused)); // force emitting struct socket_fd_info
+
+/*
+ * fill_inet_sock_info fills in the socket family, state, addresses, and ports
+ * from the specified sock_common.
+ */
+void fill_inet_sock_info(struct sock_common *skc, struct inet_sock_info *info)
+{
+    __builtin_memset(info, 0, sizeof(*info));
+    info->family = BPF_CORE_READ(skc, skc_family);
+    info->state = BPF_CORE_READ(skc, skc_state);
+    info->src_port = BPF_CORE_READ(skc, skc_num);
+    info->dst_port = bpf_ntohs(BPF_CORE_READ(skc, skc_dport));
+    if (info->family == AF_INET6) {
+        BPF_CORE_READ_INTO(&info->src, skc, skc_v6_rcv_saddr.in6_u.u6_addr8);
+        BPF_CORE_READ_INTO(&info->dst, skc, skc_v6_daddr.in6_u.u6_addr8);
+    } else {
+        __be32 addr = BPF_CORE_READ(skc, skc_rcv_saddr);
+        __builtin_memcpy(info->src, &addr, sizeof(addr));
+        addr = BPF_CORE_READ(skc, skc_daddr);
+        __builtin_memcpy(info->dst, &addr, sizeof(addr));
+    }
+}
+
+SEC("iter/tcp")
+int dump_tcp(struct bpf_iter__tcp *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct sock_common *skc = ctx->sk_common;
+    if (skc == NULL) {
+        return 0;
+    }
+
+    struct inet_sock_info info;
+    fill_inet_sock_info(skc, &info);
+    info.uid = ctx->uid;
+
+    // time-wait and request socks are no full TCP socks and thus have neither
+    // queues nor inodes.
+    struct tcp_sock *tp = bpf_skc_to_tcp_sock(skc);
+    if (tp != NULL) {
+        struct sock *sk = (struct sock *) tp;
+        info.inode = sock_i_ino(sk);
+        if (info.state == TCP_LISTEN) {
+            info.rx_queue = BPF_CORE_READ(sk, sk_ack_backlog);
+            info.tx_queue = BPF_CORE_READ(sk, sk_max_ack_backlog);
+        } else {
+            info.rx_queue = BPF_CORE_READ(tp, rcv_nxt) - BPF_CORE_READ(tp, copied_seq);
+            info.tx_queue = BPF_CORE_READ(tp, write_seq) - BPF_CORE_READ(tp, snd_una);
+        }
+    }
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
+
+SEC("iter/udp")
+int dump_udp(struct bpf_iter__udp *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct udp_sock *udp_sk = ctx->udp_sk;
+    if (udp_sk == NULL) {
+        return 0;
+    }
+    struct sock *sk = (struct sock *) udp_sk;
+
+    struct inet_sock_info info;
+    fill_inet_sock_info(&sk->__sk_common, &info);
+    info.uid = ctx->uid;
+    info.inode = sock_i_ino(sk);
+    info.rx_queue = BPF_CORE_READ(sk, sk_rmem_alloc.counter);
+    info.tx_queue = BPF_CORE_READ(sk, sk_wmem_alloc.refs.counter) - 1;
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
+
+SEC("iter/unix")
+int dump_unix(struct bpf_iter__unix *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct unix_sock *unix_sk = ctx->unix_sk;
+    if (unix_sk == NULL) {
+        return 0;
+    }
+    struct sock *sk = (struct sock *) unix_sk;
+
+    struct unix_sock_info info;
+    __builtin_memset(&info, 0, sizeof(info));
+    info.uid = ctx->uid;
+    info.inode = sock_i_ino(sk);
+    info.type = BPF_CORE_READ(sk, sk_type);
+    info.state = BPF_CORE_READ(sk, __sk_common.skc_state);
+    info.rx_queue = BPF_CORE_READ(sk, sk_receive_queue.qlen);
+    info.tx_queue = BPF_CORE_READ(sk, sk_wmem_alloc.refs.counter) - 1;
+
+    // https://elixir.bootlin.com/linux/v6.12/source/net/unix/af_unix.c#L186
+    struct sock *peer = BPF_CORE_READ(unix_sk, peer);
+    if (peer != NULL) {
+        info.peer_inode = sock_i_ino(peer);
+    }
+
+    struct unix_address *addr = BPF_CORE_READ(unix_sk, addr);
+    if (addr != NULL) {
+        // the address length includes the sun_family field.
+        int len = BPF_CORE_READ(addr, len) - sizeof(unsigned short);
+        if (len > UNIX_PATH_MAX) {
+            len = UNIX_PATH_MAX;
+        }
+        if (len > 0) {
+            bpf_probe_read_kernel(info.path, len, addr->name[0].sun_path);
+            info.path_len = len;
+        }
+    }
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
+
+SEC("iter/task_file")
+int dump_socket_fds(struct bpf_iter__task_file *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct task_struct *task = ctx->task;
+    struct file *file = ctx->file;
+    if (task == NULL || file == NULL) {
+        return 0;
+    }
+    if (bpf_sock_from_file(file) == NULL) {
+        return 0;
+    }
+
+    struct socket_fd_info info;
+    info.inode = BPF_CORE_READ(file, f_inode, i_ino);
+    info.pid = task->tgid;
+    struct task_struct *grp_leader = bpf_task_acquire(task->group_leader);
+    if (grp_leader != NULL) {
+        info.local_pid = tid_current_pidns(grp_leader);
+        bpf_task_release(grp_leader);
+    } else {
+        info.local_pid = 0;
+    }
+    info.fd = ctx->fd;
+
+    bpf_seq_write(m, &info, sizeof(info));
+    return 0;
+}
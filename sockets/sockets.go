@@ -0,0 +1,259 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:generate bpf2go -go-package sockets sockets sockets.bpf.c -- -I../_headers/cilium-ebpf -I../_headers/libbpf -I../_headers/beesy
+
+package sockets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/netip"
+	"slices"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/bpfiter"
+	"github.com/thediveo/beesy/internal/iteriter"
+	"golang.org/x/sys/unix"
+)
+
+// SocketIterator iterates over the TCP, UDP, and unix domain sockets in the
+// caller's network namespace.
+type SocketIterator struct {
+	ebpfObjects socketsObjects
+	tcpIter     *link.Iter
+	udpIter     *link.Iter
+	unixIter    *link.Iter
+	fdIter      *link.Iter
+}
+
+// NewSocketIterator returns a new SocketIterator. Use [SocketIterator.All] to
+// iterate over the sockets and [SocketIterator.Close] to release the
+// SocketIterator's resources when done.
+func NewSocketIterator() (*SocketIterator, error) {
+	si := &SocketIterator{}
+	spec, err := loadSockets()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load socket iterator eBPF objects, reason: %w", err)
+	}
+	if err := errors.Join(
+		bpfiter.CheckLayout[socketsInetSockInfo](spec.Types, "inet_sock_info"),
+		bpfiter.CheckLayout[socketsUnixSockInfo](spec.Types, "unix_sock_info"),
+		bpfiter.CheckLayout[socketsSocketFdInfo](spec.Types, "socket_fd_info"),
+	); err != nil {
+		return nil, fmt.Errorf("mismatching socket iterator record layout, reason: %w", err)
+	}
+	if err := spec.LoadAndAssign(&si.ebpfObjects, nil); err != nil {
+		return nil, fmt.Errorf("cannot load socket iterator eBPF objects, reason: %w", err)
+	}
+	for _, attach := range []struct {
+		it   **link.Iter
+		opts link.IterOptions
+		name string
+	}{
+		{&si.tcpIter, link.IterOptions{Program: si.ebpfObjects.DumpTcp}, "TCP"},
+		{&si.udpIter, link.IterOptions{Program: si.ebpfObjects.DumpUdp}, "UDP"},
+		{&si.unixIter, link.IterOptions{Program: si.ebpfObjects.DumpUnix}, "unix"},
+		{&si.fdIter, link.IterOptions{Program: si.ebpfObjects.DumpSocketFds}, "socket fd"},
+	} {
+		var err error
+		if *attach.it, err = link.AttachIter(attach.opts); err != nil {
+			si.Close()
+			return nil, fmt.Errorf("cannot attach %s iterator, reason: %w", attach.name, err)
+		}
+	}
+	return si, nil
+}
+
+// Close releases all resources associated with this SocketIterator.
+func (si *SocketIterator) Close() {
+	for _, it := range []*link.Iter{si.tcpIter, si.udpIter, si.unixIter, si.fdIter} {
+		if it != nil {
+			it.Close()
+		}
+	}
+	si.ebpfObjects.Close()
+}
+
+// Owners returns the processes owning sockets, indexed by socket inode
+// numbers.
+func (si *SocketIterator) Owners() (map[uint64][]Owner, error) {
+	return si.owners(context.Background())
+}
+
+// owners returns the processes owning sockets, indexed by socket inode
+// numbers, until the context is done.
+func (si *SocketIterator) owners(ctx context.Context) (map[uint64][]Owner, error) {
+	owners := map[uint64][]Owner{}
+	for info, err := range iteriter.AllVolatileContext[socketsSocketFdInfo](ctx, si.fdIter) {
+		if err != nil {
+			return nil, fmt.Errorf("cannot iterate socket file descriptors, reason: %w", err)
+		}
+		owners[info.Inode] = append(owners[info.Inode], Owner{
+			PID:      info.Pid,
+			LocalPID: info.LocalPid,
+			FD:       info.Fd,
+		})
+	}
+	return owners, nil
+}
+
+// All returns an iterator over all sockets of the specified protocols,
+// together with their owning processes. If no protocols are specified, All
+// iterates over the sockets of all supported protocols. In case of an iterator
+// failure, the iterator will return a zero Socket together with an error and
+// then end the sequence.
+func (si *SocketIterator) All(protocols ...Protocol) iter.Seq2[Socket, error] {
+	return si.AllContext(context.Background(), protocols...)
+}
+
+// AllContext returns an iterator over all sockets of the specified protocols,
+// together with their owning processes, see [SocketIterator.All]. When the
+// specified context is done, the iterator stops, returning a zero Socket
+// together with an error wrapping ctx.Err() and then ending the sequence.
+func (si *SocketIterator) AllContext(ctx context.Context, protocols ...Protocol) iter.Seq2[Socket, error] {
+	if len(protocols) == 0 {
+		protocols = []Protocol{TCP, UDP, Unix}
+	}
+	return func(yield func(Socket, error) bool) {
+		owners, err := si.owners(ctx)
+		if err != nil {
+			yield(Socket{}, err)
+			return
+		}
+		for _, proto := range protocols {
+			for sock, err := range si.sockets(ctx, proto) {
+				if err != nil {
+					yield(Socket{}, fmt.Errorf("cannot iterate %s sockets, reason: %w", proto, err))
+					return
+				}
+				sock.attribute(owners)
+				if !yield(sock, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// attribute sets the owners of this socket and in case of a connected unix
+// domain socket also the owners of its peer socket.
+func (s *Socket) attribute(owners map[uint64][]Owner) {
+	s.Owners = slices.Clone(owners[s.Inode])
+	if s.Peer != 0 {
+		s.PeerOwners = slices.Clone(owners[s.Peer])
+	}
+}
+
+// sockets returns an iterator over the sockets of the specified protocol,
+// without their owners, until the context is done.
+func (si *SocketIterator) sockets(ctx context.Context, proto Protocol) iter.Seq2[Socket, error] {
+	return func(yield func(Socket, error) bool) {
+		switch proto {
+		case TCP, UDP:
+			it := si.tcpIter
+			if proto == UDP {
+				it = si.udpIter
+			}
+			for info, err := range iteriter.AllVolatileContext[socketsInetSockInfo](ctx, it) {
+				if err != nil {
+					yield(Socket{}, err)
+					return
+				}
+				if !yield(newInetSocket(proto, info), nil) {
+					return
+				}
+			}
+		case Unix:
+			for info, err := range iteriter.AllVolatileContext[socketsUnixSockInfo](ctx, si.unixIter) {
+				if err != nil {
+					yield(Socket{}, err)
+					return
+				}
+				if !yield(newUnixSocket(info), nil) {
+					return
+				}
+			}
+		default:
+			yield(Socket{}, fmt.Errorf("unsupported protocol %s", proto))
+		}
+	}
+}
+
+// newInetSocket returns a new TCP or UDP Socket from the binary socket
+// information.
+func newInetSocket(proto Protocol, info *socketsInetSockInfo) Socket {
+	sock := Socket{
+		Protocol: proto,
+		Family:   info.Family,
+		Type:     unix.SOCK_STREAM,
+		State:    State(info.State),
+		Inode:    info.Inode,
+		RxQueue:  info.RxQueue,
+		TxQueue:  info.TxQueue,
+		UID:      info.Uid,
+	}
+	if proto == UDP {
+		sock.Type = unix.SOCK_DGRAM
+	}
+	sock.Local = netip.AddrPortFrom(inetAddr(info.Family, info.Src), info.SrcPort)
+	sock.Remote = netip.AddrPortFrom(inetAddr(info.Family, info.Dst), info.DstPort)
+	return sock
+}
+
+// inetAddr returns the IPv4 or IPv6 address depending on the address family.
+func inetAddr(family uint16, addr [16]uint8) netip.Addr {
+	if family == unix.AF_INET {
+		return netip.AddrFrom4([4]byte(addr[:4]))
+	}
+	return netip.AddrFrom16(addr)
+}
+
+// newUnixSocket returns a new unix domain Socket from the binary socket
+// information.
+func newUnixSocket(info *socketsUnixSockInfo) Socket {
+	sock := Socket{
+		Protocol: Unix,
+		Family:   unix.AF_UNIX,
+		Type:     info.Type,
+		State:    State(info.State),
+		Inode:    info.Inode,
+		Peer:     info.PeerInode,
+		RxQueue:  info.RxQueue,
+		TxQueue:  info.TxQueue,
+		UID:      info.Uid,
+	}
+	pathlen := min(int(info.PathLen), len(info.Path))
+	path := make([]byte, pathlen)
+	for idx := range pathlen {
+		path[idx] = byte(info.Path[idx])
+	}
+	switch {
+	case pathlen == 0:
+	case path[0] == 0:
+		// abstract names are shown with a leading “@” instead of the zero
+		// byte, same as ss(8) does.
+		sock.Path = "@" + string(path[1:])
+	default:
+		// pathname sockets might or might not include the terminating zero
+		// byte in their address lengths.
+		if idx := slices.Index(path, 0); idx >= 0 {
+			path = path[:idx]
+		}
+		sock.Path = string(path)
+	}
+	return sock
+}
@@ -0,0 +1,176 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sockets
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// cpath returns the passed string as a C char array, optionally zero
+// terminated.
+func cpath(s string) (path [108]int8, pathlen uint8) {
+	for idx := range len(s) {
+		path[idx] = int8(s[idx])
+	}
+	return path, uint8(len(s))
+}
+
+var _ = Describe("sockets", func() {
+
+	It("converts inet socket information", func() {
+		sock := newInetSocket(TCP, &socketsInetSockInfo{
+			Inode:   42,
+			Src:     [16]uint8{127, 0, 0, 1},
+			Dst:     [16]uint8{127, 0, 0, 2},
+			Family:  unix.AF_INET,
+			SrcPort: 1234,
+			DstPort: 80,
+			State:   uint8(Established),
+		})
+		Expect(sock.Protocol).To(Equal(TCP))
+		Expect(sock.Type).To(Equal(uint16(unix.SOCK_STREAM)))
+		Expect(sock.State).To(Equal(Established))
+		Expect(sock.Local).To(Equal(netip.MustParseAddrPort("127.0.0.1:1234")))
+		Expect(sock.Remote).To(Equal(netip.MustParseAddrPort("127.0.0.2:80")))
+
+		sock = newInetSocket(UDP, &socketsInetSockInfo{
+			Src:     [16]uint8{15: 1},
+			Family:  unix.AF_INET6,
+			SrcPort: 53,
+			State:   uint8(Close),
+		})
+		Expect(sock.Type).To(Equal(uint16(unix.SOCK_DGRAM)))
+		Expect(sock.Local).To(Equal(netip.MustParseAddrPort("[::1]:53")))
+	})
+
+	DescribeTable("converts unix socket paths",
+		func(path string, expected string) {
+			cpath, pathlen := cpath(path)
+			sock := newUnixSocket(&socketsUnixSockInfo{
+				Type:    unix.SOCK_STREAM,
+				Path:    cpath,
+				PathLen: pathlen,
+			})
+			Expect(sock.Protocol).To(Equal(Unix))
+			Expect(sock.Path).To(Equal(expected))
+		},
+		Entry("unbound", "", ""),
+		Entry("abstract", "\x00foo", "@foo"),
+		Entry("pathname", "/run/foo.sock", "/run/foo.sock"),
+		Entry("zero-terminated pathname", "/run/foo.sock\x00", "/run/foo.sock"),
+	)
+
+	It("attributes sockets and their peers", func() {
+		owners := map[uint64][]Owner{
+			42: {{PID: 1, FD: 3}},
+			43: {{PID: 2, FD: 4}, {PID: 3, FD: 5}},
+		}
+		sock := newUnixSocket(&socketsUnixSockInfo{Inode: 42, PeerInode: 43})
+		sock.attribute(owners)
+		Expect(sock.Peer).To(Equal(uint64(43)))
+		Expect(sock.Owners).To(ConsistOf(Owner{PID: 1, FD: 3}))
+		Expect(sock.PeerOwners).To(ConsistOf(Owner{PID: 2, FD: 4}, Owner{PID: 3, FD: 5}))
+
+		sock = newUnixSocket(&socketsUnixSockInfo{Inode: 43})
+		sock.attribute(owners)
+		Expect(sock.PeerOwners).To(BeEmpty())
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(10 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("finds its own listening sockets", func() {
+			tcpl := Successful(net.Listen("tcp", "127.0.0.1:0"))
+			defer tcpl.Close()
+			tcpaddr := tcpl.Addr().(*net.TCPAddr).AddrPort()
+			unixpath := filepath.Join(GinkgoT().TempDir(), "beesy.sock")
+			unixl := Successful(net.Listen("unix", unixpath))
+			defer unixl.Close()
+
+			si := Successful(NewSocketIterator())
+			defer si.Close()
+
+			ownPID := int32(os.Getpid())
+			var tcpsock, unixsock *Socket
+			for sock, err := range si.All() {
+				Expect(err).NotTo(HaveOccurred())
+				switch {
+				case sock.Protocol == TCP && sock.Local == tcpaddr:
+					tcpsock = &sock
+				case sock.Protocol == Unix && sock.Path == unixpath:
+					unixsock = &sock
+				}
+			}
+			Expect(tcpsock).NotTo(BeNil(), "missing TCP listening socket")
+			Expect(tcpsock.State).To(Equal(Listen))
+			Expect(tcpsock.Owners).To(ContainElement(HaveField("PID", ownPID)))
+			Expect(unixsock).NotTo(BeNil(), "missing unix listening socket")
+			Expect(unixsock.State).To(Equal(Listen))
+			Expect(unixsock.Owners).To(ContainElement(HaveField("PID", ownPID)))
+		})
+
+		It("resolves unix socket peers", func() {
+			fds := Successful(unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0))
+			defer unix.Close(fds[0])
+			defer unix.Close(fds[1])
+
+			si := Successful(NewSocketIterator())
+			defer si.Close()
+
+			ownPID := int32(os.Getpid())
+			found := 0
+			for sock, err := range si.All(Unix) {
+				Expect(err).NotTo(HaveOccurred())
+				if !slices.ContainsFunc(sock.Owners, func(o Owner) bool {
+					return o.PID == ownPID && (o.FD == uint32(fds[0]) || o.FD == uint32(fds[1]))
+				}) {
+					continue
+				}
+				found++
+				Expect(sock.Peer).NotTo(BeZero())
+				Expect(sock.PeerOwners).To(ContainElement(HaveField("PID", ownPID)))
+			}
+			Expect(found).To(Equal(2))
+		})
+
+	})
+
+})
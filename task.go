@@ -0,0 +1,155 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package beesy
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/thediveo/beesy/tasks"
+)
+
+// Task represents a single task (thread) as seen by a [TaskIterator]. All PIDs
+// and TIDs are from the perspective of the initial PID namespace.
+type Task struct {
+	PID   int32       // PID (in kernel-speak: thread group ID) of the process this task belongs to.
+	TID   int32       // TID (in kernel-speak: PID) of this task.
+	PPID  int32       // PID of the real parent process, or zero.
+	PGID  int32       // process group ID of the process this task belongs to, or zero.
+	SID   int32       // session ID of the process this task belongs to, or zero.
+	Name  string      // task name; kthreads get their full name instead of their truncated comm.
+	State tasks.State // task state, such as R(unning), S(leeping), D(isk sleep), ...
+
+	// StartTime of this task, in CLOCK_MONOTONIC time.
+	StartTime time.Duration
+	// LastRan is the (approximate) CLOCK_MONOTONIC time when this task last
+	// stopped running on a CPU, such as when it blocked. As the scheduler
+	// doesn't record this time, it is the time the task last got onto a CPU,
+	// as tracked by the scheduler's rq clock, plus how long the task then ran.
+	// For a currently running task, LastRan thus might lag behind by up to a
+	// scheduler tick. Please note that on kernels without CONFIG_SCHED_INFO
+	// this falls back to the rq task clock, which excludes IRQ and steal times
+	// and thus lags behind CLOCK_MONOTONIC.
+	LastRan time.Duration
+
+	// UID is the real UID of this task.
+	UID uint32
+	// CgroupID is the ID of the cgroup this task is a member of in the unified
+	// (v2) cgroup hierarchy.
+	CgroupID uint64
+
+	// TTY is the device number of the controlling terminal, or zero if there
+	// is no controlling terminal.
+	TTY uint64
+	// LoginUID is the audit login UID, or [tasks.AuditUIDUnset].
+	LoginUID uint32
+	// AuditSessionID is the audit (login) session ID, or
+	// [tasks.AuditSessionIDUnset].
+	AuditSessionID uint32
+
+	// Namespaces the task is attached to.
+	Namespaces Namespaces
+
+	Kthread        bool // task is a kernel thread.
+	ChildSubreaper bool // process is a child subreaper, see also PR_SET_CHILD_SUBREAPER.
+	// GroupAlive is true as long as the process this task belongs to has live
+	// threads, even if this task itself has already exited.
+	GroupAlive bool
+
+	// CPU a per-CPU kernel thread is bound to, or -1 if not bound to a single
+	// CPU (or not a kernel thread at all).
+	CPU int32
+	// NUMA node of the worker pool a kworker belongs to, or -1 if unknown.
+	Node int32
+	// Workqueue is the name of the workqueue of the work item a kworker is
+	// currently processing, or empty.
+	Workqueue string
+	// WorkerDesc is the description of a kworker, as set by the work it is
+	// processing or has last processed. This is the “-xxx” suffix that ps
+	// shows for kworkers.
+	WorkerDesc string
+
+	kstack  []uint64
+	cmdline []string
+	environ []string
+}
+
+// Namespaces lists the inode numbers of the namespaces a task is attached to.
+// Exited tasks, such as zombies, have zero namespace inode numbers, except for
+// their user and PID namespaces.
+type Namespaces struct {
+	Cgroup uint64
+	IPC    uint64
+	Mnt    uint64
+	Net    uint64
+	PID    uint64 // active PID namespace, not the PID namespace for children.
+	Time   uint64 // zero if the kernel doesn't support time namespaces.
+	User   uint64
+	UTS    uint64
+}
+
+// KernelStack returns the kernel stack of this task as a list of instruction
+// pointers, with the innermost stack frame first. KernelStack returns nil if
+// kernel stacks weren't requested for the state of this task using
+// [WithKernelStacks]. Use [ksym.Table.Symbolize] to symbolize the kernel
+// stack.
+//
+// [ksym.Table.Symbolize]: https://pkg.go.dev/github.com/thediveo/beesy/ksym#Table.Symbolize
+func (t *Task) KernelStack() []uint64 {
+	return t.kstack
+}
+
+// Cmdline returns the command line arguments of the process this task belongs
+// to, or nil if the command line wasn't requested using [WithCmdline], or if
+// this is a kernel thread. If the command line exceeds the configured maximum
+// length, the final argument will be truncated.
+func (t *Task) Cmdline() []string {
+	return t.cmdline
+}
+
+// Environ returns the environment variables in “key=value” form of the
+// process this task belongs to, or nil if the environment wasn't requested
+// using [WithEnviron], or if this is a kernel thread. If the environment
+// exceeds the configured maximum length, the final variable will be truncated.
+func (t *Task) Environ() []string {
+	return t.environ
+}
+
+// WithOptionalData returns a copy of this task with the specified kernel
+// stack, command line, and environment, such as when restoring a task from a
+// snapshot file. See also [Task.KernelStack], [Task.Cmdline], and
+// [Task.Environ].
+func (t Task) WithOptionalData(kstack []uint64, cmdline []string, environ []string) Task {
+	t.kstack = kstack
+	t.cmdline = cmdline
+	t.environ = environ
+	return t
+}
+
+// splitNulTerminated splits the passed sequence of zero-terminated strings
+// into its individual strings. If the final string lacks its terminating zero
+// byte, it is nevertheless returned (as a truncated string). Please note that
+// an empty b returns nil.
+func splitNulTerminated(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	b = bytes.TrimSuffix(b, []byte{0})
+	strs := []string{}
+	for s := range bytes.SplitSeq(b, []byte{0}) {
+		strs = append(strs, string(s))
+	}
+	return strs
+}
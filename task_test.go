@@ -0,0 +1,59 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package beesy
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("tasks", func() {
+
+	DescribeTable("splitting zero-terminated strings",
+		func(b string, expected []string) {
+			Expect(splitNulTerminated([]byte(b))).To(Equal(expected))
+		},
+		Entry("nothing", "", nil),
+		Entry("single string", "foo\x00", []string{"foo"}),
+		Entry("multiple strings", "foo\x00bar\x00baz\x00", []string{"foo", "bar", "baz"}),
+		Entry("truncated final string", "foo\x00ba", []string{"foo", "ba"}),
+		Entry("empty string", "foo\x00\x00bar\x00", []string{"foo", "", "bar"}),
+	)
+
+	It("returns the kernel stack", func() {
+		t := Task{kstack: []uint64{0xffffffff81a2d1f0}}
+		Expect(t.KernelStack()).To(ConsistOf(uint64(0xffffffff81a2d1f0)))
+	})
+
+	It("returns command line and environment", func() {
+		t := Task{
+			cmdline: []string{"/bin/foo", "--bar"},
+			environ: []string{"FOO=bar"},
+		}
+		Expect(t.Cmdline()).To(ConsistOf("/bin/foo", "--bar"))
+		Expect(t.Environ()).To(ConsistOf("FOO=bar"))
+	})
+
+	It("returns a copy with optional data", func() {
+		t := Task{PID: 42}
+		t2 := t.WithOptionalData([]uint64{1}, []string{"/bin/foo"}, []string{"FOO=bar"})
+		Expect(t.Cmdline()).To(BeNil())
+		Expect(t2.PID).To(Equal(int32(42)))
+		Expect(t2.KernelStack()).To(ConsistOf(uint64(1)))
+		Expect(t2.Cmdline()).To(ConsistOf("/bin/foo"))
+		Expect(t2.Environ()).To(ConsistOf("FOO=bar"))
+	})
+
+})
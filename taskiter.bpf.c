@@ -1,79 +1,18 @@
 //go:build ignore
 
-#include "common.h"
+#include "iter.h"
+#include "frame.h"
+#include "strncpy.h"
+#include "usermem.h"
+#include "state.h"
+#include "kstack.h"
+#include "workqueue.h"
+#include "namespaces.h"
+#include "cgroup.h"
 #include "bpf_core_read.h"
 
 char __license[] SEC("license") = "Dual MIT/GPL";
 
-// https://elixir.bootlin.com/linux/v6.12/source/tools/sched_ext/include/scx/common.bpf.h#L329
-extern void bpf_rcu_read_lock(void) __ksym;
-extern void bpf_rcu_read_unlock(void) __ksym;
-
-extern struct task_struct *bpf_task_acquire(struct task_struct *p) __ksym;
-extern void bpf_task_release(struct task_struct *p) __ksym;
-
-// See:
-// - https://elixir.bootlin.com/linux/v6.12/source/include/linux/types.h#L27
-// - https://elixir.bootlin.com/linux/v6.12/source/include/uapi/asm-generic/posix_types.h#L28
-typedef int pid_t; 
-
-// https://elixir.bootlin.com/linux/v6.14.4/source/include/linux/sched.h#L307
-#define TASK_COMM_LEN 16
-
-// https://elixir.bootlin.com/linux/v6.14.5/source/include/linux/sched.h#L1695
-#define PF_KTHREAD 0x00200000 /* I am a kernel thread */
-
-// https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L778
-struct task_struct {
-    pid_t pid;
-    pid_t tgid;
-
-    char comm[TASK_COMM_LEN];
-    
-    struct task_struct *real_parent;
-
-    unsigned int flags;
-    void *worker_private;
-} __attribute__((preserve_access_index));
-
-struct kthread {
-    char *full_name;
-} __attribute__((preserve_access_index));
-
-// https://elixir.bootlin.com/linux/v6.12/source/tools/testing/selftests/bpf/progs/bpf_iter.h#L52
-struct bpf_iter_meta {
-	struct seq_file *seq;
-	__u64 session_id;
-	__u64 seq_num;
-} __attribute__((preserve_access_index));
-
-
-// https://elixir.bootlin.com/linux/v6.12/source/tools/testing/selftests/bpf/progs/bpf_iter.h#L68
-struct bpf_iter__task {
-	struct bpf_iter_meta *meta;
-	struct task_struct *task;
-} __attribute__((preserve_access_index));
-
-
-// https://elixir.bootlin.com/linux/v6.14.5/source/fs/proc/array.c#L99
-#define TASKFULLNAMELEN 64
-
-/*
- * bee_strncpy copies len byte-wide chars from *src to *dst, filling in zero
- * chars when the end of src has been reached before len. This helper does not
- * ensure a trailing zero byte, it is up to the caller to ensure such a zero
- * byte terminator where necessary.
- */
-void bee_strncpy(char *dst, const char *src, int len) {
-    while (len) {
-        if ((*dst = *src) != 0) {
-            src++;
-        }
-        dst++;
-        len--;
-    }
-}
-
 /*
  * task_name copies the name of the *task into the *buf of len, ensuring that
  * the name is always properly zero byte terminated.
@@ -119,16 +58,302 @@ void task_name(struct task_struct *task, char *buf, int len)
     return;
 }
 
-// taskstatus defines the binary representation of the per-task status
-// information.
-struct task_info {
-    int  pid;
-    int  tid;
-    int  ppid;
-    char fullname[TASKFULLNAMELEN];
+// Frame types of the framed task iterator output: each task starts with a
+// task status frame, optionally followed by a kernel stack frame and, in case
+// of the sleepable iterator, command line and environment frames.
+#define TASK_FRAME_STATUS  1 // struct task_status
+#define TASK_FRAME_KSTACK  2 // kernel stack addresses
+#define TASK_FRAME_CMDLINE 3 // command line data from user memory
+#define TASK_FRAME_ENVIRON 4 // environment data from user memory
+
+// task_status defines the binary representation of the per-task status
+// information, emitted as the payload of a TASK_FRAME_STATUS frame.
+struct task_status {
+    __u64 start_time; // CLOCK_MONOTONIC nanoseconds
+    __u64 last_ran;   // rq clock nanoseconds when last switched off a CPU
+    __u64 cgroup_id;  // cgroup ID in the unified hierarchy
+    int   pid;
+    int   tid;
+    int   ppid;
+    int   sid;
+    int   pgid;
+    __u32 tty_major;    // major number of controlling tty, or 0 if none
+    __u32 tty_minor;    // minor number of controlling tty
+    __u32 loginuid;     // audit login UID, or AUDIT_UID_UNSET
+    __u32 sessionid;    // audit session ID, or AUDIT_SID_UNSET
+    __u32 uid;          // real UID
+    int   cpu;  // CPU a per-CPU kthread is bound to, or -1
+    int   node; // NUMA node of a kworker's pool, or -1
+    char  fullname[TASKFULLNAMELEN];
+    char  wq_name[WQ_NAME_LEN];     // workqueue of a kworker's current work
+    char  wq_desc[WORKER_DESC_LEN]; // a kworker's description
+    struct task_namespaces namespaces;
+    __u32 state; // state index, see task_state_index
+    __u32 flags; // TASK_STATUS_xxx flags
 };
 
-const struct task_info _meh __attribute__((unused)); // force emitting struct procstatus
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/audit.h#L18
+#define AUDIT_UID_UNSET ((__u32) -1)
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/audit.h#L502
+#define AUDIT_SID_UNSET ((unsigned int) -1)
+
+#define TASK_STATUS_KTHREAD          0x01 // kernel thread
+#define TASK_STATUS_CHILD_SUBREAPER  0x02 // process is a child subreaper
+#define TASK_STATUS_GROUP_ALIVE      0x04 // process has live threads
+
+const struct task_status _meh __attribute__((unused)); // force emitting struct task_status
+
+// task_status_scratch is the scratch space for task_status records, as these
+// are too large to be kept on the eBPF stack of 512 bytes, especially together
+// with the user memory chunk buffer of the sleepable iterator program.
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct task_status);
+} task_status_scratch SEC(".maps");
+
+/*
+ * scratch_task_status returns this CPU's task_status scratch space, or NULL.
+ *
+ * Please note that the task_status frame must be written to the seq_file
+ * before doing anything that might sleep.
+ */
+struct task_status *scratch_task_status(void)
+{
+    __u32 zero = 0;
+    return bpf_map_lookup_elem(&task_status_scratch, &zero);
+}
+
+// Maximum lengths of the command line and environment data to copy from the
+// user memory of processes; user space sets these at load time.
+const volatile __u32 max_cmdline_len = 4096;
+const volatile __u32 max_environ_len = 0;
+
+// Bit mask of task state indices (1 << task_state_index) for which to capture
+// the kernel stacks; user space sets this at load time. Zero means no kernel
+// stacks at all.
+const volatile __u32 kstack_state_mask = 0;
+
+// Task filters; user space sets these at load time. Only tasks matching all
+// filters get emitted.
+#define FILTER_ANY_UID    ((__u32) -1)
+#define FILTER_KTHREADS   1 // only kernel threads
+#define FILTER_USER_TASKS 2 // only user tasks
+
+const volatile __u64 filter_cgroup_id = 0; // zero matches any cgroup
+const volatile __u32 filter_pidns = 0;     // zero matches any PID namespace
+const volatile __u32 filter_uid = FILTER_ANY_UID;
+const volatile __u32 filter_kind = 0;      // FILTER_xxx, zero matches any task
+const volatile __u32 filter_name_prefix_len = 0;
+const volatile char filter_name_prefix[TASKFULLNAMELEN] = {};
+
+/*
+ * task_matches returns non-zero if the task described by *stat matches all
+ * task filters, otherwise zero.
+ */
+int task_matches(struct task_status *stat)
+{
+    if (filter_cgroup_id != 0 && stat->cgroup_id != filter_cgroup_id) {
+        return 0;
+    }
+    if (filter_pidns != 0 && stat->namespaces.pid != filter_pidns) {
+        return 0;
+    }
+    if (filter_uid != FILTER_ANY_UID && stat->uid != filter_uid) {
+        return 0;
+    }
+    switch (filter_kind) {
+    case FILTER_KTHREADS:
+        if (!(stat->flags & TASK_STATUS_KTHREAD)) {
+            return 0;
+        }
+        break;
+    case FILTER_USER_TASKS:
+        if (stat->flags & TASK_STATUS_KTHREAD) {
+            return 0;
+        }
+        break;
+    }
+    for (int i = 0; i < TASKFULLNAMELEN; i++) {
+        if (i >= filter_name_prefix_len) {
+            break;
+        }
+        if (stat->fullname[i] != filter_name_prefix[i]) {
+            return 0;
+        }
+    }
+    return 1;
+}
+
+/*
+ * kthread_binding fills in the CPU and NUMA node binding of per-CPU kthreads,
+ * as well as the workqueue name and description in case of kworkers.
+ */
+void kthread_binding(struct task_struct *task, struct task_status *stat)
+{
+    stat->cpu = -1;
+    stat->node = -1;
+    stat->wq_name[0] = '\0';
+    stat->wq_desc[0] = '\0';
+
+    if (!(task->flags & PF_KTHREAD)) {
+        return;
+    }
+    struct kthread *kt = BPF_CORE_READ(task, worker_private);
+    if (kt == NULL) {
+        return;
+    }
+    if (BPF_CORE_READ(kt, flags) & (1 << KTHREAD_IS_PER_CPU)) {
+        stat->cpu = BPF_CORE_READ(kt, cpu);
+    }
+    if (!(task->flags & PF_WQ_WORKER)) {
+        return;
+    }
+    // the kthread data of kworkers points to their struct worker.
+    struct worker *w = BPF_CORE_READ(kt, data);
+    if (w == NULL) {
+        return;
+    }
+    struct worker_pool *pool = BPF_CORE_READ(w, pool);
+    if (pool != NULL) {
+        int cpu = BPF_CORE_READ(pool, cpu);
+        if (cpu >= 0) {
+            stat->cpu = cpu;
+        }
+        stat->node = BPF_CORE_READ(pool, node);
+    }
+    BPF_CORE_READ_STR_INTO(&stat->wq_desc, w, desc);
+    struct workqueue_struct *wq = BPF_CORE_READ(w, current_pwq, wq);
+    if (wq != NULL) {
+        BPF_CORE_READ_STR_INTO(&stat->wq_name, wq, name);
+    }
+}
+
+/*
+ * session_info fills in the process group and session IDs, the controlling
+ * tty, as well as the audit login UID and audit session ID.
+ */
+void session_info(struct task_struct *task, struct task_status *stat)
+{
+    // the process group and session IDs as seen from the initial PID
+    // namespace.
+    stat->pgid = BPF_CORE_READ(task, signal, pids[PIDTYPE_PGID], numbers[0].nr);
+    stat->sid = BPF_CORE_READ(task, signal, pids[PIDTYPE_SID], numbers[0].nr);
+
+    // https://elixir.bootlin.com/linux/v6.12/source/drivers/tty/tty_io.c#L3290
+    stat->tty_major = 0;
+    stat->tty_minor = 0;
+    struct tty_struct *tty = BPF_CORE_READ(task, signal, tty);
+    if (tty != NULL) {
+        struct tty_driver *driver = BPF_CORE_READ(tty, driver);
+        if (driver != NULL) {
+            stat->tty_major = BPF_CORE_READ(driver, major);
+            stat->tty_minor = BPF_CORE_READ(driver, minor_start) + BPF_CORE_READ(tty, index);
+        }
+    }
+
+    stat->loginuid = AUDIT_UID_UNSET;
+    stat->sessionid = AUDIT_SID_UNSET;
+    if (bpf_core_field_exists(task->loginuid)) {
+        stat->loginuid = BPF_CORE_READ(task, loginuid.val);
+        stat->sessionid = BPF_CORE_READ(task, sessionid);
+    }
+}
+
+/*
+ * fill_task_status fills in the task_status information that is independent
+ * of any user memory of the specified task.
+ */
+void fill_task_status(struct task_struct *task, struct task_status *stat)
+{
+    stat->pid = task->tgid;  // user-space PID <=> kernel-space tgid
+    stat->tid = task->pid;   // user-space TID <=> kernel-space pid
+    task_name(task, stat->fullname, sizeof(stat->fullname));
+    stat->state = task_state_index(task);
+    stat->start_time = task->start_time;
+    // the scheduler doesn't record when a task was last switched off a CPU,
+    // so we add how long the task ran since it last arrived on a CPU to its
+    // arrival time: the scheduler snapshots sum_exec_runtime into
+    // prev_sum_exec_runtime upon arrival. The rq clock of the arrival time is
+    // based on the sched_clock that approximates CLOCK_MONOTONIC. In contrast,
+    // the rq task clock of se.exec_start excludes IRQ and steal times, so it
+    // only serves as a fallback without CONFIG_SCHED_INFO.
+    if (bpf_core_field_exists(task->sched_info)) {
+        __u64 ran = BPF_CORE_READ(task, se.sum_exec_runtime) -
+                    BPF_CORE_READ(task, se.prev_sum_exec_runtime);
+        stat->last_ran = BPF_CORE_READ(task, sched_info.last_arrival) + ran;
+    } else {
+        stat->last_ran = BPF_CORE_READ(task, se.exec_start);
+    }
+    stat->cgroup_id = task_cgroup_id(task);
+    stat->uid = BPF_CORE_READ(task, real_cred, uid.val);
+
+    stat->flags = 0;
+    if (task->flags & PF_KTHREAD) {
+        stat->flags |= TASK_STATUS_KTHREAD;
+    }
+    struct signal_struct *sig = BPF_CORE_READ(task, signal);
+    if (sig != NULL && BPF_CORE_READ_BITFIELD_PROBED(sig, is_child_subreaper)) {
+        stat->flags |= TASK_STATUS_CHILD_SUBREAPER;
+    }
+    if (sig != NULL && BPF_CORE_READ(sig, live.counter) > 0) {
+        stat->flags |= TASK_STATUS_GROUP_ALIVE;
+    }
+    kthread_binding(task, stat);
+    session_info(task, stat);
+    fill_task_namespaces(task, &stat->namespaces);
+
+    bpf_rcu_read_lock();
+    struct task_struct *parent = bpf_task_acquire(task->real_parent);
+    bpf_rcu_read_unlock();
+    if (parent != NULL) {
+        stat->ppid = parent->tgid;
+        bpf_task_release(parent);
+    } else {
+        stat->ppid = 0;
+    }
+}
+
+/*
+ * emit_task_status emits the task status frame, followed by a kernel stack
+ * frame if requested for the state the task is in.
+ */
+void emit_task_status(struct seq_file *m, struct task_struct *task,
+                      struct task_status *stat)
+{
+    struct kstack *ks = NULL;
+    __u32 kstack_len = 0;
+    if (kstack_state_mask & (1 << stat->state)) {
+        ks = capture_kstack(task, &kstack_len);
+    }
+    emit_frame(m, TASK_FRAME_STATUS, stat, sizeof(*stat));
+    if (ks != NULL) {
+        if (kstack_len > sizeof(ks->ips)) { // pacify the verifier
+            kstack_len = sizeof(ks->ips);
+        }
+        emit_frame(m, TASK_FRAME_KSTACK, ks->ips, kstack_len);
+    }
+}
+
+/*
+ * emit_usermem emits a frame of the specified type with len bytes of the user
+ * memory of *task starting at the user-space address start, unless len is
+ * zero.
+ *
+ * emit_usermem must only be called from sleepable eBPF programs.
+ */
+void emit_usermem(struct seq_file *m, struct task_struct *task, __u16 type,
+                  unsigned long start, __u32 len)
+{
+    if (len == 0) {
+        return;
+    }
+    if (emit_frame_header(m, type, len)) {
+        return;
+    }
+    seq_write_usermem(m, task, start, len);
+}
 
 SEC("iter/task")
 int dump_task_status(struct bpf_iter__task *ctx)
@@ -139,21 +364,213 @@ int dump_task_status(struct bpf_iter__task *ctx)
         return 0;
     }
 
-    struct task_info stat;
-    
-    stat.pid = task->tgid,  // user-space PID <=> kernel-space tgid
-    stat.tid = task->pid,   // user-space TID <=> kernel-space pid
-    task_name(task, stat.fullname, sizeof(stat.fullname));
+    struct task_status *stat = scratch_task_status();
+    if (stat == NULL) {
+        return 0;
+    }
+    fill_task_status(task, stat);
+    if (!task_matches(stat)) {
+        return 0;
+    }
+
+    emit_stream_header(ctx->meta);
+    emit_task_status(m, task, stat);
 
-    struct task_struct *parent = bpf_task_acquire(task->real_parent);
-    if (parent != NULL) {
-        stat.ppid = parent->tgid;
-        bpf_task_release(parent);
-    } else {
-        stat.ppid = 0;
+    return 0;
+}
+
+/*
+ * dump_task_status_usermem is the sleepable variant of dump_task_status that
+ * additionally copies the command line and environment from the user memory
+ * of (non-kernel) tasks. As user memory might be paged out, this needs to be a
+ * sleepable program.
+ */
+SEC("iter.s/task")
+int dump_task_status_usermem(struct bpf_iter__task *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct task_struct *task = ctx->task;
+    if (task == NULL) {
+        return 0;
+    }
+
+    struct task_status *stat = scratch_task_status();
+    if (stat == NULL) {
+        return 0;
+    }
+    fill_task_status(task, stat);
+    if (!task_matches(stat)) {
+        return 0;
+    }
+
+    unsigned long arg_start = 0, arg_end = 0;
+    unsigned long env_start = 0, env_end = 0;
+    if (!(task->flags & PF_KTHREAD)) {
+        struct mm_struct *mm = BPF_CORE_READ(task, mm);
+        if (mm != NULL) {
+            arg_start = BPF_CORE_READ(mm, arg_start);
+            arg_end = BPF_CORE_READ(mm, arg_end);
+            env_start = BPF_CORE_READ(mm, env_start);
+            env_end = BPF_CORE_READ(mm, env_end);
+        }
+    }
+    __u32 cmdline_len = usermem_len(arg_start, arg_end, max_cmdline_len);
+    __u32 environ_len = usermem_len(env_start, env_end, max_environ_len);
+
+    emit_stream_header(ctx->meta);
+    emit_task_status(m, task, stat);
+    emit_usermem(m, task, TASK_FRAME_CMDLINE, arg_start, cmdline_len);
+    emit_usermem(m, task, TASK_FRAME_ENVIRON, env_start, environ_len);
+
+    return 0;
+}
+
+/*
+ * dump_cgroup_task_status is the cgroup-driven variant of dump_task_status:
+ * user space attaches it to a specific cgroup, and optionally its descendants,
+ * so that only the tasks of these cgroups get iterated over instead of all
+ * tasks.
+ */
+SEC("iter/cgroup")
+int dump_cgroup_task_status(struct bpf_iter__cgroup *ctx)
+{
+    struct seq_file *m = ctx->meta->seq;
+    struct cgroup *cgrp = ctx->cgroup;
+    if (cgrp == NULL) {
+        return 0;
     }
 
-    bpf_seq_write(m, &stat, sizeof(stat));
+    // as we cannot sleep while iterating the tasks of a css, there's no
+    // usermem variant.
+    struct task_status *stat = scratch_task_status();
+    if (stat == NULL) {
+        return 0;
+    }
+    // emit the stream header upfront, as it must be emitted at most once per
+    // element; a stream header without any frames is fine.
+    emit_stream_header(ctx->meta);
+    struct bpf_iter_css_task it;
+    struct task_struct *task;
+    bpf_iter_css_task_new(&it, &cgrp->self, 0);
+    while ((task = bpf_iter_css_task_next(&it)) != NULL) {
+        fill_task_status(task, stat);
+        if (task_matches(stat)) {
+            emit_task_status(m, task, stat);
+        }
+    }
+    bpf_iter_css_task_destroy(&it);
+
+    return 0;
+}
 
+// task_counts defines the binary representation of the task counters
+// accumulated by count_tasks.
+struct task_counts {
+    __u64 states[TASK_STATE_INDICES]; // number of tasks per state index
+    __u64 kthreads;
+    __u64 user_tasks;
+    __u64 dropped; // per-process and per-cgroup counts lost due to full maps
+};
+
+const struct task_counts _meh_counts __attribute__((unused)); // force emitting struct task_counts
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/bpf.h#L1361
+#define BPF_F_NO_PREALLOC (1U << 0)
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/threads.h#L34
+#define PID_MAX_LIMIT (4*1024*1024)
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct task_counts);
+} counts SEC(".maps");
+
+// number of threads per process, indexed by PID.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, PID_MAX_LIMIT);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, __u32);
+    __type(value, __u64);
+} process_threads SEC(".maps");
+
+// number of tasks per cgroup, indexed by cgroup ID.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 64*1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, __u64);
+    __type(value, __u64);
+} cgroup_tasks SEC(".maps");
+
+/*
+ * count increments the counter with the specified key in the specified hash
+ * map, returning non-zero if successful, or zero if the map is full.
+ */
+int count(void *map, void *key)
+{
+    __u64 *n = bpf_map_lookup_elem(map, key);
+    if (n != NULL) {
+        __sync_fetch_and_add(n, 1);
+        return 1;
+    }
+    __u64 one = 1;
+    if (bpf_map_update_elem(map, key, &one, BPF_NOEXIST) == 0) {
+        return 1;
+    }
+    // we might have lost a race with another iteration...
+    n = bpf_map_lookup_elem(map, key);
+    if (n != NULL) {
+        __sync_fetch_and_add(n, 1);
+        return 1;
+    }
+    return 0;
+}
+
+/*
+ * count_tasks is the aggregating variant of dump_task_status that doesn't
+ * emit any per-task records, but instead accumulates task counters in the
+ * counts, process_threads, and cgroup_tasks maps.
+ */
+SEC("iter/task")
+int count_tasks(struct bpf_iter__task *ctx)
+{
+    struct task_struct *task = ctx->task;
+    if (task == NULL) {
+        return 0;
+    }
+
+    struct task_status *stat = scratch_task_status();
+    if (stat == NULL) {
+        return 0;
+    }
+    fill_task_status(task, stat);
+    if (!task_matches(stat)) {
+        return 0;
+    }
+
+    __u32 zero = 0;
+    struct task_counts *c = bpf_map_lookup_elem(&counts, &zero);
+    if (c == NULL) {
+        return 0;
+    }
+    __u32 state = stat->state;
+    if (state < TASK_STATE_INDICES) {
+        c->states[state]++;
+    }
+    if (stat->flags & TASK_STATUS_KTHREAD) {
+        c->kthreads++;
+    } else {
+        c->user_tasks++;
+    }
+    __u32 pid = stat->pid;
+    if (!count(&process_threads, &pid)) {
+        c->dropped++;
+    }
+    __u64 cgroup_id = stat->cgroup_id;
+    if (!count(&cgroup_tasks, &cgroup_id)) {
+        c->dropped++;
+    }
     return 0;
 }
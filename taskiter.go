@@ -15,51 +15,328 @@
 package beesy
 
 import (
-	"bytes"
-	"io"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"iter"
-	"strings"
-	"unsafe"
+	"slices"
+	"time"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
+	"github.com/thediveo/beesy/bpfiter"
+	"github.com/thediveo/beesy/internal/cstr"
+	"github.com/thediveo/beesy/internal/iteriter"
+	"github.com/thediveo/beesy/tasks"
+	"golang.org/x/sys/unix"
 )
 
-func (ts *beesyTaskStatus) Name() string {
-	b := unsafe.Slice((*byte)(unsafe.Pointer(&ts.Fullname[0])), unsafe.Sizeof(ts.Fullname))
-	// note that the fullname char array isn't zero padded, so we cannot use the
-	// usual TrimRight and Co., but instead stop dead at the first zero byte.
-	if idx := bytes.IndexByte(b, 0); idx >= 0 {
-		return strings.Clone(string(b[:idx]))
+// Task status flags, see TASK_STATUS_xxx in taskiter.bpf.c.
+const (
+	taskStatusKthread        = 0x01
+	taskStatusChildSubreaper = 0x02
+	taskStatusGroupAlive     = 0x04
+)
+
+// Frame types of the framed task iterator output, see TASK_FRAME_xxx in
+// taskiter.bpf.c.
+const (
+	taskFrameStatus  = 1
+	taskFrameKstack  = 2
+	taskFrameCmdline = 3
+	taskFrameEnviron = 4
+)
+
+// TaskIterator iterates over all tasks visible to the caller in a single
+// (kernel-side) pass.
+type TaskIterator struct {
+	prog     *ebpf.Program
+	taskIter *link.Iter
+	source   bpfiter.Source
+}
+
+// NewTaskIterator returns a new TaskIterator, configured using the specified
+// options. Use [TaskIterator.All] to iterate over the tasks and
+// [TaskIterator.Close] to release the TaskIterator's resources when done.
+func NewTaskIterator(opts ...Option) (*TaskIterator, error) {
+	o := newOptions(opts...)
+	progName := "dump_task_status"
+	if o.usermem() {
+		progName = "dump_task_status_usermem"
+	}
+	return newTaskIterator(&o, progName, func(prog *ebpf.Program) (*link.Iter, error) {
+		return link.AttachIter(link.IterOptions{Program: prog})
+	})
+}
+
+// NewCgroupTaskIterator returns a new TaskIterator that iterates only over the
+// tasks of the cgroup at the specified path in the cgroup2 filesystem, such as
+// “/sys/fs/cgroup/system.slice/foo.service”. If descendants is true, the
+// TaskIterator additionally iterates over the tasks of all descendant cgroups.
+//
+// In contrast to scanning all tasks and then filtering them, the kernel only
+// visits the tasks of the cgroup(s) in the first place. Please note that this
+// requires Linux 6.7 or later.
+//
+// Retrieving command lines and environments isn't supported, as the kernel
+// doesn't allow accessing user memory while iterating the tasks of a cgroup.
+func NewCgroupTaskIterator(path string, descendants bool, opts ...Option) (*TaskIterator, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open cgroup %q, reason: %w", path, err)
+	}
+	defer unix.Close(fd)
+	return NewCgroupFDTaskIterator(fd, descendants, opts...)
+}
+
+// NewCgroupFDTaskIterator returns a new TaskIterator that iterates only over
+// the tasks of the cgroup referenced by the open file descriptor cgroupfd. If
+// descendants is true, the TaskIterator additionally iterates over the tasks
+// of all descendant cgroups. The caller can close cgroupfd after
+// NewCgroupFDTaskIterator returns. See also [NewCgroupTaskIterator].
+func NewCgroupFDTaskIterator(cgroupfd int, descendants bool, opts ...Option) (*TaskIterator, error) {
+	o := newOptions(opts...)
+	if o.usermem() {
+		return nil, errors.New("cgroup task iterators don't support command lines and environments")
+	}
+	order := iteriter.CgroupIterSelfOnly
+	if descendants {
+		order = iteriter.CgroupIterDescendantsPre
+	}
+	return newTaskIterator(&o, "dump_cgroup_task_status", func(prog *ebpf.Program) (*link.Iter, error) {
+		return iteriter.AttachCgroupIter(prog, cgroupfd, order)
+	})
+}
+
+// newTaskIterator returns a new TaskIterator using the named eBPF iterator
+// program, configured using the specified options and attached using the
+// specified attach function.
+func newTaskIterator(
+	o *options, progName string, attach func(*ebpf.Program) (*link.Iter, error),
+) (*TaskIterator, error) {
+	spec, err := loadSpec(o)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Variables["kstack_state_mask"].Set(o.kstackStateMask); err != nil {
+		return nil, fmt.Errorf("cannot configure kernel stack states, reason: %w", err)
+	}
+	if o.usermem() {
+		if err := spec.Variables["max_cmdline_len"].Set(o.maxCmdlineLen); err != nil {
+			return nil, fmt.Errorf("cannot configure maximum command line length, reason: %w", err)
+		}
+		if err := spec.Variables["max_environ_len"].Set(o.maxEnvironLen); err != nil {
+			return nil, fmt.Errorf("cannot configure maximum environment length, reason: %w", err)
+		}
+	}
+	// Only load the one eBPF iterator program actually needed, so that we
+	// don't fail on older kernels without sleepable iterator support or
+	// without the open-coded css task iterator in case these aren't needed
+	// anyway.
+	for name := range spec.Programs {
+		if name != progName {
+			delete(spec.Programs, name)
+		}
+	}
+	deleteAggregation(spec)
+	coll, err := ebpf.NewCollection(spec)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load task iterator eBPF objects, reason: %w", err)
+	}
+	defer coll.Close()
+
+	ti := &TaskIterator{
+		prog: coll.DetachProgram(progName),
+	}
+	if ti.taskIter, err = attach(ti.prog); err != nil {
+		ti.Close()
+		return nil, fmt.Errorf("cannot attach task iterator, reason: %w", err)
+	}
+	ti.source = ti.taskIter
+	return ti, nil
+}
+
+// loadSpec returns the task iterator eBPF collection specification, with the
+// task filters configured as specified in the options.
+func loadSpec(o *options) (*ebpf.CollectionSpec, error) {
+	filterVars, err := o.filter.variables()
+	if err != nil {
+		return nil, fmt.Errorf("invalid task filter, reason: %w", err)
+	}
+	spec, err := loadBeesy()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load task iterator eBPF objects, reason: %w", err)
+	}
+	// struct task_status keeps growing with further task details, so a
+	// forgotten "go generate" is most likely to show up here.
+	if err := errors.Join(
+		bpfiter.CheckLayout[beesyTaskStatus](spec.Types, "task_status"),
+		bpfiter.CheckLayout[beesyTaskCounts](spec.Types, "task_counts"),
+	); err != nil {
+		return nil, fmt.Errorf("mismatching task iterator record layout, reason: %w", err)
 	}
-	return strings.Clone(string(b[:]))
+	for name, value := range filterVars {
+		if err := spec.Variables[name].Set(value); err != nil {
+			return nil, fmt.Errorf("cannot configure task filter %s, reason: %w", name, err)
+		}
+	}
+	return spec, nil
+}
+
+// Close releases all resources associated with this TaskIterator.
+func (ti *TaskIterator) Close() {
+	if ti.taskIter != nil {
+		ti.taskIter.Close()
+	}
+	if ti.prog != nil {
+		ti.prog.Close()
+	}
+}
+
+// NewTaskIteratorFromSource returns a new TaskIterator decoding the raw task
+// iterator output from the specified source instead of running the eBPF task
+// iterator, such as replaying a recording made with a [bpfiter.Recorder] from
+// [TaskIterator.Source] using a [bpfiter.Replayer].
+func NewTaskIteratorFromSource(src bpfiter.Source) *TaskIterator {
+	return &TaskIterator{source: src}
+}
+
+// Source returns the source of the raw task iterator output of this
+// TaskIterator.
+func (ti *TaskIterator) Source() bpfiter.Source {
+	return ti.source
+}
+
+// All returns an iterator over all tasks visible to the caller. In case of an
+// iterator failure, the iterator will return a zero Task together with an
+// error and then end the sequence.
+func (ti *TaskIterator) All() iter.Seq2[Task, error] {
+	return allTasks(context.Background(), ti.source)
 }
 
-func newTaskStatus(f io.Reader) (ts beesyTaskStatus, err error) {
-	n, err := f.Read(unsafe.Slice((*byte)(unsafe.Pointer(&ts)), unsafe.Sizeof(ts)))
+// AllContext returns an iterator over all tasks visible to the caller, see
+// [TaskIterator.All]. When the specified context is done, the iterator stops
+// reading, returning a zero Task together with ctx.Err() and then ending the
+// sequence.
+func (ti *TaskIterator) AllContext(ctx context.Context) iter.Seq2[Task, error] {
+	return allTasks(ctx, ti.source)
+}
+
+// Name returns the name of the task, which is the full name in case of
+// kthreads.
+func (ts *beesyTaskStatus) Name() string {
+	return cstr.String(ts.Fullname[:])
+}
+
+// newTask returns the Task described by the specified task status frame.
+func newTask(f bpfiter.Frame) (Task, error) {
+	ts, err := bpfiter.Payload[beesyTaskStatus](f)
 	if err != nil {
-		return ts, io.EOF
+		return Task{}, err
 	}
-	if uintptr(n) != unsafe.Sizeof(ts) {
-		return ts, io.EOF
+	task := Task{
+		PID:            ts.Pid,
+		TID:            ts.Tid,
+		PPID:           ts.Ppid,
+		PGID:           ts.Pgid,
+		SID:            ts.Sid,
+		Name:           ts.Name(),
+		State:          tasks.State(ts.State),
+		StartTime:      time.Duration(ts.StartTime),
+		LastRan:        time.Duration(ts.LastRan),
+		UID:            ts.Uid,
+		CgroupID:       ts.CgroupId,
+		LoginUID:       ts.Loginuid,
+		AuditSessionID: ts.Sessionid,
+		Kthread:        ts.Flags&taskStatusKthread != 0,
+		ChildSubreaper: ts.Flags&taskStatusChildSubreaper != 0,
+		GroupAlive:     ts.Flags&taskStatusGroupAlive != 0,
+		Namespaces: Namespaces{
+			Cgroup: uint64(ts.Namespaces.Cgroup),
+			IPC:    uint64(ts.Namespaces.Ipc),
+			Mnt:    uint64(ts.Namespaces.Mnt),
+			Net:    uint64(ts.Namespaces.Net),
+			PID:    uint64(ts.Namespaces.Pid),
+			Time:   uint64(ts.Namespaces.Time),
+			User:   uint64(ts.Namespaces.User),
+			UTS:    uint64(ts.Namespaces.Uts),
+		},
+		CPU:        ts.Cpu,
+		Node:       ts.Node,
+		Workqueue:  cstr.String(ts.WqName[:]),
+		WorkerDesc: cstr.String(ts.WqDesc[:]),
 	}
-	return ts, nil
+	if ts.TtyMajor != 0 {
+		task.TTY = unix.Mkdev(ts.TtyMajor, ts.TtyMinor)
+	}
+	return task, nil
 }
 
-func allTasks(it *link.Iter) iter.Seq[beesyTaskStatus] {
-	return func(yield func(beesyTaskStatus) bool) {
-		f, err := it.Open()
+// addFrame adds the optional data from the specified kernel stack, command
+// line, or environment frame to the task, ignoring frames of other types.
+// addFrame copies the frame payload as necessary.
+func (t *Task) addFrame(f bpfiter.Frame) {
+	switch f.Type {
+	case taskFrameKstack:
+		t.kstack = make([]uint64, 0, len(f.Payload)/8)
+		for ip := range slices.Chunk(f.Payload, 8) {
+			if len(ip) == 8 {
+				t.kstack = append(t.kstack, binary.NativeEndian.Uint64(ip))
+			}
+		}
+	case taskFrameCmdline:
+		t.cmdline = splitNulTerminated(f.Payload)
+	case taskFrameEnviron:
+		t.environ = splitNulTerminated(f.Payload)
+	}
+}
+
+// allTasks returns an iterator over the tasks emitted by the eBPF task
+// iterator source “src”, until the context is done. The iterator will never emit
+// io.EOF.
+func allTasks(ctx context.Context, src bpfiter.Source) iter.Seq2[Task, error] {
+	return func(yield func(Task, error) bool) {
+		f, err := src.Open()
 		if err != nil {
+			yield(Task{}, err)
 			return
 		}
 		defer f.Close()
-		for {
-			taskStatus, err := newTaskStatus(f)
+		done := ctx.Done()
+		var task Task
+		pending := false
+		for frame, err := range bpfiter.FramesVolatile(iteriter.NewReader(f)) {
 			if err != nil {
+				yield(Task{}, err)
+				return
+			}
+			if frame.Type != taskFrameStatus {
+				if !pending {
+					yield(Task{}, fmt.Errorf("unexpected task frame type %d without task status", frame.Type))
+					return
+				}
+				task.addFrame(frame)
+				continue
+			}
+			// a new task status frame completes the previous task.
+			if pending && !yield(task, nil) {
+				return
+			}
+			select {
+			case <-done:
+				yield(Task{}, ctx.Err())
 				return
+			default:
 			}
-			if !yield(taskStatus) {
+			if task, err = newTask(frame); err != nil {
+				yield(Task{}, err)
 				return
 			}
+			pending = true
+		}
+		if pending {
+			yield(task, nil)
 		}
 	}
 }
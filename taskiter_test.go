@@ -15,21 +15,167 @@
 package beesy
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unsafe"
 
 	"github.com/onsi/gomega/format"
+	"golang.org/x/sys/unix"
 
 	"github.com/cilium/ebpf/link"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gleak"
+	"github.com/thediveo/beesy/bpfiter"
+	"github.com/thediveo/beesy/ksym"
+	"github.com/thediveo/beesy/tasks"
 	. "github.com/thediveo/success"
 )
 
-var _ = Describe("beesy eBPF", func() {
+// taskStream returns a framed task stream consisting of the specified frames.
+func taskStream(frames ...bpfiter.Frame) []byte {
+	b := binary.NativeEndian.AppendUint32(nil, bpfiter.StreamMagic)
+	b = binary.NativeEndian.AppendUint16(b, bpfiter.StreamVersion)
+	b = binary.NativeEndian.AppendUint16(b, 0)
+	for _, f := range frames {
+		b = binary.NativeEndian.AppendUint16(b, f.Type)
+		b = binary.NativeEndian.AppendUint16(b, 0)
+		b = binary.NativeEndian.AppendUint32(b, uint32(len(f.Payload)))
+		b = append(b, f.Payload...)
+	}
+	return b
+}
+
+// taskFrames returns the frames of a task status with the specified PID and
+// name, followed by a command line frame if cmdline is non-empty.
+func taskFrames(pid int32, name string, cmdline string) []bpfiter.Frame {
+	ts := beesyTaskStatus{
+		Pid:      pid,
+		Tid:      pid,
+		Ppid:     1,
+		Uid:      1000,
+		CgroupId: 666,
+	}
+	for idx, ch := range []byte(name) {
+		ts.Fullname[idx] = int8(ch)
+	}
+	frames := []bpfiter.Frame{{
+		Type:    taskFrameStatus,
+		Payload: bytes.Clone(unsafe.Slice((*byte)(unsafe.Pointer(&ts)), unsafe.Sizeof(ts))),
+	}}
+	if cmdline != "" {
+		frames = append(frames, bpfiter.Frame{Type: taskFrameCmdline, Payload: []byte(cmdline)})
+	}
+	return frames
+}
+
+var _ = Describe("task sources", func() {
+
+	It("decodes tasks from sources", func() {
+		src := bpfiter.BytesSource(taskStream(slices.Concat(
+			taskFrames(42, "foo", ""),
+			[]bpfiter.Frame{{Type: 666, Payload: []byte("unknown")}},
+			taskFrames(666, "bar", "bar\x00--baz\x00"),
+			[]bpfiter.Frame{{Type: taskFrameKstack, Payload: binary.NativeEndian.AppendUint64(nil, 0xdeadbeef)}},
+		)...))
+		ti := NewTaskIteratorFromSource(src)
+		defer ti.Close()
+		Expect(ti.Source()).To(Equal(src))
+		var tasks []Task
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			tasks = append(tasks, task)
+		}
+		Expect(tasks).To(HaveExactElements(
+			And(HaveField("PID", int32(42)), HaveField("Name", "foo"),
+				HaveField("UID", uint32(1000)), HaveField("CgroupID", uint64(666))),
+			And(HaveField("PID", int32(666)), HaveField("Name", "bar")),
+		))
+		Expect(tasks[0].Cmdline()).To(BeNil())
+		Expect(tasks[1].Cmdline()).To(Equal([]string{"bar", "--baz"}))
+		Expect(tasks[1].KernelStack()).To(Equal([]uint64{0xdeadbeef}))
+	})
+
+	It("decodes empty sources", func() {
+		for range NewTaskIteratorFromSource(bpfiter.BytesSource(nil)).All() {
+			Fail("unexpected task")
+		}
+		for range NewTaskIteratorFromSource(bpfiter.BytesSource(taskStream())).All() {
+			Fail("unexpected task")
+		}
+	})
+
+	It("reports incomplete tasks from sources", func() {
+		stream := taskStream(taskFrames(42, "foo", "foo\x00")...)
+		var errs []error
+		for _, err := range NewTaskIteratorFromSource(bpfiter.BytesSource(stream[:len(stream)-1])).All() {
+			errs = append(errs, err)
+		}
+		Expect(errs).To(ConsistOf(MatchError(bpfiter.ErrTruncatedRecord)))
+
+		status := taskFrames(42, "foo", "")[0]
+		status.Payload = status.Payload[:len(status.Payload)-1]
+		errs = nil
+		for _, err := range NewTaskIteratorFromSource(bpfiter.BytesSource(taskStream(status))).All() {
+			errs = append(errs, err)
+		}
+		Expect(errs).To(ConsistOf(MatchError(bpfiter.ErrTruncatedRecord)))
+	})
+
+	It("rejects optional task data without task status", func() {
+		src := bpfiter.BytesSource(taskStream(bpfiter.Frame{Type: taskFrameCmdline, Payload: []byte("foo\x00")}))
+		var errs []error
+		for _, err := range NewTaskIteratorFromSource(src).All() {
+			errs = append(errs, err)
+		}
+		Expect(errs).To(ConsistOf(MatchError(ContainSubstring("without task status"))))
+	})
+
+})
+
+// ownCgroupPath returns the path of the caller's cgroup in the cgroup2
+// filesystem, or skips the current test if there is no unified cgroup
+// hierarchy.
+func ownCgroupPath() string {
+	GinkgoHelper()
+	cgroups := Successful(os.ReadFile("/proc/self/cgroup"))
+	for _, line := range strings.Split(string(cgroups), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join("/sys/fs/cgroup", path)
+		}
+	}
+	Skip("needs unified cgroup hierarchy")
+	return ""
+}
+
+var _ = Describe("task iterators", func() {
+
+	It("rejects invalid task filters", func() {
+		Expect(NewTaskIterator(WithPIDNamespace(1 << 32))).Error().To(
+			MatchError(ContainSubstring("invalid task filter")))
+	})
+
+})
+
+var _ = Describe("cgroup task iterators", func() {
+
+	It("rejects invalid cgroups and options", func() {
+		Expect(NewCgroupTaskIterator("./nada", false)).Error().To(HaveOccurred())
+		Expect(NewCgroupFDTaskIterator(-1, false, WithCmdline(42))).Error().To(
+			MatchError(ContainSubstring("don't support command lines")))
+	})
 
-	var objs beesyObjects
+})
+
+var _ = Describe("beesy eBPF", func() {
 
 	BeforeEach(func() {
 		if os.Getuid() != 0 {
@@ -38,11 +184,6 @@ var _ = Describe("beesy eBPF", func() {
 
 		format.MaxLength = 8192
 
-		Expect(loadBeesyObjects(&objs, nil)).To(Succeed())
-		DeferCleanup(func() {
-			Expect(objs.Close()).To(Succeed())
-		})
-
 		goodgoos := Goroutines()
 		DeferCleanup(func() {
 			Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
@@ -50,7 +191,11 @@ var _ = Describe("beesy eBPF", func() {
 		})
 	})
 
-	It("should load the eBPF iterator program successfully", func() {
+	It("should load the eBPF iterator programs successfully", func() {
+		var objs beesyObjects
+		Expect(loadBeesyObjects(&objs, nil)).To(Succeed())
+		defer objs.Close()
+
 		it := Successful(link.AttachIter(link.IterOptions{
 			Program: objs.DumpTaskStatus,
 		}))
@@ -58,38 +203,258 @@ var _ = Describe("beesy eBPF", func() {
 	})
 
 	It("returns full kthread names", func() {
-		it := Successful(link.AttachIter(link.IterOptions{
-			Program: objs.DumpTaskStatus,
-		}))
-		defer it.Close()
+		ti := Successful(NewTaskIterator())
+		defer ti.Close()
 
 		numKthreads := 0
 		maxNameLen := 0
-		for taskStatus := range allTasks(it) {
-			if taskStatus.Ppid != 2 {
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			if task.PPID != 2 {
 				continue
 			}
 			numKthreads++
-			maxNameLen = max(maxNameLen, len(taskStatus.Name()))
+			maxNameLen = max(maxNameLen, len(task.Name))
 		}
 		Expect(numKthreads).NotTo(BeZero(), "no kthreads seen")
 		Expect(maxNameLen).To(BeNumerically(">", 15))
 	})
 
 	It("iterates", func() {
-		it := Successful(link.AttachIter(link.IterOptions{
-			Program: objs.DumpTaskStatus,
-		}))
-		defer it.Close()
+		ti := Successful(NewTaskIterator())
+		defer ti.Close()
 
 		count := 0
-		for taskStatus := range allTasks(it) {
-			Expect(taskStatus.Pid).NotTo(BeZero())
-			Expect(taskStatus.Tid).NotTo(BeZero())
-			Expect(taskStatus.Name()).NotTo(BeEmpty())
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.PID).NotTo(BeZero())
+			Expect(task.TID).NotTo(BeZero())
+			Expect(task.Name).NotTo(BeEmpty())
+			Expect(task.Cmdline()).To(BeNil())
 			count++
 		}
 		Expect(count).NotTo(BeZero())
 	})
 
+	It("returns command lines and environments", func() {
+		ti := Successful(NewTaskIterator(WithCmdline(MaxUsermemLen), WithEnviron(MaxUsermemLen)))
+		defer ti.Close()
+
+		ownPID := int32(os.Getpid())
+		found := false
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			if task.PID != ownPID || task.TID != ownPID {
+				continue
+			}
+			found = true
+			Expect(task.Cmdline()).To(Equal(os.Args))
+			Expect(task.Environ()).To(ContainElement(HavePrefix("PATH=")))
+		}
+		Expect(found).To(BeTrue(), "missing own process")
+	})
+
+	It("caps huge environments to fit the iterator output buffer", func() {
+		huge := exec.Command("sleep", "10")
+		huge.Env = []string{"HUGE=" + strings.Repeat("x", 64*1024)}
+		Expect(huge.Start()).To(Succeed())
+		defer func() {
+			_ = huge.Process.Kill()
+			_ = huge.Wait()
+		}()
+
+		ti := Successful(NewTaskIterator(WithCmdline(MaxUsermemLen), WithEnviron(MaxUsermemLen)))
+		defer ti.Close()
+
+		hugePID := int32(huge.Process.Pid)
+		Eventually(func() []string {
+			for task, err := range ti.All() {
+				Expect(err).NotTo(HaveOccurred())
+				if task.PID == hugePID && task.TID == hugePID && task.Name == "sleep" {
+					return task.Environ()
+				}
+			}
+			return nil
+		}).Should(ConsistOf(And(
+			HavePrefix("HUGE=xxx"),
+			HaveLen(MaxUsermemLen))))
+	})
+
+	It("returns kernel stacks only for the requested task states", func() {
+		ti := Successful(NewTaskIterator(WithKernelStacks(tasks.Sleeping, tasks.Idle)))
+		defer ti.Close()
+
+		kallsyms := Successful(ksym.Load())
+		numStacks := 0
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			switch task.State {
+			case tasks.Sleeping, tasks.Idle:
+				if len(task.KernelStack()) == 0 {
+					continue
+				}
+				numStacks++
+				Expect(kallsyms.Symbolize(task.KernelStack())).To(
+					ContainElement(ContainSubstring("schedule")))
+			default:
+				Expect(task.KernelStack()).To(BeEmpty())
+			}
+		}
+		Expect(numStacks).NotTo(BeZero())
+	})
+
+	It("returns kworker workqueue information and CPU bindings", func() {
+		ti := Successful(NewTaskIterator())
+		defer ti.Close()
+
+		numKworkers := 0
+		numPerCPU := 0
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			if !task.Kthread {
+				Expect(task.CPU).To(Equal(int32(-1)))
+				Expect(task.Workqueue).To(BeEmpty())
+				Expect(task.WorkerDesc).To(BeEmpty())
+				continue
+			}
+			if task.CPU >= 0 {
+				numPerCPU++
+			}
+			if strings.HasPrefix(task.Name, "kworker/") && task.WorkerDesc != "" {
+				numKworkers++
+			}
+		}
+		Expect(numPerCPU).NotTo(BeZero(), "no per-CPU kthreads seen")
+		Expect(numKworkers).NotTo(BeZero(), "no kworkers with descriptions seen")
+	})
+
+	It("returns process group, session, audit login, and namespace information", func() {
+		ti := Successful(NewTaskIterator())
+		defer ti.Close()
+
+		ownPID := int32(os.Getpid())
+		found := false
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			if task.TID != ownPID {
+				continue
+			}
+			found = true
+			Expect(task.PGID).To(Equal(int32(Successful(unix.Getpgid(0)))))
+			Expect(task.SID).To(Equal(int32(Successful(unix.Getsid(0)))))
+			for nstype, nsino := range map[string]uint64{
+				"mnt": task.Namespaces.Mnt,
+				"net": task.Namespaces.Net,
+				"pid": task.Namespaces.PID,
+			} {
+				var stat unix.Stat_t
+				Expect(unix.Stat("/proc/self/ns/"+nstype, &stat)).To(Succeed())
+				Expect(nsino).To(Equal(stat.Ino), "wrong %s namespace", nstype)
+			}
+			if loginuid, err := os.ReadFile("/proc/self/loginuid"); err == nil {
+				Expect(strconv.FormatUint(uint64(task.LoginUID), 10)).To(Equal(string(loginuid)))
+			}
+		}
+		Expect(found).To(BeTrue(), "missing own process")
+	})
+
+	It("stops iterating when the context is done", func() {
+		ti := Successful(NewTaskIterator())
+		defer ti.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		count := 0
+		var errs []error
+		for _, err := range ti.AllContext(ctx) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			count++
+			if count == 2 {
+				cancel()
+			}
+		}
+		Expect(count).To(Equal(2))
+		Expect(errs).To(ConsistOf(MatchError(context.Canceled)))
+	})
+
+	It("filters tasks in-kernel", func() {
+		var own Task
+		ti := Successful(NewTaskIterator())
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			if task.TID == int32(os.Getpid()) {
+				own = task
+			}
+		}
+		ti.Close()
+		Expect(own.TID).NotTo(BeZero(), "missing own process")
+		Expect(own.UID).To(Equal(uint32(os.Getuid())))
+		Expect(own.CgroupID).NotTo(BeZero())
+
+		for _, tt := range []struct {
+			opt     Option
+			matcher func(Task) bool
+			own     bool
+		}{
+			{WithKthreadsOnly(), func(t Task) bool { return t.Kthread }, false},
+			{WithUserTasksOnly(), func(t Task) bool { return !t.Kthread }, true},
+			{WithNamePrefix(own.Name[:3]), func(t Task) bool { return strings.HasPrefix(t.Name, own.Name[:3]) }, true},
+			{WithNamePrefix("kworker/"), func(t Task) bool { return strings.HasPrefix(t.Name, "kworker/") }, false},
+			{WithUID(own.UID), func(t Task) bool { return t.UID == own.UID }, true},
+			{WithCgroupID(own.CgroupID), func(t Task) bool { return t.CgroupID == own.CgroupID }, true},
+			{WithPIDNamespace(own.Namespaces.PID), func(t Task) bool { return t.Namespaces.PID == own.Namespaces.PID }, true},
+		} {
+			ti := Successful(NewTaskIterator(tt.opt))
+			count := 0
+			found := false
+			for task, err := range ti.All() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tt.matcher(task)).To(BeTrue(), "unexpected task %+v", task)
+				count++
+				found = found || task.TID == own.TID
+			}
+			ti.Close()
+			Expect(count).NotTo(BeZero())
+			Expect(found).To(Equal(tt.own))
+		}
+	})
+
+	It("iterates only the tasks of a cgroup", func() {
+		cgroupPath := ownCgroupPath()
+		var stat unix.Stat_t
+		Expect(unix.Stat(cgroupPath, &stat)).To(Succeed())
+
+		ti := Successful(NewCgroupTaskIterator(cgroupPath, false))
+		defer ti.Close()
+		found := false
+		for task, err := range ti.All() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.CgroupID).To(Equal(stat.Ino))
+			found = found || task.TID == int32(os.Getpid())
+		}
+		Expect(found).To(BeTrue(), "missing own process")
+	})
+
+	It("iterates the tasks of a cgroup and its descendants", func() {
+		_ = ownCgroupPath()
+
+		count := func(ti *TaskIterator) int {
+			defer ti.Close()
+			n := 0
+			for _, err := range ti.All() {
+				Expect(err).NotTo(HaveOccurred())
+				n++
+			}
+			return n
+		}
+		all := count(Successful(NewTaskIterator()))
+		Expect(count(Successful(NewCgroupTaskIterator("/sys/fs/cgroup", true)))).To(
+			BeNumerically("~", all, all/10))
+		Expect(count(Successful(NewCgroupTaskIterator("/sys/fs/cgroup", true, WithUserTasksOnly())))).To(
+			BeNumerically("<", all))
+	})
+
 })
@@ -22,3 +22,15 @@ const CommSize = 16
 // field, as there must always be a terminating zero byte which we don't count
 // into the name length.
 const MaxCommLen = CommSize - 1
+
+// AuditUIDUnset is the audit login UID of tasks for which no login UID has
+// been set.
+//
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/audit.h#L18
+const AuditUIDUnset = ^uint32(0)
+
+// AuditSessionIDUnset is the audit session ID of tasks not belonging to any
+// audit session.
+//
+// https://elixir.bootlin.com/linux/v6.12/source/include/uapi/linux/audit.h#L502
+const AuditSessionIDUnset = ^uint32(0)
@@ -0,0 +1,77 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package tasks
+
+// State is the state of a task, as also reported in the third field of
+// “/proc/$PID/stat”. Please note that the State values are not the raw kernel
+// task state bits, but instead the (reporting) state indices as returned by
+// the kernel's [task_state_index].
+//
+// [task_state_index]: https://elixir.bootlin.com/linux/v6.12/source/include/linux/sched.h#L1624
+type State uint32
+
+// Task states in the order of the kernel's reporting state indices.
+const (
+	Running     State = iota // R (running)
+	Sleeping                 // S (sleeping), interruptible sleep
+	DiskSleep                // D (disk sleep), uninterruptible sleep
+	Stopped                  // T (stopped)
+	TracingStop              // t (tracing stop)
+	Dead                     // X (dead)
+	Zombie                   // Z (zombie)
+	Parked                   // P (parked)
+	Idle                     // I (idle), uninterruptible, but not contributing to the load
+)
+
+// https://elixir.bootlin.com/linux/v6.12/source/fs/proc/array.c#L127
+var stateNames = [...]string{
+	"R (running)",
+	"S (sleeping)",
+	"D (disk sleep)",
+	"T (stopped)",
+	"t (tracing stop)",
+	"X (dead)",
+	"Z (zombie)",
+	"P (parked)",
+	"I (idle)",
+}
+
+// String returns the state in the same textual format as used in
+// “/proc/$PID/status”, such as “D (disk sleep)”.
+func (s State) String() string {
+	if int(s) >= len(stateNames) {
+		return "? (unknown)"
+	}
+	return stateNames[s]
+}
+
+// Letter returns the single letter state as shown by ps, such as “D”.
+func (s State) Letter() byte {
+	return s.String()[0]
+}
+
+// StateMask returns a bit mask with the bits set corresponding to the
+// specified states. If no states are specified at all, then StateMask returns
+// a mask with all state bits set.
+func StateMask(states ...State) uint32 {
+	if len(states) == 0 {
+		return ^uint32(0)
+	}
+	var mask uint32
+	for _, s := range states {
+		mask |= 1 << s
+	}
+	return mask
+}
@@ -0,0 +1,38 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package tasks
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("task states", func() {
+
+	It("stringifies", func() {
+		Expect(Running.String()).To(Equal("R (running)"))
+		Expect(DiskSleep.String()).To(Equal("D (disk sleep)"))
+		Expect(Idle.String()).To(Equal("I (idle)"))
+		Expect(State(42).String()).To(Equal("? (unknown)"))
+		Expect(TracingStop.Letter()).To(Equal(byte('t')))
+	})
+
+	It("returns state masks", func() {
+		Expect(StateMask()).To(Equal(^uint32(0)))
+		Expect(StateMask(Running)).To(Equal(uint32(1)))
+		Expect(StateMask(DiskSleep, Zombie)).To(Equal(uint32(1<<2 | 1<<6)))
+	})
+
+})
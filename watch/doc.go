@@ -0,0 +1,57 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+/*
+Package watch watches processes and threads getting forked, exec'ed, and
+exiting, using the kernel's “sched_process_fork”, “sched_process_exec”, and
+“sched_process_exit” tracepoints.
+
+In contrast to the point-in-time task iterators, a [Watcher] doesn't miss any
+short-lived processes between polls. It delivers typed [Event]s over a BPF ring
+buffer either as an iterator sequence or as a channel:
+
+	w, err := watch.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for ev, err := range w.AllContext(ctx) {
+		if err != nil {
+			return err
+		}
+		fmt.Println(ev.Type, ev.PID, ev.Name)
+	}
+
+Events carry the PIDs and TIDs as seen from both the initial PID namespace and
+the PID namespace of the watching process, as well as the task start times as
+stable task identities. In case the ring buffer becomes full, events get lost;
+[Watcher.Lost] returns the number of lost events.
+
+A [ProcessTable] combines an initial task snapshot with the events of a
+[Watcher] into a live process table, offering concurrent-safe lookups and
+change notifications:
+
+	pt, err := watch.NewProcessTable(ctx)
+	if err != nil {
+		return err
+	}
+	defer pt.Close()
+	for change := range pt.Subscribe(ctx) {
+		fmt.Println(change.Type, change.Process.PID, change.Process.Name)
+	}
+
+A ProcessTable resyncs from the task iterator as soon as it notices lost
+events, as well as periodically in order to pick up re-parented processes.
+*/
+package watch
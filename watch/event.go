@@ -0,0 +1,102 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+import (
+	"errors"
+	"time"
+	"unsafe"
+
+	"github.com/thediveo/beesy/internal/cstr"
+	"golang.org/x/sys/unix"
+)
+
+// EventType is the type of a process [Event].
+type EventType uint32
+
+// Event types, see EVENT_xxx in watch.bpf.c.
+const (
+	Fork EventType = iota + 1 // a new process or thread has been created.
+	Exec                      // a process has executed a new program.
+	Exit                      // a process or thread has exited.
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case Fork:
+		return "fork"
+	case Exec:
+		return "exec"
+	case Exit:
+		return "exit"
+	}
+	return "unknown"
+}
+
+// Event describes a process or thread having been forked, exec'ed, or exited.
+// In case a process or thread isn't visible from the PID namespace of the
+// watching process, its PID, TID, and PPID are zero.
+type Event struct {
+	Type EventType
+	// Timestamp of this event, in CLOCK_MONOTONIC time.
+	Timestamp time.Duration
+	// StartTime of the task, in CLOCK_MONOTONIC time. Together with the
+	// RootTID, it identifies the task stably, as TIDs might get reused.
+	StartTime time.Duration
+	RootPID   int32 // PID in the initial PID namespace.
+	RootTID   int32 // TID in the initial PID namespace.
+	RootPPID  int32 // PPID in the initial PID namespace.
+	PID       int32 // PID as seen from the watcher's PID namespace, or 0.
+	TID       int32 // TID as seen from the watcher's PID namespace, or 0.
+	PPID      int32 // PPID as seen from the watcher's PID namespace, or 0.
+	// Name of the task, with the new name for exec events. Please note that
+	// this is the “length-challenged” task name of at most 15 characters.
+	Name string
+	// Status of an exited task in wait(2) format, such as for retrieving its
+	// exit code using Status.ExitStatus(); zero for other events.
+	Status unix.WaitStatus
+}
+
+// IsThread returns true if the event is about a thread other than the main
+// thread of a process.
+func (e *Event) IsThread() bool {
+	return e.RootTID != e.RootPID
+}
+
+// errShortEvent is returned for event records that are too short.
+var errShortEvent = errors.New("short event record")
+
+// newEvent returns the Event decoded from the specified raw event record.
+func newEvent(record []byte) (Event, error) {
+	var ev watchEvent
+	if len(record) < int(unsafe.Sizeof(ev)) {
+		return Event{}, errShortEvent
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&ev)), unsafe.Sizeof(ev)), record)
+	return Event{
+		Type:      EventType(ev.Type),
+		Timestamp: time.Duration(ev.Timestamp),
+		StartTime: time.Duration(ev.StartTime),
+		RootPID:   ev.RootPid,
+		RootTID:   ev.RootTid,
+		RootPPID:  ev.RootPpid,
+		PID:       ev.Pid,
+		TID:       ev.Tid,
+		PPID:      ev.Ppid,
+		Name:      cstr.String(ev.Name[:]),
+		Status:    unix.WaitStatus(ev.ExitCode),
+	}, nil
+}
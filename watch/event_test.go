@@ -0,0 +1,80 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+import (
+	"bytes"
+	"time"
+	"unsafe"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/thediveo/success"
+)
+
+// eventRecord returns the binary representation of a process event, as
+// emitted by the eBPF tracepoint programs.
+func eventRecord(ev watchEvent, name string) []byte {
+	for idx := range min(len(name), len(ev.Name)-1) {
+		ev.Name[idx] = int8(name[idx])
+	}
+	return bytes.Clone(unsafe.Slice((*byte)(unsafe.Pointer(&ev)), unsafe.Sizeof(ev)))
+}
+
+var _ = Describe("events", func() {
+
+	It("names event types", func() {
+		Expect(Fork.String()).To(Equal("fork"))
+		Expect(Exec.String()).To(Equal("exec"))
+		Expect(Exit.String()).To(Equal("exit"))
+		Expect(EventType(0).String()).To(Equal("unknown"))
+	})
+
+	It("decodes event records", func() {
+		ev := Successful(newEvent(eventRecord(watchEvent{
+			Timestamp: 2_000_000_000,
+			StartTime: 1_000_000_000,
+			Type:      uint32(Exit),
+			RootPid:   4242,
+			RootTid:   4243,
+			RootPpid:  1,
+			Pid:       42,
+			Tid:       43,
+			Ppid:      0,
+			ExitCode:  3 << 8,
+		}, "foobar")))
+		Expect(ev).To(Equal(Event{
+			Type:      Exit,
+			Timestamp: 2 * time.Second,
+			StartTime: 1 * time.Second,
+			RootPID:   4242,
+			RootTID:   4243,
+			RootPPID:  1,
+			PID:       42,
+			TID:       43,
+			Name:      "foobar",
+			Status:    3 << 8,
+		}))
+		Expect(ev.IsThread()).To(BeTrue())
+		Expect(ev.Status.Exited()).To(BeTrue())
+		Expect(ev.Status.ExitStatus()).To(Equal(3))
+	})
+
+	It("rejects short event records", func() {
+		rec := eventRecord(watchEvent{}, "")
+		Expect(newEvent(rec[:len(rec)-1])).Error().To(MatchError(errShortEvent))
+	})
+
+})
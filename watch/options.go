@@ -0,0 +1,47 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+// DefaultRingBufferSize is the default size in bytes of the ring buffer
+// transferring events from the kernel to user space.
+const DefaultRingBufferSize = 256 * 1024
+
+// Option configures a [Watcher] when creating it using [NewWatcher].
+type Option func(*options)
+
+type options struct {
+	ringBufferSize uint32
+}
+
+// newOptions returns the options for the specified Option functions.
+func newOptions(opts ...Option) options {
+	o := options{
+		ringBufferSize: DefaultRingBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRingBufferSize sets the size in bytes of the ring buffer transferring
+// events from the kernel to user space. The size must be a power of two and a
+// multiple of the page size. Larger ring buffers lose fewer events during
+// bursts of process activity.
+func WithRingBufferSize(size uint32) Option {
+	return func(o *options) {
+		o.ringBufferSize = size
+	}
+}
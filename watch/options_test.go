@@ -0,0 +1,32 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("options", func() {
+
+	It("defaults to the default ring buffer size", func() {
+		Expect(newOptions().ringBufferSize).To(Equal(uint32(DefaultRingBufferSize)))
+	})
+
+	It("sets the ring buffer size", func() {
+		Expect(newOptions(WithRingBufferSize(1024 * 1024)).ringBufferSize).To(Equal(uint32(1024 * 1024)))
+	})
+
+})
@@ -0,0 +1,544 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/tasks"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultResyncInterval is the default interval for resyncing a
+// [ProcessTable] from the task iterator.
+const DefaultResyncInterval = time.Minute
+
+// lostEventsCheckInterval is the interval for checking a [ProcessTable]'s
+// watcher for lost events, in order to resync as soon as possible.
+const lostEventsCheckInterval = time.Second
+
+// SubscriberBufferSize is the number of changes buffered per subscriber of a
+// [ProcessTable] before the subscription gets dropped.
+const SubscriberBufferSize = 1024
+
+// Process is a process in a [ProcessTable]. All PIDs are from the perspective
+// of the initial PID namespace.
+type Process struct {
+	PID  int32  // PID of this process.
+	PPID int32  // PID of the real parent process, or zero.
+	Name string // process name; kthreads get their full name when resyncing.
+	// StartTime of this process, in CLOCK_MONOTONIC time. Together with the
+	// PID, it identifies the process stably, as PIDs might get reused.
+	StartTime time.Duration
+}
+
+// ChangeType is the type of a process [Change].
+type ChangeType int
+
+// Process change types.
+const (
+	Added   ChangeType = iota + 1 // a process has been added.
+	Updated                       // a process has changed its name or parent.
+	Removed                       // a process has been removed.
+)
+
+// String returns the name of the change type.
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Updated:
+		return "updated"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Change describes a change to a [ProcessTable].
+type Change struct {
+	Type    ChangeType
+	Process Process // added, updated, or removed process.
+	Old     Process // process before an update; zero otherwise.
+}
+
+// TableOption configures a [ProcessTable] when creating it using
+// [NewProcessTable].
+type TableOption func(*tableOptions)
+
+type tableOptions struct {
+	resyncInterval time.Duration
+	watcherOpts    []Option
+}
+
+// newTableOptions returns the options for the specified TableOption functions.
+func newTableOptions(opts ...TableOption) tableOptions {
+	o := tableOptions{
+		resyncInterval: DefaultResyncInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithResyncInterval sets the interval for periodically resyncing a
+// [ProcessTable] from the task iterator, healing any lost events. A zero
+// interval disables periodic resyncing.
+func WithResyncInterval(interval time.Duration) TableOption {
+	return func(o *tableOptions) {
+		o.resyncInterval = interval
+	}
+}
+
+// WithWatcherOptions configures the [Watcher] used by a [ProcessTable].
+func WithWatcherOptions(opts ...Option) TableOption {
+	return func(o *tableOptions) {
+		o.watcherOpts = append(o.watcherOpts, opts...)
+	}
+}
+
+// ProcessTable is a live table of the processes, seeded from the task
+// iterator and then kept in sync with the kernel using the process fork, exec,
+// and exit events of a [Watcher]. A ProcessTable periodically resyncs from the
+// task iterator in order to pick up re-parented processes, as the kernel
+// doesn't emit events for them. Additionally, a ProcessTable resyncs as soon as
+// it notices events lost due to a full ring buffer. A ProcessTable is safe for
+// concurrent use.
+//
+// Please note that a process gets removed as soon as its main thread exits,
+// even if the process has further threads that are still running.
+type ProcessTable struct {
+	// mu protects the table and must be held while notifying subscribers, so
+	// that subscribers receive the changes in the order they were applied.
+	// The lock order thus is mu before subsMu.
+	mu        sync.RWMutex
+	procs     map[int32]Process
+	resyncing bool    // collect events while resyncing...
+	pending   []Event // ...in order to replay them on the fresh snapshot.
+	err       error   // event reading failure, if any.
+
+	resyncMu sync.Mutex // serializes resyncs.
+	tasks    func(ctx context.Context) iter.Seq2[beesy.Task, error]
+	lost     func() (uint64, error) // number of lost events so far.
+
+	subsMu sync.Mutex
+	subs   map[*subscription]struct{}
+
+	watcher *Watcher
+	taskit  *beesy.TaskIterator
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// subscription is a subscription to the changes of a ProcessTable.
+type subscription struct {
+	ch chan Change
+}
+
+// NewProcessTable returns a new ProcessTable, seeded from the task iterator
+// and then kept in sync using a [Watcher]. Use [ProcessTable.Close] to release
+// the table's resources when done.
+func NewProcessTable(ctx context.Context, opts ...TableOption) (*ProcessTable, error) {
+	o := newTableOptions(opts...)
+	// start watching before seeding, so that we don't miss any events while
+	// seeding.
+	w, err := NewWatcher(o.watcherOpts...)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := beesy.NewTaskIterator()
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	pt := newProcessTable(ti.AllContext)
+	pt.lost = w.Lost
+	pt.watcher = w
+	pt.taskit = ti
+	loopctx, cancel := context.WithCancel(context.Background())
+	pt.cancel = cancel
+	pt.wg.Add(1)
+	go func() {
+		defer pt.wg.Done()
+		pt.watch(loopctx)
+	}()
+	if err := pt.Resync(ctx); err != nil {
+		pt.Close()
+		return nil, err
+	}
+	pt.wg.Add(1)
+	go func() {
+		defer pt.wg.Done()
+		pt.resyncOnLostEvents(loopctx, lostEventsCheckInterval)
+	}()
+	if o.resyncInterval > 0 {
+		pt.wg.Add(1)
+		go func() {
+			defer pt.wg.Done()
+			pt.resyncPeriodically(loopctx, o.resyncInterval)
+		}()
+	}
+	return pt, nil
+}
+
+// newProcessTable returns a new, empty ProcessTable that resyncs from the
+// specified task sequence, but doesn't watch for events yet.
+func newProcessTable(tasks func(ctx context.Context) iter.Seq2[beesy.Task, error]) *ProcessTable {
+	return &ProcessTable{
+		procs: map[int32]Process{},
+		tasks: tasks,
+		subs:  map[*subscription]struct{}{},
+	}
+}
+
+// Close releases all resources associated with this ProcessTable and closes
+// all subscription channels.
+func (pt *ProcessTable) Close() {
+	if pt.cancel != nil {
+		pt.cancel()
+	}
+	if pt.watcher != nil {
+		pt.watcher.Close()
+	}
+	pt.wg.Wait()
+	if pt.taskit != nil {
+		pt.taskit.Close()
+	}
+	pt.subsMu.Lock()
+	defer pt.subsMu.Unlock()
+	for sub := range pt.subs {
+		close(sub.ch)
+	}
+	clear(pt.subs)
+}
+
+// Lookup returns the process with the specified PID, as seen from the initial
+// PID namespace, and true if found; otherwise, it returns a zero Process and
+// false.
+func (pt *ProcessTable) Lookup(pid int32) (Process, bool) {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	proc, ok := pt.procs[pid]
+	return proc, ok
+}
+
+// Len returns the number of processes in this table.
+func (pt *ProcessTable) Len() int {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return len(pt.procs)
+}
+
+// Processes returns the processes in this table, sorted by PID.
+func (pt *ProcessTable) Processes() []Process {
+	pt.mu.RLock()
+	procs := slices.Collect(maps.Values(pt.procs))
+	pt.mu.RUnlock()
+	slices.SortFunc(procs, func(a, b Process) int { return cmp.Compare(a.PID, b.PID) })
+	return procs
+}
+
+// Err returns the error that stopped this table from receiving process events,
+// if any. The table then only gets updated when resyncing.
+func (pt *ProcessTable) Err() error {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return pt.err
+}
+
+// Subscribe returns a channel receiving the changes to this table. The
+// channel gets closed when the specified context is done or the table has
+// been closed. In case a subscriber falls behind by more than
+// [SubscriberBufferSize] changes, its subscription gets dropped and its
+// channel closed, so that the subscriber can resubscribe and then
+// reconcile using [ProcessTable.Processes].
+func (pt *ProcessTable) Subscribe(ctx context.Context) <-chan Change {
+	sub := &subscription{ch: make(chan Change, SubscriberBufferSize)}
+	pt.subsMu.Lock()
+	pt.subs[sub] = struct{}{}
+	pt.subsMu.Unlock()
+	context.AfterFunc(ctx, func() { pt.unsubscribe(sub) })
+	return sub.ch
+}
+
+// unsubscribe drops the specified subscription, closing its channel, unless
+// already dropped.
+func (pt *ProcessTable) unsubscribe(sub *subscription) {
+	pt.subsMu.Lock()
+	defer pt.subsMu.Unlock()
+	if _, ok := pt.subs[sub]; !ok {
+		return
+	}
+	delete(pt.subs, sub)
+	close(sub.ch)
+}
+
+// notify sends the specified changes to all subscribers, dropping subscribers
+// that fell behind. The caller must hold pt.mu, see [ProcessTable].
+func (pt *ProcessTable) notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	pt.subsMu.Lock()
+	defer pt.subsMu.Unlock()
+	for sub := range pt.subs {
+		for _, change := range changes {
+			select {
+			case sub.ch <- change:
+				continue
+			default:
+			}
+			delete(pt.subs, sub)
+			close(sub.ch)
+			break
+		}
+	}
+}
+
+// watch applies the process events from the watcher until the specified
+// context is done or the watcher fails.
+func (pt *ProcessTable) watch(ctx context.Context) {
+	for ev, err := range pt.watcher.AllContext(ctx) {
+		if err != nil {
+			if ctx.Err() == nil {
+				pt.mu.Lock()
+				pt.err = fmt.Errorf("cannot watch processes, reason: %w", err)
+				pt.mu.Unlock()
+			}
+			return
+		}
+		pt.apply(ev)
+	}
+}
+
+// resyncPeriodically resyncs this table in the specified interval until the
+// specified context is done.
+func (pt *ProcessTable) resyncPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = pt.Resync(ctx)
+		}
+	}
+}
+
+// resyncOnLostEvents checks the number of lost events in the specified
+// interval, resyncing this table whenever this number has increased, until
+// the specified context is done.
+func (pt *ProcessTable) resyncOnLostEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var seen uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lost, err := pt.lost()
+			// in case of failure, simply check again later; periodic resyncs
+			// will heal any lost events anyway.
+			if err != nil || lost == seen {
+				continue
+			}
+			if pt.Resync(ctx) == nil {
+				seen = lost
+			}
+		}
+	}
+}
+
+// apply applies the specified process event to this table, notifying
+// subscribers about the resulting change, if any.
+func (pt *ProcessTable) apply(ev Event) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.resyncing {
+		pt.pending = append(pt.pending, ev)
+	}
+	pt.notify(applyEvent(pt.procs, ev))
+}
+
+// Resync resyncs this table from the task iterator, healing any lost events
+// and picking up re-parented processes. Exit events received while resyncing
+// get replayed on top of the fresh task snapshot, as well as fork and exec
+// events of processes not in the snapshot. Fork and exec events of processes
+// in the snapshot are skipped instead, as the snapshot might already reflect
+// later changes, such as re-parenting; in consequence, an exec racing with the
+// snapshot might only show up with the next exec event or resync.
+func (pt *ProcessTable) Resync(ctx context.Context) error {
+	pt.resyncMu.Lock()
+	defer pt.resyncMu.Unlock()
+
+	// start collecting events before taking the start time, so that we
+	// collect all events happening after the start time.
+	pt.mu.Lock()
+	pt.resyncing = true
+	pt.pending = nil
+	pt.mu.Unlock()
+	stopResyncing := func() {
+		pt.resyncing = false
+		pt.pending = nil
+	}
+	start, err := monotonicNow()
+	if err != nil {
+		pt.mu.Lock()
+		stopResyncing()
+		pt.mu.Unlock()
+		return err
+	}
+	procs := map[int32]Process{}
+	for task, err := range pt.tasks(ctx) {
+		if err != nil {
+			pt.mu.Lock()
+			stopResyncing()
+			pt.mu.Unlock()
+			return fmt.Errorf("cannot resync process table, reason: %w", err)
+		}
+		// skip threads other than the main threads, as well as processes
+		// that have already exited but not yet been reaped.
+		if task.TID != task.PID || task.State == tasks.Zombie || task.State == tasks.Dead {
+			continue
+		}
+		procs[task.PID] = Process{
+			PID:       task.PID,
+			PPID:      task.PPID,
+			Name:      task.Name,
+			StartTime: task.StartTime,
+		}
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	// the task snapshot might or might not reflect the events that happened
+	// while taking it, so replay them. However, fork and exec events must not
+	// overwrite newer information about the same process in the snapshot.
+	snapshot := maps.Clone(procs)
+	for _, ev := range pt.pending {
+		if ev.Timestamp < start {
+			continue
+		}
+		if ev.Type != Exit {
+			if proc, ok := snapshot[ev.RootPID]; ok && proc.StartTime == ev.StartTime {
+				continue
+			}
+		}
+		applyEvent(procs, ev)
+	}
+	pt.notify(diffProcesses(pt.procs, procs))
+	pt.procs = procs
+	stopResyncing()
+	return nil
+}
+
+// applyEvent applies the specified process event to the specified processes,
+// returning the resulting changes, if any. Applying an event multiple times is
+// idempotent.
+func applyEvent(procs map[int32]Process, ev Event) []Change {
+	if ev.IsThread() {
+		return nil
+	}
+	old, ok := procs[ev.RootPID]
+	if ok && ev.StartTime < old.StartTime {
+		return nil // stale event of a previous process with this PID.
+	}
+	var changes []Change
+	if ok && ev.StartTime > old.StartTime {
+		// we've missed the exit of the previous process with this PID.
+		delete(procs, old.PID)
+		changes = append(changes, Change{Type: Removed, Process: old})
+		ok = false
+	}
+	switch ev.Type {
+	case Fork:
+		if ok {
+			return changes
+		}
+		proc := Process{
+			PID:       ev.RootPID,
+			PPID:      ev.RootPPID,
+			Name:      ev.Name,
+			StartTime: ev.StartTime,
+		}
+		procs[proc.PID] = proc
+		return append(changes, Change{Type: Added, Process: proc})
+	case Exec:
+		proc := Process{
+			PID:       ev.RootPID,
+			PPID:      ev.RootPPID,
+			Name:      ev.Name,
+			StartTime: ev.StartTime,
+		}
+		procs[proc.PID] = proc
+		switch {
+		case !ok: // we've missed the fork.
+			return append(changes, Change{Type: Added, Process: proc})
+		case proc != old:
+			return append(changes, Change{Type: Updated, Process: proc, Old: old})
+		}
+	case Exit:
+		if ok {
+			delete(procs, old.PID)
+			return append(changes, Change{Type: Removed, Process: old})
+		}
+	}
+	return changes
+}
+
+// diffProcesses returns the changes from the old to the new processes, sorted
+// by PID.
+func diffProcesses(old, new map[int32]Process) []Change {
+	var changes []Change
+	for pid, proc := range old {
+		if newProc, ok := new[pid]; !ok || newProc.StartTime != proc.StartTime {
+			changes = append(changes, Change{Type: Removed, Process: proc})
+		}
+	}
+	for pid, proc := range new {
+		oldProc, ok := old[pid]
+		switch {
+		case !ok || oldProc.StartTime != proc.StartTime:
+			changes = append(changes, Change{Type: Added, Process: proc})
+		case oldProc != proc:
+			changes = append(changes, Change{Type: Updated, Process: proc, Old: oldProc})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		// removals before additions of reused PIDs.
+		return cmp.Or(cmp.Compare(a.Process.PID, b.Process.PID), cmp.Compare(b.Type, a.Type))
+	})
+	return changes
+}
+
+// monotonicNow returns the current CLOCK_MONOTONIC time.
+func monotonicNow() (time.Duration, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, fmt.Errorf("cannot read monotonic clock, reason: %w", err)
+	}
+	return time.Duration(ts.Nano()), nil
+}
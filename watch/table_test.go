@@ -0,0 +1,380 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thediveo/beesy"
+	"github.com/thediveo/beesy/tasks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+// taskSeq returns a task sequence function for resyncing, calling the
+// optional during function after the first task.
+func taskSeq(ts []beesy.Task, during func()) func(context.Context) iter.Seq2[beesy.Task, error] {
+	return func(context.Context) iter.Seq2[beesy.Task, error] {
+		return func(yield func(beesy.Task, error) bool) {
+			for idx, t := range ts {
+				if !yield(t, nil) {
+					return
+				}
+				if idx == 0 && during != nil {
+					during()
+				}
+			}
+		}
+	}
+}
+
+// proc returns a test process.
+func proc(pid int32, start int, ppid int32, name string) Process {
+	return Process{PID: pid, PPID: ppid, Name: name, StartTime: time.Duration(start) * time.Second}
+}
+
+// event returns a test event for the specified process.
+func event(typ EventType, p Process) Event {
+	return Event{
+		Type:      typ,
+		Timestamp: math.MaxInt64,
+		StartTime: p.StartTime,
+		RootPID:   p.PID,
+		RootTID:   p.PID,
+		RootPPID:  p.PPID,
+		Name:      p.Name,
+	}
+}
+
+// task returns a test task for the specified process.
+func task(p Process) beesy.Task {
+	return beesy.Task{
+		PID:       p.PID,
+		TID:       p.PID,
+		PPID:      p.PPID,
+		Name:      p.Name,
+		StartTime: p.StartTime,
+		State:     tasks.Sleeping,
+	}
+}
+
+var _ = Describe("process table", func() {
+
+	It("names change types", func() {
+		Expect(Added.String()).To(Equal("added"))
+		Expect(Updated.String()).To(Equal("updated"))
+		Expect(Removed.String()).To(Equal("removed"))
+		Expect(ChangeType(0).String()).To(Equal("unknown"))
+	})
+
+	It("configures options", func() {
+		o := newTableOptions()
+		Expect(o.resyncInterval).To(Equal(DefaultResyncInterval))
+		Expect(o.watcherOpts).To(BeEmpty())
+		o = newTableOptions(WithResyncInterval(0), WithWatcherOptions(WithRingBufferSize(4096)))
+		Expect(o.resyncInterval).To(BeZero())
+		Expect(o.watcherOpts).To(HaveLen(1))
+	})
+
+	Context("applying events", func() {
+
+		It("ignores threads", func() {
+			procs := map[int32]Process{}
+			ev := event(Fork, proc(42, 1, 1, "foo"))
+			ev.RootTID = 43
+			Expect(applyEvent(procs, ev)).To(BeEmpty())
+			Expect(procs).To(BeEmpty())
+		})
+
+		It("tracks a process lifecycle idempotently", func() {
+			procs := map[int32]Process{}
+			foo := proc(42, 1, 1, "foo")
+			bar := proc(42, 1, 1, "bar")
+
+			Expect(applyEvent(procs, event(Fork, foo))).To(ConsistOf(Change{Type: Added, Process: foo}))
+			Expect(applyEvent(procs, event(Fork, foo))).To(BeEmpty())
+			Expect(applyEvent(procs, event(Exec, bar))).To(ConsistOf(Change{Type: Updated, Process: bar, Old: foo}))
+			Expect(applyEvent(procs, event(Exec, bar))).To(BeEmpty())
+			Expect(procs).To(HaveKeyWithValue(int32(42), bar))
+			Expect(applyEvent(procs, event(Exit, bar))).To(ConsistOf(Change{Type: Removed, Process: bar}))
+			Expect(applyEvent(procs, event(Exit, bar))).To(BeEmpty())
+			Expect(procs).To(BeEmpty())
+		})
+
+		It("adds processes on exec when having missed their forks", func() {
+			procs := map[int32]Process{}
+			foo := proc(42, 1, 1, "foo")
+			Expect(applyEvent(procs, event(Exec, foo))).To(ConsistOf(Change{Type: Added, Process: foo}))
+		})
+
+		It("handles reused PIDs", func() {
+			old := proc(42, 1, 1, "old")
+			reuser := proc(42, 2, 1, "reuser")
+			procs := map[int32]Process{42: old}
+			Expect(applyEvent(procs, event(Fork, reuser))).To(HaveExactElements(
+				Change{Type: Removed, Process: old},
+				Change{Type: Added, Process: reuser},
+			))
+			Expect(applyEvent(procs, event(Exit, old))).To(BeEmpty())
+			Expect(procs).To(HaveKeyWithValue(int32(42), reuser))
+		})
+
+	})
+
+	It("diffs processes", func() {
+		Expect(diffProcesses(
+			map[int32]Process{
+				1:  proc(1, 0, 0, "init"),
+				42: proc(42, 1, 1, "foo"),
+				43: proc(43, 1, 42, "bar"),
+				44: proc(44, 1, 1, "gone"),
+			},
+			map[int32]Process{
+				1:  proc(1, 0, 0, "init"),
+				42: proc(42, 3, 1, "reuser"),
+				43: proc(43, 1, 1, "bar"),
+				45: proc(45, 4, 1, "new"),
+			})).To(HaveExactElements(
+			Change{Type: Removed, Process: proc(42, 1, 1, "foo")},
+			Change{Type: Added, Process: proc(42, 3, 1, "reuser")},
+			Change{Type: Updated, Process: proc(43, 1, 1, "bar"), Old: proc(43, 1, 42, "bar")},
+			Change{Type: Removed, Process: proc(44, 1, 1, "gone")},
+			Change{Type: Added, Process: proc(45, 4, 1, "new")},
+		))
+	})
+
+	Context("resyncing", func() {
+
+		It("replays events received while resyncing", func(ctx context.Context) {
+			init := proc(1, 0, 0, "init")
+			foo := proc(42, 1, 1, "foo")
+			bar := proc(43, 2, 1, "bar")
+			zombie := proc(44, 1, 1, "zombie")
+			zombieTask := task(zombie)
+			zombieTask.State = tasks.Zombie
+			thread := task(foo)
+			thread.TID = 666
+
+			var pt *ProcessTable
+			pt = newProcessTable(taskSeq(
+				[]beesy.Task{task(init), task(foo), thread, zombieTask},
+				func() {
+					stale := event(Fork, proc(100, 1, 1, "stale"))
+					stale.Timestamp = 0
+					pt.apply(stale)
+					pt.apply(event(Fork, bar))
+					pt.apply(event(Exit, foo))
+				}))
+			ch := pt.Subscribe(ctx)
+			Expect(pt.Resync(ctx)).To(Succeed())
+			Expect(pt.Processes()).To(HaveExactElements(init, bar))
+			Expect(pt.Len()).To(Equal(2))
+			p, ok := pt.Lookup(43)
+			Expect(ok).To(BeTrue())
+			Expect(p).To(Equal(bar))
+			_, ok = pt.Lookup(42)
+			Expect(ok).To(BeFalse())
+			Expect(pt.Err()).NotTo(HaveOccurred())
+
+			stale := proc(100, 1, 1, "stale")
+			var changes []Change
+			for range 4 {
+				var change Change
+				Expect(ch).To(Receive(&change))
+				changes = append(changes, change)
+			}
+			Expect(ch).NotTo(Receive())
+			Expect(changes).To(HaveExactElements(
+				Change{Type: Added, Process: stale}, // live events...
+				Change{Type: Added, Process: bar},
+				Change{Type: Added, Process: init}, // ...and resync
+				Change{Type: Removed, Process: stale},
+			))
+		})
+
+		It("doesn't replay stale events over the snapshot", func(ctx context.Context) {
+			init := proc(1, 0, 0, "init")
+			orphan := proc(42, 1, 1, "orphan")
+			var pt *ProcessTable
+			pt = newProcessTable(taskSeq(
+				[]beesy.Task{task(init), task(orphan)},
+				func() {
+					// the exec happened before the orphan got re-parented.
+					pt.apply(event(Exec, proc(42, 1, 41, "orphan")))
+					pt.apply(event(Fork, proc(43, 2, 1, "sh")))
+					pt.apply(event(Exec, proc(43, 2, 1, "ls")))
+				}))
+			Expect(pt.Resync(ctx)).To(Succeed())
+			Expect(pt.Processes()).To(HaveExactElements(init, orphan, proc(43, 2, 1, "ls")))
+		})
+
+		It("resyncs on lost events", func(ctx context.Context) {
+			var resyncs, lost atomic.Uint64
+			pt := newProcessTable(func(context.Context) iter.Seq2[beesy.Task, error] {
+				resyncs.Add(1)
+				return taskSeq(nil, nil)(ctx)
+			})
+			pt.lost = func() (uint64, error) { return lost.Load(), nil }
+			loopctx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				pt.resyncOnLostEvents(loopctx, 10*time.Millisecond)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+			Consistently(resyncs.Load).Within(100 * time.Millisecond).Should(BeZero())
+			lost.Store(42)
+			Eventually(resyncs.Load).Should(Equal(uint64(1)))
+			Consistently(resyncs.Load).Within(100 * time.Millisecond).Should(Equal(uint64(1)))
+		})
+
+		It("keeps the table when resyncing fails", func(ctx context.Context) {
+			init := proc(1, 0, 0, "init")
+			pt := newProcessTable(taskSeq([]beesy.Task{task(init)}, nil))
+			Expect(pt.Resync(ctx)).To(Succeed())
+			pt.tasks = func(context.Context) iter.Seq2[beesy.Task, error] {
+				return func(yield func(beesy.Task, error) bool) {
+					yield(beesy.Task{}, errors.New("D'OH!"))
+				}
+			}
+			Expect(pt.Resync(ctx)).To(MatchError(ContainSubstring("D'OH!")))
+			Expect(pt.Processes()).To(ConsistOf(init))
+			Expect(pt.resyncing).To(BeFalse())
+			Expect(pt.pending).To(BeNil())
+		})
+
+	})
+
+	Context("subscriptions", func() {
+
+		It("closes channels when unsubscribing and closing", func(ctx context.Context) {
+			pt := newProcessTable(taskSeq(nil, nil))
+			subctx, cancel := context.WithCancel(ctx)
+			ch1 := pt.Subscribe(subctx)
+			ch2 := pt.Subscribe(ctx)
+			cancel()
+			Eventually(ch1).Should(BeClosed())
+			Expect(ch2).NotTo(BeClosed())
+			pt.Close()
+			Expect(ch2).To(BeClosed())
+		})
+
+		It("notifies changes in the order applied", func(ctx context.Context) {
+			pt := newProcessTable(taskSeq(nil, nil))
+			ch := pt.Subscribe(ctx)
+			const procs = SubscriberBufferSize / 2
+			var wg sync.WaitGroup
+			for pid := range int32(procs) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p := proc(pid+1, 1, 1, "foo")
+					pt.apply(event(Fork, p))
+					pt.apply(event(Exit, p))
+				}()
+			}
+			wg.Wait()
+			added := map[int32]bool{}
+			for range 2 * procs {
+				var change Change
+				Expect(ch).To(Receive(&change))
+				switch change.Type {
+				case Added:
+					added[change.Process.PID] = true
+				case Removed:
+					Expect(added).To(HaveKey(change.Process.PID))
+				}
+			}
+			Expect(added).To(HaveLen(procs))
+		})
+
+		It("drops subscribers falling behind", func(ctx context.Context) {
+			pt := newProcessTable(taskSeq(nil, nil))
+			ch := pt.Subscribe(ctx)
+			for pid := range int32(SubscriberBufferSize + 1) {
+				pt.apply(event(Fork, proc(pid+1, 1, 1, "foo")))
+			}
+			Expect(ch).To(HaveLen(SubscriberBufferSize))
+			for range SubscriberBufferSize {
+				Expect(ch).To(Receive())
+			}
+			Expect(ch).To(BeClosed())
+			Expect(pt.subs).To(BeEmpty())
+		})
+
+	})
+
+	Context("ebpf", func() {
+
+		BeforeEach(func() {
+			if os.Getuid() != 0 {
+				Skip("needs root")
+			}
+
+			goodgos := Goroutines()
+			goodfds := Filedescriptors()
+			DeferCleanup(func() {
+				Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+					ShouldNot(HaveLeaked(goodgos))
+				Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+			})
+		})
+
+		It("keeps in sync with processes", func(ctx context.Context) {
+			pt := Successful(NewProcessTable(ctx, WithResyncInterval(100*time.Millisecond)))
+			defer pt.Close()
+			self, ok := pt.Lookup(int32(os.Getpid()))
+			Expect(ok).To(BeTrue())
+			Expect(self.StartTime).NotTo(BeZero())
+
+			ch := pt.Subscribe(ctx)
+			cmd := exec.Command("/bin/sleep", "1h")
+			Expect(cmd.Start()).To(Succeed())
+			pid := int32(cmd.Process.Pid)
+			Eventually(func() string {
+				p, _ := pt.Lookup(pid)
+				return p.Name
+			}).Within(2 * time.Second).ProbeEvery(10 * time.Millisecond).Should(Equal("sleep"))
+			Expect(cmd.Process.Kill()).To(Succeed())
+			Expect(cmd.Wait()).To(HaveOccurred())
+			Eventually(func() bool {
+				_, ok := pt.Lookup(pid)
+				return ok
+			}).Within(2 * time.Second).ProbeEvery(10 * time.Millisecond).Should(BeFalse())
+
+			Eventually(ch).Should(Receive(And(
+				HaveField("Type", Removed),
+				HaveField("Process.PID", pid))))
+			Expect(pt.Err()).NotTo(HaveOccurred())
+		})
+
+	})
+
+})
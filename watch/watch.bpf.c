@@ -0,0 +1,122 @@
+//go:build ignore
+
+#include "task.h"
+#include "bpf_core_read.h"
+#include "bpf_tracing.h"
+
+char __license[] SEC("license") = "Dual MIT/GPL";
+
+#define EVENT_FORK 1
+#define EVENT_EXEC 2
+#define EVENT_EXIT 3
+
+// event defines the binary representation of a process event sent to user
+// space over the events ring buffer.
+struct event {
+    __u64 timestamp;  // CLOCK_MONOTONIC nanoseconds
+    __u64 start_time; // CLOCK_MONOTONIC nanoseconds
+    __u32 type;       // EVENT_xxx
+    int   root_pid;   // user-space PID in initial PID namespace
+    int   root_tid;   // user-space TID in initial PID namespace
+    int   root_ppid;  // user-space PPID in initial PID namespace
+    int   pid;        // user-space PID as seen from watcher's PID namespace, or 0
+    int   tid;        // user-space TID as seen from watcher's PID namespace, or 0
+    int   ppid;       // user-space PPID as seen from watcher's PID namespace, or 0
+    int   exit_code;  // wait(2) status, only for EVENT_EXIT
+    char  name[TASK_COMM_LEN];
+};
+
+const struct event _meh __attribute__((unused)); // force emitting struct event
+
+// The inode number of the watcher's PID namespace; user space sets this at load
+// time. As tracepoints run in the context of the task triggering an event, we
+// cannot use the current task's PID namespace, in contrast to iterators.
+const volatile __u32 watcher_pidns = 0;
+
+// Number of events lost due to a full ring buffer.
+__u64 lost_events = 0;
+
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 256 * 1024);
+} events SEC(".maps");
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/linux/pid_namespace.h#L17
+#define MAX_PID_NS_LEVEL 32
+
+/*
+ * tid_watcher_pidns returns the (user-space) TID for the specified task as
+ * seen from the watcher's PID namespace, or 0.
+ *
+ * As the watcher doesn't know the level of its own PID namespace (procfs only
+ * shows the levels below the procfs' PID namespace), we look for the watcher's
+ * PID namespace along the task's PID namespace levels.
+ */
+pid_t tid_watcher_pidns(struct task_struct *task)
+{
+    struct pid *thrpid = BPF_CORE_READ(task, thread_pid);
+    if (thrpid == NULL) {
+        return 0;
+    }
+    unsigned int level = BPF_CORE_READ(thrpid, level);
+    for (unsigned int l = 0; l <= level && l < MAX_PID_NS_LEVEL; l++) {
+        struct upid upid = BPF_CORE_READ(thrpid, numbers[l]);
+        if (BPF_CORE_READ(upid.ns, ns.inum) == watcher_pidns) {
+            return upid.nr;
+        }
+    }
+    return 0;
+}
+
+/*
+ * emit sends an event of the specified type about the specified task to user
+ * space, counting lost events if the ring buffer is full.
+ */
+void emit(__u32 type, struct task_struct *task)
+{
+    struct event *e = bpf_ringbuf_reserve(&events, sizeof(*e), 0);
+    if (e == NULL) {
+        __sync_fetch_and_add(&lost_events, 1);
+        return;
+    }
+    e->timestamp = bpf_ktime_get_ns();
+    e->start_time = BPF_CORE_READ(task, start_time);
+    e->type = type;
+
+    struct task_struct *leader = BPF_CORE_READ(task, group_leader);
+    struct task_struct *parent = BPF_CORE_READ(task, real_parent, group_leader);
+    e->root_pid = BPF_CORE_READ(task, tgid);
+    e->root_tid = BPF_CORE_READ(task, pid);
+    e->root_ppid = BPF_CORE_READ(parent, tgid);
+    e->pid = tid_watcher_pidns(leader);
+    e->tid = tid_watcher_pidns(task);
+    e->ppid = tid_watcher_pidns(parent);
+    e->exit_code = type == EVENT_EXIT ? BPF_CORE_READ(task, exit_code) : 0;
+    BPF_CORE_READ_STR_INTO(&e->name, task, comm);
+
+    bpf_ringbuf_submit(e, 0);
+}
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/trace/events/sched.h#L378
+SEC("tp_btf/sched_process_fork")
+int BPF_PROG(handle_fork, struct task_struct *parent, struct task_struct *child)
+{
+    emit(EVENT_FORK, child);
+    return 0;
+}
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/trace/events/sched.h#L404
+SEC("tp_btf/sched_process_exec")
+int BPF_PROG(handle_exec, struct task_struct *task)
+{
+    emit(EVENT_EXEC, task);
+    return 0;
+}
+
+// https://elixir.bootlin.com/linux/v6.12/source/include/trace/events/sched.h#L346
+SEC("tp_btf/sched_process_exit")
+int BPF_PROG(handle_exit, struct task_struct *task)
+{
+    emit(EVENT_EXIT, task);
+    return 0;
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:generate bpf2go -go-package watch watch watch.bpf.c -- -I../_headers/cilium-ebpf -I../_headers/libbpf -I../_headers/beesy
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/thediveo/beesy/bpfiter"
+	"golang.org/x/sys/unix"
+)
+
+// Watcher watches processes and threads getting forked, exec'ed, and exiting,
+// delivering the corresponding [Event]s over a BPF ring buffer.
+//
+// Only a single consumer should read the events of a Watcher at any time, as
+// each event gets delivered only once.
+type Watcher struct {
+	ebpfObjects watchObjects
+	links       []link.Link
+	events      *ringbuf.Reader
+}
+
+// NewWatcher returns a new Watcher, attached to the process fork, exec, and
+// exit tracepoints. Use [Watcher.All], [Watcher.AllContext], or
+// [Watcher.Events] to receive the events and [Watcher.Close] to release the
+// Watcher's resources when done.
+func NewWatcher(opts ...Option) (*Watcher, error) {
+	o := newOptions(opts...)
+	var stat unix.Stat_t
+	if err := unix.Stat("/proc/self/ns/pid", &stat); err != nil {
+		return nil, fmt.Errorf("cannot determine PID namespace, reason: %w", err)
+	}
+	spec, err := loadWatch()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load process watcher eBPF objects, reason: %w", err)
+	}
+	// newEvent accepts ring buffer records larger than a watchEvent, so a
+	// stale watchEvent would otherwise go unnoticed.
+	if err := bpfiter.CheckLayout[watchEvent](spec.Types, "event"); err != nil {
+		return nil, fmt.Errorf("mismatching process watcher event layout, reason: %w", err)
+	}
+	if err := spec.Variables["watcher_pidns"].Set(uint32(stat.Ino)); err != nil {
+		return nil, fmt.Errorf("cannot configure process watcher, reason: %w", err)
+	}
+	spec.Maps["events"].MaxEntries = o.ringBufferSize
+	w := &Watcher{}
+	if err := spec.LoadAndAssign(&w.ebpfObjects, nil); err != nil {
+		return nil, fmt.Errorf("cannot load process watcher eBPF objects, reason: %w", err)
+	}
+	for _, prog := range []*ebpf.Program{
+		w.ebpfObjects.HandleFork,
+		w.ebpfObjects.HandleExec,
+		w.ebpfObjects.HandleExit,
+	} {
+		l, err := link.AttachTracing(link.TracingOptions{
+			Program:    prog,
+			AttachType: ebpf.AttachTraceRawTp,
+		})
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("cannot attach process watcher, reason: %w", err)
+		}
+		w.links = append(w.links, l)
+	}
+	if w.events, err = ringbuf.NewReader(w.ebpfObjects.Events); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("cannot read process watcher events, reason: %w", err)
+	}
+	return w, nil
+}
+
+// Close releases all resources associated with this Watcher, ending any
+// ongoing event iterations and closing event channels.
+func (w *Watcher) Close() {
+	for _, l := range w.links {
+		l.Close()
+	}
+	w.links = nil
+	if w.events != nil {
+		w.events.Close()
+	}
+	w.ebpfObjects.Close()
+}
+
+// Lost returns the number of events lost so far because the ring buffer was
+// full. Users should then resync from a fresh task snapshot.
+func (w *Watcher) Lost() (uint64, error) {
+	var lost uint64
+	if err := w.ebpfObjects.LostEvents.Get(&lost); err != nil {
+		return 0, fmt.Errorf("cannot read lost events counter, reason: %w", err)
+	}
+	return lost, nil
+}
+
+// All returns an iterator over the process events, blocking while waiting for
+// further events. The iterator ends after the Watcher has been closed. In case
+// of a failure, the iterator will return a zero Event together with an error
+// and then end the sequence.
+func (w *Watcher) All() iter.Seq2[Event, error] {
+	return w.AllContext(context.Background())
+}
+
+// AllContext returns an iterator over the process events, see [Watcher.All].
+// In case the specified context is done, the iterator returns a zero Event
+// together with ctx.Err() and then ends the sequence.
+func (w *Watcher) AllContext(ctx context.Context) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		// flushing unblocks a waiting read as soon as the context is done;
+		// the read then returns any pending events before returning
+		// ErrFlushed.
+		stop := context.AfterFunc(ctx, func() { _ = w.events.Flush() })
+		defer stop()
+		var rec ringbuf.Record
+		for {
+			err := w.events.ReadInto(&rec)
+			if err != nil {
+				switch {
+				case errors.Is(err, ringbuf.ErrFlushed):
+					if ctxErr := ctx.Err(); ctxErr != nil {
+						yield(Event{}, ctxErr)
+						return
+					}
+					continue // stale flush of an earlier context.
+				case errors.Is(err, os.ErrClosed):
+					return
+				}
+				yield(Event{}, fmt.Errorf("cannot read process events, reason: %w", err))
+				return
+			}
+			ev, err := newEvent(rec.RawSample)
+			if err != nil {
+				yield(Event{}, fmt.Errorf("cannot decode process event, reason: %w", err))
+				return
+			}
+			if !yield(ev, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Events returns a channel receiving the process events. The channel gets
+// closed when the specified context is done, the Watcher has been closed, or
+// in case of a failure.
+func (w *Watcher) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for ev, err := range w.AllContext(ctx) {
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 Harald Albrecht.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy
+// of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package watch
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gleak"
+	. "github.com/thediveo/fdooze"
+	. "github.com/thediveo/success"
+)
+
+var _ = Describe("process watcher", func() {
+
+	BeforeEach(func() {
+		if os.Getuid() != 0 {
+			Skip("needs root")
+		}
+
+		goodgos := Goroutines()
+		goodfds := Filedescriptors()
+		DeferCleanup(func() {
+			Eventually(Goroutines).Within(2 * time.Second).ProbeEvery(100 * time.Millisecond).
+				ShouldNot(HaveLeaked(goodgos))
+			Expect(Filedescriptors()).NotTo(HaveLeakedFds(goodfds))
+		})
+	})
+
+	It("watches a process being forked, exec'ed, and exiting", func(ctx context.Context) {
+		w := Successful(NewWatcher())
+		defer w.Close()
+
+		cmd := exec.Command("/bin/sh", "-c", "exit 42")
+		Expect(cmd.Start()).To(Succeed())
+		pid := int32(cmd.Process.Pid)
+		Expect(cmd.Wait()).To(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		var events []Event
+		for ev, err := range w.AllContext(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			if ev.PID != pid {
+				continue
+			}
+			events = append(events, ev)
+			if ev.Type == Exit && !ev.IsThread() {
+				break
+			}
+		}
+		Expect(events).To(ContainElements(
+			And(HaveField("Type", Fork), HaveField("TID", pid), HaveField("PPID", int32(os.Getpid()))),
+			And(HaveField("Type", Exec), HaveField("Name", "sh")),
+			And(HaveField("Type", Exit), HaveField("Status.ExitStatus()", 42)),
+		))
+		Expect(events[0].RootPID).NotTo(BeZero())
+		Expect(events[0].StartTime).To(Equal(events[len(events)-1].StartTime))
+		Expect(Successful(w.Lost())).To(BeZero())
+	})
+
+	It("ends iterating when the context is done", func(ctx context.Context) {
+		w := Successful(NewWatcher())
+		defer w.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		var err error
+		for _, err = range w.AllContext(ctx) {
+			if err != nil {
+				break
+			}
+		}
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("closes the event channel when the watcher is closed", func(ctx context.Context) {
+		w := Successful(NewWatcher())
+		ch := w.Events(ctx)
+		w.Close()
+		Eventually(ch).Within(2 * time.Second).Should(BeClosed())
+	})
+
+})